@@ -3,11 +3,15 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
 	"time"
@@ -15,6 +19,7 @@ import (
 	"github.com/shellkjell/langspace/pkg/compile"
 	_ "github.com/shellkjell/langspace/pkg/compile/python"     // Register Python compiler
 	_ "github.com/shellkjell/langspace/pkg/compile/typescript" // Register TypeScript compiler
+	"github.com/shellkjell/langspace/pkg/formatter"
 	"github.com/shellkjell/langspace/pkg/lsp"
 	"github.com/shellkjell/langspace/pkg/parser"
 	"github.com/shellkjell/langspace/pkg/runtime"
@@ -51,6 +56,8 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 		err = runLSP(commandArgs, stdin, stdout, stderr)
 	case "validate":
 		err = runValidate(commandArgs, stdin, stdout)
+	case "fmt":
+		err = runFmt(commandArgs, stdin, stdout)
 	case "help", "-h", "--help":
 		return showHelp(stdout)
 	case "version":
@@ -77,6 +84,7 @@ Commands:
   run       Execute an intent or pipeline
   compile   Compile to target language (python, typescript)
   validate  Validate a LangSpace file without executing
+  fmt       Reformat a LangSpace file in canonical style
   serve     Start trigger server
 
 Options:
@@ -88,6 +96,7 @@ Examples:
   langspace run -file workflow.ls -name my-intent
   langspace run -file workflow.ls -name my-pipeline -input "Review this code"
   langspace validate -file workflow.ls
+  langspace fmt -file workflow.ls -w
 
 For more information, visit: https://github.com/shellkjell/langspace
 `
@@ -132,7 +141,7 @@ func runParse(args []string, stdin io.Reader, stdout io.Writer) error {
 		p := parser.New(string(content))
 		entities, _, err := p.Parse()
 		if err != nil {
-			return fmt.Errorf("parse error: %w", err)
+			return fmt.Errorf("<stdin>: %w", err)
 		}
 
 		for _, entity := range entities {
@@ -173,7 +182,15 @@ func runExecute(args []string, stdin io.Reader, stdout, stderr io.Writer) error
 	inputFile2 := fs.String("input-file", "", "File containing input data")
 	timeout := fs.Duration("timeout", 5*time.Minute, "Execution timeout")
 	noStream := fs.Bool("no-stream", false, "Disable streaming output")
-	verbose := fs.Bool("verbose", false, "Show verbose output")
+	verbose := fs.Bool("verbose", false, "Show verbose output, and log each provider request to stderr")
+	manifestPath := fs.String("manifest", "", "Write a reproducibility manifest (JSON) to this path")
+	maxCost := fs.Float64("max-cost", 0, "Abort once estimated spend reaches this many USD (0 for no limit)")
+	showJSON := fs.Bool("json", false, "Print the execution result as JSON (disables streaming output)")
+	recordPath := fs.String("record", "", "Record every LLM response to this JSONL file")
+	replayPath := fs.String("replay", "", "Answer LLM requests from a file written by -record instead of calling providers")
+	replayFallback := fs.Bool("replay-fallback", false, "With -replay, call the real provider for requests that were not recorded")
+	escalate := fs.Bool("escalate", false, "Ask on stdin for the output of a failed pipeline step instead of failing the run")
+	dryRun := fs.Bool("dry-run", false, "Print the agent, model and provider of each step without calling any provider")
 
 	if err := fs.Parse(args); err != nil {
 		return fmt.Errorf("parsing flags: %w", err)
@@ -187,6 +204,11 @@ func runExecute(args []string, stdin io.Reader, stdout, stderr io.Writer) error
 		return fmt.Errorf("required flag -name not provided")
 	}
 
+	// Keep stdout machine-readable
+	if *showJSON {
+		*noStream = true
+	}
+
 	// Load file and its imports
 
 	ws := workspace.New()
@@ -216,16 +238,79 @@ func runExecute(args []string, stdin io.Reader, stdout, stderr io.Writer) error
 	}
 
 	// Create runtime
-	rt := runtime.New(ws, runtime.WithConfig(&runtime.Config{
+	rtOpts := []runtime.Option{runtime.WithConfig(&runtime.Config{
 		DefaultModel:    "claude-sonnet-4-20250514",
 		DefaultProvider: "anthropic",
 		Timeout:         *timeout,
+		MaxRetries:      3,
 		EnableStreaming: !*noStream,
-	}))
+		MaxCostUSD:      *maxCost,
+	})}
+	if *escalate {
+		rtOpts = append(rtOpts, runtime.WithEscalationHandler(runtime.StdinEscalationHandler(stdin, stderr)))
+	}
+	if *verbose {
+		logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		rtOpts = append(rtOpts, runtime.WithLogger(logger))
+	}
+	rt := runtime.New(ws, rtOpts...)
 
 	// Register providers
-	rt.RegisterProvider("anthropic", runtime.NewAnthropicProvider())
-	rt.RegisterProvider("openai", runtime.NewOpenAIProvider())
+	providers := map[string]runtime.LLMProvider{
+		"anthropic": runtime.NewAnthropicProvider(),
+		"openai":    runtime.NewOpenAIProvider(),
+		"ollama":    runtime.NewOllamaProvider(),
+	}
+	if *recordPath != "" && *replayPath != "" {
+		return fmt.Errorf("-record and -replay cannot be used together")
+	}
+	if *recordPath != "" {
+		f, err := os.Create(*recordPath)
+		if err != nil {
+			return fmt.Errorf("creating recording: %w", err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				log.Printf("failed to close recording: %v", err)
+			}
+		}()
+		recorder := runtime.NewRecorder(f)
+		for name, p := range providers {
+			providers[name] = recorder.Wrap(p)
+		}
+	}
+	if *replayPath != "" {
+		data, err := os.ReadFile(*replayPath)
+		if err != nil {
+			return fmt.Errorf("reading recording: %w", err)
+		}
+		for name, p := range providers {
+			var opts []runtime.ReplayOption
+			if *replayFallback {
+				opts = append(opts, runtime.WithReplayFallback(p))
+			}
+			replay, err := runtime.NewReplayProvider(bytes.NewReader(data), opts...)
+			if err != nil {
+				return fmt.Errorf("%s: %w", *replayPath, err)
+			}
+			providers[name] = replay
+		}
+	}
+	for name, p := range providers {
+		rt.RegisterProvider(name, p)
+	}
+
+	if *dryRun {
+		entity, found := ws.GetEntityByName(*entityType, *entityName)
+		if !found {
+			return fmt.Errorf("entity not found: %s %q", *entityType, *entityName)
+		}
+		plan, err := rt.Plan(context.Background(), entity)
+		if err != nil {
+			return err
+		}
+		return printPlan(stdout, plan, *showJSON)
+	}
 
 	// Create stream handler for output
 	var handler runtime.StreamHandler
@@ -248,11 +333,40 @@ func runExecute(args []string, stdin io.Reader, stdout, stderr io.Writer) error
 	}
 	opts = append(opts, runtime.WithTimeout(*timeout))
 
+	// Write the manifest before executing so it exists even if the run fails
+	if *manifestPath != "" {
+		entity, found := ws.GetEntityByName(*entityType, *entityName)
+		if !found {
+			return fmt.Errorf("entity not found: %s %q", *entityType, *entityName)
+		}
+		manifest, err := rt.Manifest(entity, input)
+		if err != nil {
+			return fmt.Errorf("building manifest: %w", err)
+		}
+		if err := manifest.WriteFile(*manifestPath); err != nil {
+			return err
+		}
+		// Run with the seed the manifest records
+		opts = append(opts, runtime.WithRunSeed(manifest.Config.RunSeed))
+	}
+
 	result, err := rt.ExecuteByName(ctx, *entityType, *entityName, opts...)
 	if err != nil {
 		return fmt.Errorf("execution failed: %w", err)
 	}
 
+	if *showJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return fmt.Errorf("encoding result: %w", err)
+		}
+		if !result.Success {
+			return fmt.Errorf("execution failed: %v", result.Error)
+		}
+		return nil
+	}
+
 	// Print result
 	if !*noStream {
 		checkPrint(fmt.Fprintln(stdout)) // Newline after streaming
@@ -273,6 +387,30 @@ func runExecute(args []string, stdin io.Reader, stdout, stderr io.Writer) error
 	return nil
 }
 
+// printPlan prints an execution plan, failing if it has warnings.
+func printPlan(w io.Writer, plan *runtime.ExecutionPlan, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			return fmt.Errorf("encoding plan: %w", err)
+		}
+	} else {
+		checkPrint(fmt.Fprintf(w, "%s %q: %d steps\n", plan.EntityType, plan.EntityName, plan.TotalSteps))
+		for i, step := range plan.Steps {
+			checkPrint(fmt.Fprintf(w, "  %d. %s: agent=%s model=%s provider=%s\n", i+1, step.Name, step.Agent, step.Model, step.Provider))
+		}
+		for _, warning := range plan.Warnings {
+			checkPrint(fmt.Fprintf(w, "⚠️  %s\n", warning))
+		}
+	}
+
+	if len(plan.Warnings) > 0 {
+		return fmt.Errorf("plan has %d warning(s)", len(plan.Warnings))
+	}
+	return nil
+}
+
 // runCompile handles the compile command
 func runCompile(args []string, stdout io.Writer) error {
 	fs := flag.NewFlagSet("compile", flag.ContinueOnError)
@@ -344,11 +482,60 @@ func runValidate(args []string, stdin io.Reader, stdout io.Writer) error {
 	}
 
 	// For validation, we might want to still show ParseWithRecovery errors from the main file,
-	// but Loader already parsed it. Let's just output success for now if Loader succeeds.
+	// but Loader already parsed it. Check cross-entity references now that everything is loaded.
+	if err := workspace.ValidateWorkspace(ws); err != nil {
+		return fmt.Errorf("validation failed:\n%w", err)
+	}
+
 	checkPrint(fmt.Fprintf(stdout, "Validation successful: %d entities loaded (including imports)\n", len(ws.GetEntities())))
 	return nil
 }
 
+// runFmt handles the fmt command
+func runFmt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	inputFile := fs.String("file", "", "LangSpace file to format (default: stdin)")
+	write := fs.Bool("w", false, "Write the result back to the file instead of stdout")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	var content []byte
+	var err error
+	name := "<stdin>"
+	if *inputFile == "" {
+		if *write {
+			return fmt.Errorf("-w requires -file")
+		}
+		content, err = io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("reading stdin: %w", err)
+		}
+	} else {
+		name = *inputFile
+		content, err = os.ReadFile(*inputFile)
+		if err != nil {
+			return fmt.Errorf("reading input: %w", err)
+		}
+	}
+
+	entities, imports, err := parser.New(string(content)).Parse()
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	formatted, err := formatter.FormatFile(imports, entities)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	if *write {
+		return os.WriteFile(*inputFile, []byte(formatted), 0644)
+	}
+	checkPrint(fmt.Fprint(stdout, formatted))
+	return nil
+}
+
 // runServe handles the serve command
 func runServe(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
@@ -371,9 +558,11 @@ func runServe(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	}
 
 	// Create runtime
-	rt := runtime.New(ws)
+	metrics := runtime.NewMetrics()
+	rt := runtime.New(ws, runtime.WithMetrics(metrics))
 	rt.RegisterProvider("anthropic", runtime.NewAnthropicProvider())
 	rt.RegisterProvider("openai", runtime.NewOpenAIProvider())
+	rt.RegisterProvider("ollama", runtime.NewOllamaProvider())
 
 	// Start trigger engine
 	engine := runtime.NewTriggerEngine(rt)
@@ -384,8 +573,10 @@ func runServe(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	checkPrint(fmt.Fprintf(stdout, "LangSpace server listening on port %d...\n", *port))
 	checkPrint(fmt.Fprintf(stdout, "Trigger engine active with %d triggers\n", len(ws.GetEntitiesByType("trigger"))))
 
-	// Keep running until interrupted
-	select {}
+	// Serve metrics until interrupted
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	return http.ListenAndServe(fmt.Sprintf(":%d", *port), mux)
 }
 
 // runLSP handles the lsp command
@@ -439,6 +630,9 @@ func printExecutionResult(w io.Writer, result *runtime.ExecutionResult) {
 		result.TokensUsed.TotalTokens,
 		result.TokensUsed.InputTokens,
 		result.TokensUsed.OutputTokens))
+	if result.CostUSD > 0 {
+		checkPrint(fmt.Fprintf(w, "Estimated Cost: $%.4f\n", result.CostUSD))
+	}
 
 	if len(result.StepResults) > 0 {
 		checkPrint(fmt.Fprintln(w, "\nStep Results:"))
@@ -481,6 +675,10 @@ func (h *CLIStreamHandler) OnProgress(event runtime.ProgressEvent) {
 			checkPrint(fmt.Fprintf(h.stderr, "- %s\n", event.Message))
 		case runtime.ProgressTypeError:
 			checkPrint(fmt.Fprintf(h.stderr, "❌ %s\n", event.Message))
+		case runtime.ProgressTypeEscalation:
+			checkPrint(fmt.Fprintf(h.stderr, "🙋 %s\n", event.Message))
+		case runtime.ProgressTypeWarning:
+			checkPrint(fmt.Fprintf(h.stderr, "⚠️  %s\n", event.Message))
 		}
 	}
 }
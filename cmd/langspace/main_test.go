@@ -222,3 +222,84 @@ agent "test" {
 		t.Errorf("expected 1 entity, got: %s", output)
 	}
 }
+
+func TestRun_Fmt(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test.ls")
+	content := `agent "a" { temperature: 0.5 model: "m" }`
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+	want := "agent \"a\" {\n  model: \"m\"\n  temperature: 0.5\n}\n"
+
+	stdout := &bytes.Buffer{}
+	if err := run([]string{"fmt"}, strings.NewReader(content), stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if stdout.String() != want {
+		t.Errorf("fmt output = %q, want %q", stdout.String(), want)
+	}
+
+	if err := run([]string{"fmt", "-file", tmpFile, "-w"}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	got, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("rewritten file = %q, want %q", got, want)
+	}
+}
+
+func TestRun_DryRun(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test.ls")
+	content := `agent "writer" {
+	model: "gpt-4o"
+}
+
+pipeline "report" {
+	step "draft" {
+		use: agent("writer")
+	}
+	step "review" {
+		use: agent("reviewer")
+	}
+}`
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	err := run([]string{"run", "-file", tmpFile, "-name", "report", "-dry-run"}, strings.NewReader(""), stdout, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "1 warning") {
+		t.Errorf("run() error = %v, want the unresolved agent reported", err)
+	}
+
+	output := stdout.String()
+	for _, want := range []string{`pipeline "report": 2 steps`, "draft: agent=writer model=gpt-4o provider=openai", `step "review"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got: %s", want, output)
+		}
+	}
+}
+
+func TestRun_ValidateExamples(t *testing.T) {
+	// The advanced examples use expression syntax the parser doesn't
+	// support yet, so only the top-level examples are checked
+	files, err := filepath.Glob("../../examples/*.ls")
+	if err != nil {
+		t.Fatalf("glob examples: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no examples found")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			if err := run([]string{"validate", "-file", file}, strings.NewReader(""), stdout, &bytes.Buffer{}); err != nil {
+				t.Fatalf("validate %s: %v", file, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,621 @@
+// Package formatter renders parsed LangSpace entities back to source text in
+// a canonical layout, in the spirit of gofmt.
+//
+// Output uses two-space indentation. Within a block, plain properties come
+// first in alphabetical order, followed by the block's steps in declaration
+// order and then nested blocks (parallel, branch, loop, handlers) in
+// alphabetical order of their property names. Parsing formatted output yields
+// an entity tree equivalent to the one that was formatted.
+//
+// Comments are not part of the AST and are therefore not preserved.
+package formatter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+const indentUnit = "  "
+
+// maxInlineArray is the longest array, in bytes, that is kept on one line.
+const maxInlineArray = 60
+
+// Format renders entities as canonical LangSpace source. An error is returned
+// if an entity contains a value that has no source representation, such as a
+// branch stored under a property other than branch.
+func Format(entities []ast.Entity) (string, error) {
+	return FormatFile(nil, entities)
+}
+
+// FormatFile renders a whole file: its import directives followed by its
+// entities. See Format.
+func FormatFile(imports []ast.Import, entities []ast.Entity) (string, error) {
+	var b strings.Builder
+
+	for _, imp := range imports {
+		fmt.Fprintf(&b, "import %s\n", quote(imp.Path))
+	}
+
+	for i, entity := range entities {
+		if i > 0 || len(imports) > 0 {
+			b.WriteString("\n")
+		}
+		text, err := formatEntity(entity)
+		if err != nil {
+			return "", fmt.Errorf("%s %q: %w", entity.Type(), entity.Name(), err)
+		}
+		b.WriteString(text)
+		b.WriteString("\n")
+	}
+
+	return b.String(), nil
+}
+
+// formatEntity renders a top-level entity.
+func formatEntity(entity ast.Entity) (string, error) {
+	header := entity.Type()
+	if entity.Type() != "config" || entity.Name() != "" {
+		header += " " + quote(entity.Name())
+	}
+
+	body, err := formatBlock(entity, 0)
+	if err != nil {
+		return "", err
+	}
+	return header + " " + body, nil
+}
+
+// formatNamed renders a nested block written as `type "name" { ... }`, or
+// `type { ... }` when it has no name.
+func formatNamed(entity ast.Entity, depth int) (string, error) {
+	header := entity.Type()
+	if entity.Name() != "" {
+		header += " " + quote(entity.Name())
+	}
+
+	body, err := formatBlock(entity, depth)
+	if err != nil {
+		return "", err
+	}
+	return header + " " + body, nil
+}
+
+// formatBlock renders the braces and contents of an entity whose opening
+// line is at depth.
+func formatBlock(entity ast.Entity, depth int) (string, error) {
+	props := entity.Properties()
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var lines, blocks []string
+	for _, step := range childSteps(entity) {
+		text, err := formatNamed(step, depth+1)
+		if err != nil {
+			return "", fmt.Errorf("step %q: %w", step.Name(), err)
+		}
+		blocks = append(blocks, text)
+	}
+	for _, key := range keys {
+		value := props[key]
+		if isBlockProperty(entity, key, value) {
+			text, err := formatBlockProperty(key, value, depth+1)
+			if err != nil {
+				return "", fmt.Errorf("property %q: %w", key, err)
+			}
+			blocks = append(blocks, text)
+			continue
+		}
+
+		if !isIdent(key) {
+			return "", fmt.Errorf("property name %q is not an identifier", key)
+		}
+		if key == "branch" || key == "loop" {
+			return "", fmt.Errorf("property %q must be a %s block", key, key)
+		}
+		text, err := formatValue(value, depth+1)
+		if err != nil {
+			return "", fmt.Errorf("property %q: %w", key, err)
+		}
+		lines = append(lines, key+": "+text)
+	}
+
+	if len(lines) == 0 && len(blocks) == 0 {
+		return "{}", nil
+	}
+
+	indent := strings.Repeat(indentUnit, depth+1)
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, line := range lines {
+		b.WriteString(indent + line + "\n")
+	}
+	for i, block := range blocks {
+		if i > 0 || len(lines) > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + block + "\n")
+	}
+	b.WriteString(strings.Repeat(indentUnit, depth) + "}")
+	return b.String(), nil
+}
+
+// childSteps returns the steps a pipeline or parallel block holds outside its
+// property map.
+func childSteps(entity ast.Entity) []*ast.StepEntity {
+	switch e := entity.(type) {
+	case *ast.PipelineEntity:
+		return e.Steps
+	case *ast.ParallelEntity:
+		return e.Steps
+	}
+	return nil
+}
+
+// nestedKeywords are the property names the parser accepts in block form,
+// as in `handler "name" { ... }`; see parser.isNestedEntityKeyword.
+var nestedKeywords = map[string]bool{
+	"step": true, "parallel": true, "handler": true, "on_success": true,
+	"on_failure": true, "on_error": true, "on_complete": true, "config": true,
+}
+
+// isBlockProperty reports whether a property is written as a block rather
+// than as `key: value`.
+func isBlockProperty(parent ast.Entity, key string, value ast.Value) bool {
+	switch v := value.(type) {
+	case ast.BranchValue:
+		return key == "branch"
+	case ast.LoopValue:
+		return key == "loop"
+	case ast.NestedEntityValue:
+		if v.Entity == nil || v.Entity.Type() != key || !nestedKeywords[key] {
+			return false
+		}
+		// A step block directly inside a pipeline or parallel block is parsed
+		// as one of its steps, not as a property
+		_, isStep := v.Entity.(*ast.StepEntity)
+		return !(isStep && childSteps(parent) != nil)
+	}
+	return false
+}
+
+func formatBlockProperty(key string, value ast.Value, depth int) (string, error) {
+	switch v := value.(type) {
+	case ast.BranchValue:
+		return formatBranch(v, depth)
+	case ast.LoopValue:
+		return formatLoop(v, depth)
+	case ast.NestedEntityValue:
+		return formatNamed(v.Entity, depth)
+	}
+	return "", fmt.Errorf("unexpected block value %T", value)
+}
+
+// formatBranch renders `branch <condition> { "case" => step "name" { ... } }`
+// with cases in alphabetical order.
+func formatBranch(branch ast.BranchValue, depth int) (string, error) {
+	cond, err := formatValue(branch.Condition, depth)
+	if err != nil {
+		return "", fmt.Errorf("branch condition: %w", err)
+	}
+
+	cases := make([]string, 0, len(branch.Cases))
+	for c := range branch.Cases {
+		cases = append(cases, c)
+	}
+	sort.Strings(cases)
+
+	indent := strings.Repeat(indentUnit, depth+1)
+	var b strings.Builder
+	b.WriteString("branch " + cond + " {\n")
+	for i, c := range cases {
+		nested := branch.Cases[c].Entity
+		if nested == nil {
+			return "", fmt.Errorf("branch case %q has no body", c)
+		}
+		label := quote(c)
+		text, err := formatNamed(nested, depth+1)
+		if err != nil {
+			return "", fmt.Errorf("branch case %q: %w", c, err)
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + label + " => " + text + "\n")
+	}
+	b.WriteString(strings.Repeat(indentUnit, depth) + "}")
+	return b.String(), nil
+}
+
+// formatLoop renders `loop max: N { ... }`, with the loop's steps followed by
+// its break_if condition.
+func formatLoop(loop ast.LoopValue, depth int) (string, error) {
+	header := "loop"
+	if loop.MaxIterations != 0 {
+		header += fmt.Sprintf(" max: %d", loop.MaxIterations)
+	}
+
+	var blocks []string
+	for _, nested := range loop.Body {
+		if nested.Entity == nil || nested.Entity.Type() != "step" {
+			return "", fmt.Errorf("loop body may only contain steps")
+		}
+		text, err := formatNamed(nested.Entity, depth+1)
+		if err != nil {
+			return "", fmt.Errorf("step %q: %w", nested.Entity.Name(), err)
+		}
+		blocks = append(blocks, text)
+	}
+	if loop.BreakCondition != nil {
+		cond, err := formatValue(loop.BreakCondition, depth+1)
+		if err != nil {
+			return "", fmt.Errorf("break_if: %w", err)
+		}
+		blocks = append(blocks, "break_if: "+cond)
+	}
+
+	if len(blocks) == 0 {
+		return header + " {}", nil
+	}
+
+	indent := strings.Repeat(indentUnit, depth+1)
+	var b strings.Builder
+	b.WriteString(header + " {\n")
+	for i, block := range blocks {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + block + "\n")
+	}
+	b.WriteString(strings.Repeat(indentUnit, depth) + "}")
+	return b.String(), nil
+}
+
+// formatValue renders a value appearing on a line indented to depth.
+func formatValue(value ast.Value, depth int) (string, error) {
+	switch v := value.(type) {
+	case ast.StringValue:
+		return quoteValue(v.Value), nil
+
+	case ast.NumberValue:
+		if math.IsNaN(v.Value) || math.IsInf(v.Value, 0) {
+			return "", fmt.Errorf("number %v cannot be written as a literal", v.Value)
+		}
+		return strconv.FormatFloat(v.Value, 'f', -1, 64), nil
+
+	case ast.BoolValue:
+		return strconv.FormatBool(v.Value), nil
+
+	case ast.ArrayValue:
+		return formatArray(v, depth)
+
+	case ast.ObjectValue:
+		return formatObject(v, depth)
+
+	case ast.ReferenceValue:
+		text := v.Type + "(" + quote(v.Name) + ")"
+		for _, seg := range v.Path {
+			text += "." + seg
+		}
+		return text, nil
+
+	case ast.VariableValue:
+		return "$" + v.Name, nil
+
+	case ast.PropertyAccessValue:
+		text := v.Base
+		for _, seg := range v.Path {
+			if strings.HasPrefix(seg, "[") {
+				text += seg
+			} else {
+				text += "." + seg
+			}
+		}
+		return text, nil
+
+	case ast.TypedParameterValue:
+		return formatTypedParameter(v, depth)
+
+	case ast.NestedEntityValue:
+		// In value position only an unnamed typed block, e.g. http { ... },
+		// can be written
+		if v.Entity == nil {
+			return "", fmt.Errorf("nested block has no entity")
+		}
+		if v.Entity.Name() != "" {
+			return "", fmt.Errorf("named %s block %q cannot be written as a value", v.Entity.Type(), v.Entity.Name())
+		}
+		return formatNamed(v.Entity, depth)
+
+	case ast.MethodCallValue:
+		return formatMethodCall(v, depth)
+
+	case ast.FunctionCallValue:
+		args, err := formatArguments(v.Arguments, depth)
+		if err != nil {
+			return "", err
+		}
+		return v.Function + args, nil
+
+	case ast.ComparisonValue:
+		left, err := formatValue(v.Left, depth)
+		if err != nil {
+			return "", err
+		}
+		right, err := formatValue(v.Right, depth)
+		if err != nil {
+			return "", err
+		}
+		return left + " " + v.Operator + " " + right, nil
+
+	case ast.BranchValue:
+		return "", fmt.Errorf("branch is only valid as a block property")
+
+	case ast.LoopValue:
+		return "", fmt.Errorf("loop is only valid as a block property")
+
+	case nil:
+		return "", fmt.Errorf("missing value")
+	}
+	return "", fmt.Errorf("unsupported value type %T", value)
+}
+
+// formatArray keeps short arrays of single-line elements on one line and
+// otherwise puts each element on its own line.
+func formatArray(arr ast.ArrayValue, depth int) (string, error) {
+	if len(arr.Elements) == 0 {
+		return "[]", nil
+	}
+
+	elems := make([]string, len(arr.Elements))
+	inline := true
+	width := 0
+	for i, elem := range arr.Elements {
+		text, err := formatValue(elem, depth+1)
+		if err != nil {
+			return "", err
+		}
+		elems[i] = text
+		width += len(text) + 2
+		if strings.Contains(text, "\n") {
+			inline = false
+		}
+	}
+	if inline && width <= maxInlineArray {
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	}
+
+	indent := strings.Repeat(indentUnit, depth+1)
+	return "[\n" + indent + strings.Join(elems, ",\n"+indent) + "\n" + strings.Repeat(indentUnit, depth) + "]", nil
+}
+
+// formatObject renders an object with one property per line in alphabetical
+// order. Statement expressions, which the parser collects under the
+// _statements key, are written first.
+func formatObject(obj ast.ObjectValue, depth int) (string, error) {
+	if len(obj.Properties) == 0 {
+		return "{}", nil
+	}
+
+	var lines []string
+	if stmts, ok := obj.Properties["_statements"].(ast.ArrayValue); ok {
+		for _, stmt := range stmts.Elements {
+			text, err := formatValue(stmt, depth+1)
+			if err != nil {
+				return "", err
+			}
+			lines = append(lines, text)
+		}
+	}
+
+	keys := make([]string, 0, len(obj.Properties))
+	for key := range obj.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := obj.Properties[key]
+		if _, ok := value.(ast.ArrayValue); ok && key == "_statements" {
+			continue
+		}
+		name := key
+		if !isIdent(key) {
+			name = quote(key)
+		}
+		text, err := formatValue(value, depth+1)
+		if err != nil {
+			return "", fmt.Errorf("key %q: %w", key, err)
+		}
+		lines = append(lines, name+": "+text)
+	}
+
+	indent := strings.Repeat(indentUnit, depth+1)
+	return "{\n" + indent + strings.Join(lines, "\n"+indent) + "\n" + strings.Repeat(indentUnit, depth) + "}", nil
+}
+
+// formatTypedParameter renders `type required|optional [default] ["description"]`,
+// or `enum ["a", "b"]` for a bare inline enum.
+func formatTypedParameter(param ast.TypedParameterValue, depth int) (string, error) {
+	var enum string
+	if param.ParamType == "enum" && len(param.EnumValues) > 0 {
+		values := make([]string, len(param.EnumValues))
+		for i, ev := range param.EnumValues {
+			values[i] = quote(ev)
+		}
+		enum = "[" + strings.Join(values, ", ") + "]"
+	}
+
+	if param.ParamType == "enum" && !param.Required && param.Default == nil && param.Description == "" {
+		if enum == "" {
+			enum = "[]"
+		}
+		return "enum " + enum, nil
+	}
+
+	parts := []string{param.ParamType, "optional"}
+	if param.Required {
+		parts[1] = "required"
+	}
+	if enum != "" {
+		parts = append(parts, enum)
+	}
+
+	// The parser reads a required parameter's string as its description and
+	// an optional parameter's first string as its default, so a string
+	// default on a required parameter and a bare description on an optional
+	// one have no source form.
+	switch d := param.Default.(type) {
+	case nil:
+		if !param.Required && param.Description != "" {
+			return "", fmt.Errorf("optional parameter with a description needs a default")
+		}
+	case ast.StringValue:
+		if param.Required {
+			return "", fmt.Errorf("required parameter cannot have a string default")
+		}
+		parts = append(parts, quote(d.Value))
+	case ast.NumberValue, ast.BoolValue, ast.ArrayValue:
+		if _, ok := d.(ast.ArrayValue); ok && param.ParamType == "enum" {
+			return "", fmt.Errorf("enum parameter cannot have an array default")
+		}
+		text, err := formatValue(d, depth)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, text)
+	default:
+		return "", fmt.Errorf("unsupported parameter default %T", param.Default)
+	}
+
+	if param.Description != "" {
+		parts = append(parts, quote(param.Description))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// formatMethodCall renders a call chain such as github.pr.comment(output),
+// including the inline block forms pipeline("name") { ... } and
+// github.pull_request { ... }.
+func formatMethodCall(call ast.MethodCallValue, depth int) (string, error) {
+	var body string
+	if call.InlineBody != nil {
+		if call.InlineBody.Name() != "" {
+			return "", fmt.Errorf("inline block of %s cannot be named", call.Method)
+		}
+		var err error
+		if body, err = formatBlock(call.InlineBody, depth); err != nil {
+			return "", err
+		}
+
+		// pipeline("name") { ... } is parsed with the entity type as the
+		// object and the referenced name as the method
+		if obj, ok := call.Object.(ast.StringValue); ok && obj.Value == call.InlineBody.Type() && len(call.Arguments) == 0 {
+			return obj.Value + "(" + quote(call.Method) + ") " + body, nil
+		}
+	}
+
+	var recv string
+	if obj, ok := call.Object.(ast.StringValue); ok {
+		recv = obj.Value
+	} else {
+		var err error
+		if recv, err = formatValue(call.Object, depth); err != nil {
+			return "", err
+		}
+	}
+
+	// a.b { ... } gives the block the type b, while a.b() { ... } leaves it
+	// untyped
+	if call.InlineBody != nil && call.InlineBody.Type() == call.Method && len(call.Arguments) == 0 {
+		return recv + "." + call.Method + " " + body, nil
+	}
+	if call.InlineBody != nil && call.InlineBody.Type() != "" {
+		return "", fmt.Errorf("inline block of %s() cannot have type %q", call.Method, call.InlineBody.Type())
+	}
+
+	args, err := formatArguments(call.Arguments, depth)
+	if err != nil {
+		return "", err
+	}
+	text := recv + "." + call.Method + args
+	if body != "" {
+		text += " " + body
+	}
+	return text, nil
+}
+
+func formatArguments(args []ast.Value, depth int) (string, error) {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		text, err := formatValue(arg, depth)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = text
+	}
+	return "(" + strings.Join(parts, ", ") + ")", nil
+}
+
+// quote renders s as a double-quoted string literal, escaping whatever the
+// tokenizer would otherwise decode or end the literal at.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// quoteValue renders a string value, keeping multi-line text readable in a
+// verbatim literal when one of the two fences can hold it.
+func quoteValue(s string) string {
+	if !strings.Contains(s, "\n") {
+		return quote(s)
+	}
+	// The parser drops the newline after the opening fence
+	for _, fence := range []string{"```", `"""`} {
+		if !strings.Contains(s, fence) && !strings.HasSuffix(s, fence[:1]) {
+			return fence + "\n" + s + fence
+		}
+	}
+	return quote(s)
+}
+
+// isIdent reports whether s is tokenized as a single identifier.
+func isIdent(s string) bool {
+	if s == "" || s == "true" || s == "false" {
+		return false
+	}
+	for i, r := range s {
+		letter := r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z')
+		if i == 0 && !letter {
+			return false
+		}
+		if !letter && r != '-' && !('0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,348 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+	"github.com/shellkjell/langspace/pkg/parser"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "properties sorted and reindented",
+			input: `agent "a" { model: "m"   temperature: 0.5 instruction: "Be brief" }`,
+			want: `agent "a" {
+  instruction: "Be brief"
+  model: "m"
+  temperature: 0.5
+}
+`,
+		},
+		{
+			name: "pipeline steps in order after properties",
+			input: `pipeline "p" {
+  step "b" { use: agent("x") }
+  output: step("b").output
+  step "a" { input: step("b").output }
+}`,
+			want: `pipeline "p" {
+  output: step("b").output
+
+  step "b" {
+    use: agent("x")
+  }
+
+  step "a" {
+    input: step("b").output
+  }
+}
+`,
+		},
+		{
+			name: "nested blocks",
+			input: `pipeline "p" {
+  parallel { step "x" { use: agent("a") } step "y" { use: agent("b") } }
+  loop max: 3 { break_if: $done == true step "r" { input: $current } }
+  branch $kind { "z" => step "z" {} "a" => step "a" { input: $input } }
+}`,
+			want: `pipeline "p" {
+  branch $kind {
+    "a" => step "a" {
+      input: $input
+    }
+
+    "z" => step "z" {}
+  }
+
+  loop max: 3 {
+    step "r" {
+      input: $current
+    }
+
+    break_if: $done == true
+  }
+
+  parallel {
+    step "x" {
+      use: agent("a")
+    }
+
+    step "y" {
+      use: agent("b")
+    }
+  }
+}
+`,
+		},
+		{
+			name: "arrays and objects",
+			input: `tool "t" {
+  tags: [a, "b", 1, true]
+  parameters: { query: string required "The query" limit: number optional 10 "Max results" }
+  long: ["aaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbb", "cccccccccccccccc", "dddddddddddddddd"]
+  empty: []
+}`,
+			want: `tool "t" {
+  empty: []
+  long: [
+    "aaaaaaaaaaaaaaaa",
+    "bbbbbbbbbbbbbbbb",
+    "cccccccccccccccc",
+    "dddddddddddddddd"
+  ]
+  parameters: {
+    limit: number optional 10 "Max results"
+    query: string required "The query"
+  }
+  tags: ["a", "b", 1, true]
+}
+`,
+		},
+		{
+			name:  "multi-line strings",
+			input: "agent \"a\" {\n  instruction: ```\n  Line one\n  \"Quoted\"\n  ```\n}",
+			want:  "agent \"a\" {\n  instruction: ```\n  Line one\n  \"Quoted\"\n  ```\n}\n",
+		},
+		{
+			name:  "escape sequences",
+			input: `agent "a" { instruction: "say \"hi\"\tC:\\dir" }`,
+			want:  "agent \"a\" {\n  instruction: \"say \\\"hi\\\"\\tC:\\\\dir\"\n}\n",
+		},
+		{
+			name:  "triple-quoted strings",
+			input: "file \"f\" { contents: \"\"\"\n```\n\"\"\" }",
+			want:  "file \"f\" {\n  contents: \"\"\"\n```\n\"\"\"\n}\n",
+		},
+		{
+			name:  "config and imports",
+			input: "import \"agents.ls\"\nconfig { default_model: \"m\" }",
+			want:  "import \"agents.ls\"\n\nconfig {\n  default_model: \"m\"\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entities, imports, err := parser.New(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := FormatFile(imports, entities)
+			if err != nil {
+				t.Fatalf("FormatFile() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FormatFile() =\n%s\nwant\n%s", got, tt.want)
+			}
+			assertRoundTrip(t, tt.input, got)
+		})
+	}
+}
+
+// TestFormat_RoundTripExamples formats every example and checks that the
+// result parses to the same entities and is itself already formatted.
+func TestFormat_RoundTripExamples(t *testing.T) {
+	files, err := filepath.Glob("../../examples/*.ls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no examples found")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entities, imports, err := parser.New(string(src)).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			formatted, err := FormatFile(imports, entities)
+			if err != nil {
+				t.Fatalf("FormatFile() error = %v", err)
+			}
+			assertRoundTrip(t, string(src), formatted)
+		})
+	}
+}
+
+func TestFormat_Values(t *testing.T) {
+	tests := []struct {
+		name  string
+		value ast.Value
+		want  string
+	}{
+		{"integer", ast.NumberValue{Value: 3}, "3"},
+		{"negative", ast.NumberValue{Value: -0.25}, "-0.25"},
+		{"quote", ast.StringValue{Value: `say "hi"`}, `"say \"hi\""`},
+		{"backslash and tab", ast.StringValue{Value: "C:\\dir\tx"}, `"C:\\dir\tx"`},
+		{"multi-line", ast.StringValue{Value: "a\nb"}, "```\na\nb```"},
+		{"multi-line with backticks", ast.StringValue{Value: "```go\nx\n```"}, "\"\"\"\n```go\nx\n```\"\"\""},
+		{"multi-line with both fences", ast.StringValue{Value: "a```\n\"\"\""}, `"a` + "```" + `\n\"\"\""`},
+		{"variable path", ast.PropertyAccessValue{Base: "$state", Path: []string{"pegs", "A", "[0]"}}, "$state.pegs.A[0]"},
+		{"inline enum", ast.TypedParameterValue{ParamType: "enum", EnumValues: []string{"a", "b"}}, `enum ["a", "b"]`},
+		{"function call", ast.FunctionCallValue{Function: "print", Arguments: []ast.Value{ast.VariableValue{Name: "x"}}}, "print($x)"},
+		{
+			"method chain",
+			ast.MethodCallValue{
+				Object:    ast.PropertyAccessValue{Base: "github", Path: []string{"pr"}},
+				Method:    "comment",
+				Arguments: []ast.Value{ast.StringValue{Value: "ok"}},
+			},
+			`github.pr.comment("ok")`,
+		},
+		{
+			"object keys needing quotes",
+			ast.ObjectValue{Properties: map[string]ast.Value{"Content-Type": ast.StringValue{Value: "json"}, "a b": ast.BoolValue{Value: false}}},
+			"{\n  Content-Type: \"json\"\n  \"a b\": false\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatValue(tt.value, 0)
+			if err != nil {
+				t.Fatalf("formatValue() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("formatValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		entity  func() ast.Entity
+		wantErr string
+	}{
+		{
+			name: "branch under another key",
+			entity: func() ast.Entity {
+				e := ast.NewBaseEntity("pipeline", "p")
+				e.SetProperty("route", ast.BranchValue{Condition: ast.VariableValue{Name: "x"}})
+				return e
+			},
+			wantErr: "branch is only valid",
+		},
+		{
+			name: "invalid property name",
+			entity: func() ast.Entity {
+				e := ast.NewBaseEntity("agent", "a")
+				e.SetProperty("not valid", ast.BoolValue{Value: true})
+				return e
+			},
+			wantErr: "not an identifier",
+		},
+		{
+			name: "required parameter with string default",
+			entity: func() ast.Entity {
+				e := ast.NewBaseEntity("tool", "t")
+				e.SetProperty("q", ast.TypedParameterValue{ParamType: "string", Required: true, Default: ast.StringValue{Value: "x"}})
+				return e
+			},
+			wantErr: "string default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Format([]ast.Entity{tt.entity()})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Format() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// assertRoundTrip checks that formatted parses to the same entities as src
+// and that formatting it again changes nothing.
+func assertRoundTrip(t *testing.T, src, formatted string) {
+	t.Helper()
+
+	want, _, err := parser.New(src).Parse()
+	if err != nil {
+		t.Fatalf("parsing source: %v", err)
+	}
+	got, imports, err := parser.New(formatted).Parse()
+	if err != nil {
+		t.Fatalf("parsing formatted output: %v\n%s", err, formatted)
+	}
+	for _, e := range want {
+		clearLocations(e)
+	}
+	for _, e := range got {
+		clearLocations(e)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("formatted output parsed differently:\n%s", formatted)
+	}
+
+	again, err := FormatFile(imports, got)
+	if err != nil {
+		t.Fatalf("formatting again: %v", err)
+	}
+	if again != formatted {
+		t.Errorf("formatting is not idempotent:\nfirst\n%s\nsecond\n%s", formatted, again)
+	}
+}
+
+// clearLocations zeroes source positions on e and every entity nested in it
+// so trees parsed from differently laid out sources can be compared.
+func clearLocations(e ast.Entity) {
+	if e == nil {
+		return
+	}
+	e.SetLocation(0, 0)
+	for _, step := range childSteps(e) {
+		clearLocations(step)
+	}
+	for _, v := range e.Properties() {
+		clearValueLocations(v)
+	}
+}
+
+func clearValueLocations(v ast.Value) {
+	switch v := v.(type) {
+	case ast.NestedEntityValue:
+		clearLocations(v.Entity)
+	case ast.MethodCallValue:
+		clearLocations(v.InlineBody)
+		clearValueLocations(v.Object)
+		for _, arg := range v.Arguments {
+			clearValueLocations(arg)
+		}
+	case ast.FunctionCallValue:
+		for _, arg := range v.Arguments {
+			clearValueLocations(arg)
+		}
+	case ast.ArrayValue:
+		for _, elem := range v.Elements {
+			clearValueLocations(elem)
+		}
+	case ast.ObjectValue:
+		for _, prop := range v.Properties {
+			clearValueLocations(prop)
+		}
+	case ast.BranchValue:
+		for _, c := range v.Cases {
+			clearLocations(c.Entity)
+		}
+	case ast.LoopValue:
+		for _, body := range v.Body {
+			clearLocations(body.Entity)
+		}
+	}
+}
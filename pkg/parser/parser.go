@@ -18,11 +18,32 @@ type ParseError struct {
 	Line    int    // Line number where the error occurred
 	Column  int    // Column number where the error occurred
 	Message string // Error message
+	Snippet string // Source line containing the error, if available
 }
 
-// Error implements the error interface
+// Error implements the error interface. When a snippet is available the
+// offending line is printed beneath the message with a caret under the column.
 func (e ParseError) Error() string {
-	return fmt.Sprintf("at line %d, col %d: %s", e.Line, e.Column, e.Message)
+	msg := fmt.Sprintf("parse error at %d:%d: %s", e.Line, e.Column, e.Message)
+	if e.Snippet == "" {
+		return msg
+	}
+	return msg + "\n" + e.Snippet + "\n" + caretLine(e.Snippet, e.Column)
+}
+
+// caretLine returns a line with a caret under the given 1-based column of
+// snippet, keeping tabs so the caret lines up however tabs are rendered.
+func caretLine(snippet string, column int) string {
+	var b strings.Builder
+	for i := 0; i < column-1 && i < len(snippet); i++ {
+		if snippet[i] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteByte('^')
+	return b.String()
 }
 
 // ParseResult contains the result of parsing, including any recovered errors
@@ -92,7 +113,7 @@ func (p *Parser) WithErrorRecovery() *Parser {
 // current returns the current token
 func (p *Parser) current() tokenizer.Token {
 	if p.pos >= len(p.tokens) {
-		return tokenizer.Token{Type: -1, Value: "", Line: 0, Column: 0}
+		return p.eof()
 	}
 	return p.tokens[p.pos]
 }
@@ -101,11 +122,28 @@ func (p *Parser) current() tokenizer.Token {
 func (p *Parser) peek(offset int) tokenizer.Token {
 	pos := p.pos + offset
 	if pos >= len(p.tokens) || pos < 0 {
-		return tokenizer.Token{Type: -1, Value: "", Line: 0, Column: 0}
+		return p.eof()
 	}
 	return p.tokens[pos]
 }
 
+// eof returns a sentinel token positioned just past the end of the input,
+// so errors about missing tokens point at where they were expected.
+func (p *Parser) eof() tokenizer.Token {
+	line := strings.Count(p.input, "\n") + 1
+	col := len(p.input) - strings.LastIndex(p.input, "\n")
+	return tokenizer.Token{Type: -1, Value: "", Line: line, Column: col}
+}
+
+// withSnippet attaches the source line at err's position to err.
+func (p *Parser) withSnippet(err ParseError) ParseError {
+	lines := strings.Split(p.input, "\n")
+	if err.Line >= 1 && err.Line <= len(lines) {
+		err.Snippet = strings.TrimRight(lines[err.Line-1], "\r")
+	}
+	return err
+}
+
 // advance moves to the next token
 func (p *Parser) advance() {
 	p.pos++
@@ -154,7 +192,7 @@ func (p *Parser) ParseWithRecovery() ParseResult {
 	for p.pos < len(p.tokens) {
 		entity, imp, err := p.parseTopLevel()
 		if err != nil {
-			result.Errors = append(result.Errors, *err)
+			result.Errors = append(result.Errors, p.withSnippet(*err))
 			p.skipToRecoveryPoint()
 			continue
 		}
@@ -455,21 +493,45 @@ func (p *Parser) parsePrimaryValue() (ast.Value, *ParseError) {
 		varName := nameTok.Value
 		p.advance()
 
-		// Check for property access: $name.property.subproperty
-		if p.current().Type == tokenizer.TokenTypeDot {
+		// Check for property access: $name.property.subproperty or $name.items[0]
+		if t := p.current().Type; t == tokenizer.TokenTypeDot || t == tokenizer.TokenTypeLeftBracket {
 			path := make([]string, 0)
-			for p.current().Type == tokenizer.TokenTypeDot {
-				p.advance() // consume dot
-				propTok := p.current()
-				if propTok.Type != tokenizer.TokenTypeIdentifier {
-					return nil, &ParseError{
-						Line:    propTok.Line,
-						Column:  propTok.Column,
-						Message: "expected property name after .",
+			for {
+				switch p.current().Type {
+				case tokenizer.TokenTypeDot:
+					p.advance() // consume dot
+					propTok := p.current()
+					if propTok.Type != tokenizer.TokenTypeIdentifier {
+						return nil, &ParseError{
+							Line:    propTok.Line,
+							Column:  propTok.Column,
+							Message: "expected property name after .",
+						}
+					}
+					path = append(path, propTok.Value)
+					p.advance()
+					continue
+
+				case tokenizer.TokenTypeLeftBracket:
+					p.advance() // consume [
+					indexTok := p.current()
+					if _, err := strconv.Atoi(indexTok.Value); indexTok.Type != tokenizer.TokenTypeNumber || err != nil {
+						return nil, &ParseError{
+							Line:    indexTok.Line,
+							Column:  indexTok.Column,
+							Message: "expected integer index after [",
+						}
+					}
+					p.advance()
+					if _, err := p.expect(tokenizer.TokenTypeRightBracket); err != nil {
+						return nil, err
 					}
+					// Index segments keep their brackets so they can't be
+					// mistaken for object keys
+					path = append(path, "["+indexTok.Value+"]")
+					continue
 				}
-				path = append(path, propTok.Value)
-				p.advance()
+				break
 			}
 			// Return as PropertyAccessValue with $ prefix to indicate variable
 			return ast.PropertyAccessValue{Base: "$" + varName, Path: path}, nil
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -367,6 +369,61 @@ Review the following code for bugs and style issues.
 	}
 }
 
+// TestParser_Parse_StringEscapes tests the decoded value of string literals
+func TestParser_Parse_StringEscapes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "escape_sequences",
+			input: `file "f" { contents: "Step 1\n\tMove \"disk\" \\ peg" }`,
+			want:  "Step 1\n\tMove \"disk\" \\ peg",
+		},
+		{
+			name:  "unknown_escape_kept",
+			input: `file "f" { contents: "C:\dir \d+" }`,
+			want:  `C:\dir \d+`,
+		},
+		{
+			name: "triple_quoted",
+			input: `file "strategy" {
+  contents: """
+Move the smallest disk first.
+Never place a larger disk on a smaller one: "rule 1" \n stays.
+"""
+}`,
+			want: "Move the smallest disk first.\nNever place a larger disk on a smaller one: \"rule 1\" \\n stays.\n",
+		},
+		{
+			name:  "triple_quoted_inline",
+			input: `file "f" { contents: """say "hi" """ }`,
+			want:  `say "hi" `,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := New(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parser.Parse() error = %v", err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("Parser.Parse() got %d entities, want 1", len(got))
+			}
+			contents, _ := got[0].GetProperty("contents")
+			sv, ok := contents.(ast.StringValue)
+			if !ok {
+				t.Fatalf("contents = %T, want ast.StringValue", contents)
+			}
+			if sv.Value != tt.want {
+				t.Errorf("contents = %q, want %q", sv.Value, tt.want)
+			}
+		})
+	}
+}
+
 // TestParser_ParseWithRecovery tests error recovery
 func TestParser_ParseWithRecovery(t *testing.T) {
 	tests := []struct {
@@ -420,15 +477,85 @@ func TestParser_ParseWithRecovery(t *testing.T) {
 }
 
 func TestParseError(t *testing.T) {
-	err := ParseError{
-		Line:    10,
-		Column:  5,
-		Message: "test error",
+	tests := []struct {
+		name string
+		err  ParseError
+		want string
+	}{
+		{
+			name: "without_snippet",
+			err:  ParseError{Line: 10, Column: 5, Message: "test error"},
+			want: "parse error at 10:5: test error",
+		},
+		{
+			name: "with_snippet",
+			err:  ParseError{Line: 2, Column: 9, Message: "expected COLON, got STRING", Snippet: "  model \"gpt-4\""},
+			want: "parse error at 2:9: expected COLON, got STRING\n  model \"gpt-4\"\n        ^",
+		},
+		{
+			name: "tabs_are_preserved",
+			err:  ParseError{Line: 1, Column: 3, Message: "oops", Snippet: "\t\tx"},
+			want: "parse error at 1:3: oops\n\t\tx\n\t\t^",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("ParseError.Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_ErrorPosition(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantLine   int
+		wantColumn int
+		wantInMsg  string
+	}{
+		{
+			name:       "missing_colon",
+			input:      "agent \"a\" {\n  model \"gpt-4\"\n}",
+			wantLine:   2,
+			wantColumn: 9,
+			wantInMsg:  "expected COLON",
+		},
+		{
+			name:       "unclosed_block",
+			input:      "file \"f\" { contents: \"x\" }\nagent \"a\" {\n  model: \"gpt-4\"",
+			wantLine:   2,
+			wantColumn: 1,
+			wantInMsg:  "unclosed",
+		},
+		{
+			name:       "missing_import_path_at_eof",
+			input:      "import",
+			wantLine:   1,
+			wantColumn: 7,
+			wantInMsg:  "expected STRING",
+		},
 	}
 
-	expected := "at line 10, col 5: test error"
-	if err.Error() != expected {
-		t.Errorf("ParseError.Error() = %q, want %q", err.Error(), expected)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := New(tt.input).Parse()
+			var pe ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if pe.Line != tt.wantLine || pe.Column != tt.wantColumn {
+				t.Errorf("position = %d:%d, want %d:%d", pe.Line, pe.Column, tt.wantLine, tt.wantColumn)
+			}
+			if !strings.Contains(pe.Message, tt.wantInMsg) {
+				t.Errorf("message %q does not contain %q", pe.Message, tt.wantInMsg)
+			}
+			if wantSnippet := strings.Split(tt.input, "\n")[tt.wantLine-1]; pe.Snippet != wantSnippet {
+				t.Errorf("Snippet = %q, want %q", pe.Snippet, wantSnippet)
+			}
+		})
 	}
 }
 
@@ -458,7 +585,7 @@ func TestParseResult_ErrorString(t *testing.T) {
 
 	t.Run("single_error", func(t *testing.T) {
 		result := ParseResult{Errors: []ParseError{{Line: 1, Column: 1, Message: "error1"}}}
-		expected := "at line 1, col 1: error1"
+		expected := "parse error at 1:1: error1"
 		if result.ErrorString() != expected {
 			t.Errorf("ErrorString() = %q, want %q", result.ErrorString(), expected)
 		}
@@ -469,7 +596,7 @@ func TestParseResult_ErrorString(t *testing.T) {
 			{Line: 1, Column: 1, Message: "error1"},
 			{Line: 2, Column: 5, Message: "error2"},
 		}}
-		expected := "at line 1, col 1: error1; at line 2, col 5: error2"
+		expected := "parse error at 1:1: error1; parse error at 2:5: error2"
 		if result.ErrorString() != expected {
 			t.Errorf("ErrorString() = %q, want %q", result.ErrorString(), expected)
 		}
@@ -958,6 +1085,36 @@ func TestParser_PropertyAccess(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "variable_path_with_index",
+			input: `intent "test" {
+				value: $state.pegs.A[0]
+			}`,
+			checkFirst: func(t *testing.T, e ast.Entity) {
+				val, _ := e.GetProperty("value")
+				access, ok := val.(ast.PropertyAccessValue)
+				if !ok {
+					t.Fatalf("expected PropertyAccessValue, got %T", val)
+				}
+				want := ast.PropertyAccessValue{Base: "$state", Path: []string{"pegs", "A", "[0]"}}
+				if !reflect.DeepEqual(access, want) {
+					t.Errorf("got %#v, want %#v", access, want)
+				}
+			},
+		},
+		{
+			name: "variable_index_then_property",
+			input: `intent "test" {
+				value: $items[2].name
+			}`,
+			checkFirst: func(t *testing.T, e ast.Entity) {
+				val, _ := e.GetProperty("value")
+				want := ast.PropertyAccessValue{Base: "$items", Path: []string{"[2]", "name"}}
+				if !reflect.DeepEqual(val, want) {
+					t.Errorf("got %#v, want %#v", val, want)
+				}
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -974,3 +1131,165 @@ func TestParser_PropertyAccess(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_Parse_Imports(t *testing.T) {
+	input := `import "common/agents.ls"
+import "prompts.ls"
+
+intent "review" {
+  use: agent("reviewer")
+}
+`
+	entities, imports, err := New(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	if len(entities) != 1 {
+		t.Errorf("expected 1 entity, got %d", len(entities))
+	}
+	if len(imports) != 2 {
+		t.Fatalf("expected 2 imports, got %d", len(imports))
+	}
+
+	want := []ast.Import{
+		{Path: "common/agents.ls", Line: 1, Column: 1},
+		{Path: "prompts.ls", Line: 2, Column: 1},
+	}
+	for i, imp := range imports {
+		if imp != want[i] {
+			t.Errorf("import %d = %+v, want %+v", i, imp, want[i])
+		}
+	}
+}
+
+// clearLocations zeroes source positions on e and its steps so entity trees
+// parsed from differently laid out sources can be compared.
+func clearLocations(e ast.Entity) {
+	e.SetLocation(0, 0)
+	if p, ok := e.(*ast.PipelineEntity); ok {
+		for _, step := range p.Steps {
+			clearLocations(step)
+		}
+	}
+	for _, v := range e.Properties() {
+		if nested, ok := v.(ast.NestedEntityValue); ok {
+			clearLocations(nested.Entity)
+		}
+	}
+}
+
+func TestParser_Parse_Comments(t *testing.T) {
+	plain := `
+pipeline "review" {
+  step "analyze" {
+    use: agent("analyzer")
+    input: $input
+    prompt: "See http://example.com/* for details"
+  }
+  step "report" {
+    use: agent("reporter")
+    input: step("analyze").output
+  }
+  output: step("report").output
+}
+`
+	commented := `
+// Review pipeline
+/* Two steps:
+   analyze, then report */
+pipeline "review" { // opening
+  step "analyze" {
+    use: /* inline */ agent("analyzer")
+    # hash comments still work
+    input: $input // trailing
+    prompt: "See http://example.com/* for details"
+  }
+  /* between steps */
+  step "report" {
+    use: agent("reporter") // the writer
+    input: step("analyze").output
+  }
+  output: step("report").output /* done */
+}
+`
+
+	want, _, err := New(plain).Parse()
+	if err != nil {
+		t.Fatalf("Parse(plain) error: %v", err)
+	}
+	got, _, err := New(commented).Parse()
+	if err != nil {
+		t.Fatalf("Parse(commented) error: %v", err)
+	}
+
+	for _, e := range append(want, got...) {
+		clearLocations(e)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commented source parsed differently:\ngot  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestParser_Parse_ArrayLiterals(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  ast.Value
+	}{
+		{
+			name:  "empty",
+			input: `agent "a" { x: [] }`,
+			want:  ast.ArrayValue{Elements: []ast.Value{}},
+		},
+		{
+			name:  "trailing_comma",
+			input: `agent "a" { x: [1, 2, 3,] }`,
+			want: ast.ArrayValue{Elements: []ast.Value{
+				ast.NumberValue{Value: 1}, ast.NumberValue{Value: 2}, ast.NumberValue{Value: 3},
+			}},
+		},
+		{
+			name:  "newline_separated_mixed_types",
+			input: "agent \"a\" { x: [\n  1\n  \"two\"\n  true\n] }",
+			want: ast.ArrayValue{Elements: []ast.Value{
+				ast.NumberValue{Value: 1}, ast.StringValue{Value: "two"}, ast.BoolValue{Value: true},
+			}},
+		},
+		{
+			name:  "nested",
+			input: `agent "a" { x: [[1, 2], [], [[3]]] }`,
+			want: ast.ArrayValue{Elements: []ast.Value{
+				ast.ArrayValue{Elements: []ast.Value{ast.NumberValue{Value: 1}, ast.NumberValue{Value: 2}}},
+				ast.ArrayValue{Elements: []ast.Value{}},
+				ast.ArrayValue{Elements: []ast.Value{ast.ArrayValue{Elements: []ast.Value{ast.NumberValue{Value: 3}}}}},
+			}},
+		},
+		{
+			name:  "inside_object",
+			input: `agent "a" { x: { A: [1, 2, 3], B: [] } }`,
+			want: ast.ObjectValue{Properties: map[string]ast.Value{
+				"A": ast.ArrayValue{Elements: []ast.Value{
+					ast.NumberValue{Value: 1}, ast.NumberValue{Value: 2}, ast.NumberValue{Value: 3},
+				}},
+				"B": ast.ArrayValue{Elements: []ast.Value{}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entities, _, err := New(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			got, ok := entities[0].GetProperty("x")
+			if !ok {
+				t.Fatal("property x not found")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("x = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package runtime
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// ResponseCache stores completion responses so identical, deterministic
+// requests can be answered without calling the provider again.
+// Implementations must be safe for concurrent use.
+type ResponseCache interface {
+	// Get returns the cached response for key, if any.
+	Get(key string) (*CompletionResponse, bool)
+
+	// Set stores resp under key.
+	Set(key string, resp *CompletionResponse)
+}
+
+// CacheKey returns the cache key for req. It covers everything that shapes
+// the model's output — model, system prompt, messages, temperature, token
+// limit, tools, stop sequences, seed and provider params — so requests that
+// differ only in temperature never share an entry.
+func CacheKey(req *CompletionRequest) string {
+	data, _ := json.Marshal(struct {
+		Model         string                 `json:"model"`
+		SystemPrompt  string                 `json:"system"`
+		Messages      []Message              `json:"messages"`
+		Temperature   float64                `json:"temperature"`
+		MaxTokens     int                    `json:"max_tokens"`
+		Tools         []ToolDefinition       `json:"tools"`
+		StopSequences []string               `json:"stop"`
+		Seed          *int                   `json:"seed"`
+		Params        map[string]interface{} `json:"params"`
+	}{
+		Model:         req.Model,
+		SystemPrompt:  req.SystemPrompt,
+		Messages:      req.Messages,
+		Temperature:   req.Temperature,
+		MaxTokens:     req.MaxTokens,
+		Tools:         req.Tools,
+		StopSequences: req.StopSequences,
+		Seed:          req.Seed,
+		Params:        req.ProviderParams,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// LRUCache is an in-memory ResponseCache that evicts the least recently
+// used entry once it holds capacity entries.
+type LRUCache struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // front is most recently used
+	mu       sync.Mutex
+}
+
+type lruEntry struct {
+	key  string
+	resp *CompletionResponse
+}
+
+// NewLRUCache creates an LRUCache holding at most capacity responses.
+// A capacity below 1 is treated as 1.
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRUCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the cached response for key and marks it as recently used.
+func (c *LRUCache) Get(key string) (*CompletionResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).resp, true
+}
+
+// Set stores resp under key, evicting the least recently used entry if the
+// cache is full.
+func (c *LRUCache) Set(key string, resp *CompletionResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*lruEntry).resp = resp
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, resp: resp})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of cached responses.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,115 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+	c.Set("a", &CompletionResponse{Content: "a"})
+	c.Set("b", &CompletionResponse{Content: "b"})
+
+	// Touch "a" so "b" becomes the eviction candidate
+	if resp, ok := c.Get("a"); !ok || resp.Content != "a" {
+		t.Fatalf("Get(a) = %v, %v", resp, ok)
+	}
+	c.Set("c", &CompletionResponse{Content: "c"})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	base := CompletionRequest{
+		Model:        "gpt-4o",
+		SystemPrompt: "sys",
+		Messages:     []Message{{Role: RoleUser, Content: "hi"}},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *CompletionRequest)
+	}{
+		{"model", func(r *CompletionRequest) { r.Model = "gpt-4o-mini" }},
+		{"system prompt", func(r *CompletionRequest) { r.SystemPrompt = "other" }},
+		{"prompt", func(r *CompletionRequest) { r.Messages = []Message{{Role: RoleUser, Content: "hello"}} }},
+		{"temperature", func(r *CompletionRequest) { r.Temperature = 0.1 }},
+		{"seed", func(r *CompletionRequest) { seed := 7; r.Seed = &seed }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := base
+			tt.modify(&changed)
+			if CacheKey(&base) == CacheKey(&changed) {
+				t.Errorf("changing %s did not change the cache key", tt.name)
+			}
+		})
+	}
+
+	same := base
+	same.Metadata = map[string]string{"run": "2"}
+	if CacheKey(&base) != CacheKey(&same) {
+		t.Error("metadata should not affect the cache key")
+	}
+}
+
+func TestExecute_ResponseCache(t *testing.T) {
+	run := func(t *testing.T, temperature float64) int {
+		t.Helper()
+		source := fmt.Sprintf(`
+agent "a" {
+	model: "mock-model"
+	temperature: %g
+	instruction: "Answer"
+}
+
+intent "ask" {
+	use: agent("a")
+	prompt: "Hello"
+}
+`, temperature)
+		ws := workspace.New()
+		addEntities(t, ws, parseSource(t, source))
+		intent, _ := ws.GetEntityByName("intent", "ask")
+
+		mock := NewMockProvider(WithMockResponses(MockResponse{Content: "cached answer", FinishReason: FinishReasonStop}))
+		rt := New(ws, WithProvider("mock", mock), WithResponseCache(NewLRUCache(8)))
+
+		for i := 0; i < 3; i++ {
+			result, err := rt.Execute(context.Background(), intent)
+			if err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+			if result.Output != "cached answer" {
+				t.Errorf("Output = %v", result.Output)
+			}
+		}
+		return len(mock.GetRequests())
+	}
+
+	t.Run("deterministic requests hit the cache", func(t *testing.T) {
+		if n := run(t, 0); n != 1 {
+			t.Errorf("provider called %d times, want 1", n)
+		}
+	})
+
+	t.Run("sampled requests bypass the cache", func(t *testing.T) {
+		if n := run(t, 0.7); n != 3 {
+			t.Errorf("provider called %d times, want 3", n)
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// Default backoff bounds used when the Config leaves them unset.
+const (
+	defaultBackoffBase = 500 * time.Millisecond
+	defaultBackoffMax  = 30 * time.Second
+)
+
+// APIError is returned by providers when the API responds with a non-success
+// HTTP status.
+type APIError struct {
+	// Provider is the name of the provider that returned the error
+	Provider string
+
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+
+	// Type is the provider's error type (e.g. "overloaded_error"), when the
+	// body carries one
+	Type string
+
+	// Body is the raw response body
+	Body string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("API error (%s): %s", e.Type, e.Body)
+	}
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// retryableErrorTypes are provider error types that indicate a transient
+// failure regardless of the HTTP status they arrive with. Anthropic reports
+// overload as an "error" event inside an otherwise successful stream.
+var retryableErrorTypes = map[string]bool{
+	"overloaded_error": true,
+	"rate_limit_error": true,
+	"api_error":        true,
+	"server_error":     true,
+}
+
+// newAPIError builds an APIError from an error response, extracting the
+// error type from the {"error": {"type": ...}} envelope both Anthropic and
+// OpenAI use.
+func newAPIError(provider string, statusCode int, body []byte) *APIError {
+	var envelope struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &envelope)
+
+	return &APIError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Type:       envelope.Error.Type,
+		Body:       string(body),
+	}
+}
+
+// IsRetryable reports whether err is a transient provider failure worth
+// retrying: request timeouts, rate limiting (429), server errors (5xx),
+// overloaded or rate-limited error types and network-level failures.
+// Authentication and other client errors, as well as context cancellation,
+// are not retryable.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if retryableErrorTypes[apiErr.Type] {
+			return true
+		}
+		switch {
+		case apiErr.StatusCode == http.StatusRequestTimeout,
+			apiErr.StatusCode == http.StatusTooManyRequests,
+			apiErr.StatusCode >= 500:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoffDelay returns the delay before retry number attempt (starting at 1):
+// base doubled per attempt, capped at max, with equal jitter drawn from
+// int64N so concurrent callers spread out instead of retrying in lockstep.
+func backoffDelay(attempt int, base, max time.Duration, int64N func(int64) int64) time.Duration {
+	if base <= 0 {
+		base = defaultBackoffBase
+	}
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	half := d / 2
+	return half + time.Duration(int64N(int64(half)+1))
+}
+
+// runRand is the seeded random source of a single run, shared by the steps
+// it runs concurrently and by the executions nested in it.
+type runRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// newRunRand returns a fresh source for a run, seeded with seed.
+func newRunRand(seed uint64) *runRand {
+	return &runRand{rng: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// int64N returns a random number in [0, n) from the run's seeded source if
+// it has one, and from the global source otherwise.
+func (ec *ExecutionContext) int64N(n int64) int64 {
+	if ec.rng == nil {
+		return rand.Int64N(n)
+	}
+	ec.rng.mu.Lock()
+	defer ec.rng.mu.Unlock()
+	return ec.rng.rng.Int64N(n)
+}
+
+// chooseRunSeed returns the run seed if one is configured, and otherwise a
+// random non-zero seed, so every run has a seed it can be reproduced with.
+func (r *Runtime) chooseRunSeed() uint64 {
+	seed := r.runSeed()
+	for seed == 0 {
+		seed = rand.Uint64()
+	}
+	return seed
+}
+
+// runSeed returns Config.RunSeed, or else the workspace config's run_seed.
+func (r *Runtime) runSeed() uint64 {
+	if r.config.RunSeed != 0 {
+		return r.config.RunSeed
+	}
+	if v, ok := r.workspaceDefault("run_seed"); ok {
+		if nv, ok := v.(ast.NumberValue); ok && nv.Value > 0 {
+			return uint64(nv.Value)
+		}
+	}
+	return 0
+}
+
+// deliveryTracker passes a stream on to a StreamHandler, noting whether any
+// chunk has been delivered.
+type deliveryTracker struct {
+	StreamHandler
+	delivered bool
+}
+
+func (d *deliveryTracker) OnChunk(chunk StreamChunk) {
+	d.delivered = true
+	d.StreamHandler.OnChunk(chunk)
+}
+
+// checkTemperature fails a request whose temperature is above what its
+// provider accepts, as reported by TemperatureLimiter, rather than leaving
+// the provider to reject it.
+func checkTemperature(provider LLMProvider, req *CompletionRequest) error {
+	limiter, ok := provider.(TemperatureLimiter)
+	if !ok {
+		return nil
+	}
+	if limit := limiter.MaxTemperature(); req.Temperature > limit {
+		return fmt.Errorf("temperature %g is above the maximum of %g that %s accepts", req.Temperature, limit, provider.Name())
+	}
+	return nil
+}
+
+// complete sends req to provider, streaming through the context's handler
+// when streaming is enabled, and retries retryable failures with exponential
+// backoff up to Config.MaxRetries times. Each attempt first waits for the
+// provider's rate limiter, if it has one.
+//
+// When a response cache is configured, requests at or below
+// Config.CacheMaxTemperature are answered from it where possible. Cache
+// hits report zero token usage, since no tokens were spent.
+func (r *Runtime) complete(ctx *ExecutionContext, provider LLMProvider, req *CompletionRequest) (*CompletionResponse, error) {
+	if r.cache == nil || req.Temperature > r.config.CacheMaxTemperature {
+		return r.completeWithRetry(ctx, provider, req)
+	}
+
+	key := CacheKey(req)
+	if cached, ok := r.cache.Get(key); ok {
+		resp := *cached
+		resp.Usage = TokenUsage{}
+		r.metrics.observeCacheHit(ctx.pipeline, req.Model)
+		r.requestLogger(ctx, provider, req).Debug("response served from cache")
+		if ctx.Handler != nil && r.config.EnableStreaming {
+			ctx.Handler.OnChunk(StreamChunk{Content: resp.Content, Type: ChunkTypeContent})
+			ctx.Handler.OnComplete(&resp)
+		}
+		return &resp, nil
+	}
+
+	resp, err := r.completeWithRetry(ctx, provider, req)
+	if err == nil {
+		r.cache.Set(key, resp)
+	}
+	return resp, err
+}
+
+func (r *Runtime) completeWithRetry(ctx *ExecutionContext, provider LLMProvider, req *CompletionRequest) (*CompletionResponse, error) {
+	if err := r.checkBudget(ctx); err != nil {
+		return nil, err
+	}
+	if err := r.checkContextWindow(req); err != nil {
+		return nil, err
+	}
+	if err := checkTemperature(provider, req); err != nil {
+		return nil, err
+	}
+
+	logger := r.requestLogger(ctx, provider, req)
+	for attempt := 0; ; attempt++ {
+		if err := r.rateLimiters[provider.Name()].Wait(ctx.Context); err != nil {
+			return nil, err
+		}
+
+		reqLog := logger.With(slog.Int("attempt", attempt+1))
+		reqLog.Debug("sending request", slog.Int("messages", len(req.Messages)), slog.Int("tools", len(req.Tools)))
+		start := time.Now()
+
+		var resp *CompletionResponse
+		var err error
+		var stream *deliveryTracker
+		if ctx.Handler != nil && r.config.EnableStreaming {
+			stream = &deliveryTracker{StreamHandler: ctx.Handler}
+			resp, err = provider.CompleteStream(ctx.Context, req, stream)
+		} else {
+			resp, err = provider.Complete(ctx.Context, req)
+		}
+		if err == nil {
+			r.estimateUsage(req, resp)
+		}
+		r.metrics.observeRequest(ctx.pipeline, req.Model, resp, err)
+
+		if err == nil {
+			reqLog.Debug("received response",
+				slog.Duration("duration", time.Since(start)),
+				slog.String("finish_reason", string(resp.FinishReason)),
+				slog.Int("input_tokens", resp.Usage.InputTokens),
+				slog.Int("output_tokens", resp.Usage.OutputTokens),
+				slog.Int("tool_calls", len(resp.ToolCalls)))
+			ctx.cost.add(r.costModel().Cost(req.Model, resp.Usage))
+			return resp, nil
+		}
+
+		// Once chunks have reached the handler, a retry would stream the
+		// response again after them
+		retryable := IsRetryable(err) && (stream == nil || !stream.delivered)
+		reqLog.Debug("request failed", slog.Duration("duration", time.Since(start)), slog.Bool("retryable", retryable), slog.Any("error", err))
+		if !retryable || attempt >= r.config.MaxRetries || ctx.Context.Err() != nil {
+			return resp, err
+		}
+
+		delay := backoffDelay(attempt+1, r.config.BackoffBase, r.config.BackoffMax, ctx.int64N)
+		reqLog.Info("retrying request", slog.Duration("delay", delay), slog.Any("error", err))
+		r.metrics.observeRetry(ctx.pipeline, req.Model)
+		ctx.EmitProgress(ProgressEvent{
+			Type:    ProgressTypeStep,
+			Message: fmt.Sprintf("Retrying %s request in %s: %v", provider.Name(), delay.Round(time.Millisecond), err),
+			Metadata: map[string]string{
+				"attempt": fmt.Sprintf("%d", attempt+1),
+			},
+		})
+
+		if sleepErr := sleepContext(ctx.Context, delay); sleepErr != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"rate limited", &APIError{StatusCode: 429}, true},
+		{"server error", &APIError{StatusCode: 503}, true},
+		{"request timeout", &APIError{StatusCode: 408}, true},
+		{"unauthorized", &APIError{StatusCode: 401}, false},
+		{"bad request", &APIError{StatusCode: 400}, false},
+		{"overloaded mid-stream", &APIError{Type: "overloaded_error"}, true},
+		{"invalid request type", &APIError{StatusCode: 400, Type: "invalid_request_error"}, false},
+		{"wrapped api error", fmt.Errorf("LLM request failed: %w", &APIError{StatusCode: 502}), true},
+		{"deadline exceeded", context.DeadlineExceeded, true},
+		{"cancelled", context.Canceled, false},
+		{"network error", fmt.Errorf("request failed: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true},
+		{"plain error", errors.New("anthropic API key not set"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	base := 100 * time.Millisecond
+	max := time.Second
+
+	for attempt := 1; attempt <= 6; attempt++ {
+		want := base << (attempt - 1)
+		if want > max {
+			want = max
+		}
+		for i := 0; i < 20; i++ {
+			d := backoffDelay(attempt, base, max, rand.Int64N)
+			if d < want/2 || d > want {
+				t.Fatalf("backoffDelay(%d) = %v, want within [%v, %v]", attempt, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestBackoffDelay_RunSeed(t *testing.T) {
+	// delays draws jitter as a run with the runtime's fresh source would
+	delays := func(rt *Runtime) []time.Duration {
+		ec := &ExecutionContext{rng: newRunRand(rt.chooseRunSeed())}
+		var ds []time.Duration
+		for attempt := 1; attempt <= 5; attempt++ {
+			ds = append(ds, backoffDelay(attempt, 100*time.Millisecond, time.Second, ec.int64N))
+		}
+		return ds
+	}
+	seeded := func(seed uint64) *Runtime {
+		cfg := DefaultConfig()
+		cfg.RunSeed = seed
+		return New(workspace.New(), WithConfig(cfg))
+	}
+
+	if a, b := delays(seeded(42)), delays(seeded(42)); !reflect.DeepEqual(a, b) {
+		t.Errorf("delays with the same seed differ: %v and %v", a, b)
+	}
+	if a, b := delays(seeded(42)), delays(seeded(43)); reflect.DeepEqual(a, b) {
+		t.Errorf("delays with different seeds are the same: %v", a)
+	}
+
+	rt := seeded(42)
+	if a, b := delays(rt), delays(rt); !reflect.DeepEqual(a, b) {
+		t.Errorf("second run of the same runtime gave %v, want %v", b, a)
+	}
+
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, `config { run_seed: 42 }`))
+	if a, b := delays(seeded(42)), delays(New(ws)); !reflect.DeepEqual(a, b) {
+		t.Errorf("workspace run_seed gave %v, want %v", b, a)
+	}
+}
+
+func TestExecute_RetriesTransientProviderErrors(t *testing.T) {
+	source := `
+agent "retry-agent" {
+	model: "mock-model"
+	instruction: "Answer"
+}
+
+intent "retry-intent" {
+	use: agent("retry-agent")
+	prompt: "Hello"
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+	intent, _ := ws.GetEntityByName("intent", "retry-intent")
+
+	newRuntime := func(mock *MockProvider, retries int) *Runtime {
+		cfg := DefaultConfig()
+		cfg.MaxRetries = retries
+		cfg.BackoffBase = time.Millisecond
+		cfg.BackoffMax = 2 * time.Millisecond
+		return New(ws, WithConfig(cfg), WithProvider("mock", mock))
+	}
+
+	t.Run("succeeds after transient failures", func(t *testing.T) {
+		mock := NewMockProvider(WithMockResponses(
+			MockResponse{Error: &APIError{StatusCode: 429, Body: "rate limited"}},
+			MockResponse{Error: &APIError{StatusCode: 529, Body: "overloaded"}},
+			MockResponse{Content: "finally", FinishReason: FinishReasonStop},
+		))
+		result, err := newRuntime(mock, 3).Execute(context.Background(), intent)
+		if err != nil {
+			t.Fatalf("execute error: %v", err)
+		}
+		if result.Output != "finally" {
+			t.Errorf("Output = %v, want finally", result.Output)
+		}
+		if n := len(mock.GetRequests()); n != 3 {
+			t.Errorf("expected 3 attempts, got %d", n)
+		}
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		mock := NewMockProvider(WithMockError(&APIError{StatusCode: 500, Body: "boom"}))
+		_, err := newRuntime(mock, 2).Execute(context.Background(), intent)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if n := len(mock.GetRequests()); n != 3 {
+			t.Errorf("expected 3 attempts, got %d", n)
+		}
+	})
+
+	t.Run("fails fast on non-retryable errors", func(t *testing.T) {
+		mock := NewMockProvider(WithMockError(&APIError{StatusCode: 401, Body: "invalid x-api-key"}))
+		_, err := newRuntime(mock, 3).Execute(context.Background(), intent)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 {
+			t.Fatalf("expected APIError 401, got %v", err)
+		}
+		if n := len(mock.GetRequests()); n != 1 {
+			t.Errorf("expected 1 attempt, got %d", n)
+		}
+	})
+}
+
+// midStreamFailureProvider streams part of its first response and then fails
+// with a retryable error; later requests succeed.
+type midStreamFailureProvider struct {
+	*MockProvider
+	streams int
+}
+
+func (p *midStreamFailureProvider) CompleteStream(ctx context.Context, req *CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	p.streams++
+	if p.streams == 1 {
+		handler.OnChunk(StreamChunk{Content: "partial", Type: ChunkTypeContent})
+		return nil, &APIError{StatusCode: 503, Body: "connection reset"}
+	}
+	return p.MockProvider.CompleteStream(ctx, req, handler)
+}
+
+func TestExecute_NoRetryAfterStreamedChunks(t *testing.T) {
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, `
+agent "a" {
+	model: "mock-model"
+}
+
+intent "i" {
+	use: agent("a")
+	prompt: "Hello"
+}
+`))
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 3
+	cfg.BackoffBase = time.Millisecond
+	cfg.BackoffMax = time.Millisecond
+	provider := &midStreamFailureProvider{MockProvider: NewMockProvider(WithMockResponses(MockResponse{Content: "full answer"}))}
+	rt := New(ws, WithConfig(cfg), WithProvider("mock", provider))
+
+	var chunks []string
+	handler := &CallbackStreamHandler{ChunkFunc: func(c StreamChunk) {
+		chunks = append(chunks, c.Content)
+	}}
+	_, err := rt.ExecuteByName(context.Background(), "intent", "i", WithStreamHandler(handler))
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 503 {
+		t.Fatalf("execute error = %v, want the mid-stream failure", err)
+	}
+	if provider.streams != 1 {
+		t.Errorf("got %d stream attempts, want no retry once a chunk was delivered", provider.streams)
+	}
+	if len(chunks) != 1 || chunks[0] != "partial" {
+		t.Errorf("chunks = %q, want only the partial chunk", chunks)
+	}
+}
+
+func TestExecute_Logging(t *testing.T) {
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, `
+agent "a" {
+	model: "mock-model"
+}
+
+pipeline "p" {
+	step "ask" {
+		use: agent("a")
+	}
+}
+`))
+	mock := NewMockProvider(WithMockResponses(
+		MockResponse{Error: &APIError{StatusCode: 429, Body: "rate limited"}},
+		MockResponse{Content: "ok", Usage: TokenUsage{InputTokens: 3, OutputTokens: 1, TotalTokens: 4}},
+	))
+	cfg := DefaultConfig()
+	cfg.BackoffBase = time.Millisecond
+	cfg.BackoffMax = time.Millisecond
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	rt := New(ws, WithConfig(cfg), WithProvider("mock", mock), WithLogger(logger))
+
+	if _, err := rt.ExecuteByName(context.Background(), "pipeline", "p"); err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+
+	want := []struct {
+		msg     string
+		attempt float64
+	}{
+		{"sending request", 1},
+		{"request failed", 1},
+		{"retrying request", 1},
+		{"sending request", 2},
+		{"received response", 2},
+	}
+	dec := json.NewDecoder(&buf)
+	for i, w := range want {
+		var record map[string]interface{}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("log record %d: %v", i, err)
+		}
+		if record["msg"] != w.msg || record["attempt"] != w.attempt {
+			t.Errorf("log record %d = %v %v, want %q attempt %v", i, record["msg"], record["attempt"], w.msg, w.attempt)
+		}
+		for key, value := range map[string]string{"provider": "mock", "model": "mock-model", "pipeline": "p", "step": "ask"} {
+			if record[key] != value {
+				t.Errorf("log record %d: %s = %v, want %q", i, key, record[key], value)
+			}
+		}
+		switch w.msg {
+		case "request failed":
+			if record["retryable"] != true {
+				t.Errorf("log record %d: retryable = %v, want true", i, record["retryable"])
+			}
+		case "received response":
+			if record["output_tokens"] != float64(1) {
+				t.Errorf("log record %d: output_tokens = %v, want 1", i, record["output_tokens"])
+			}
+		}
+	}
+	if dec.More() {
+		t.Errorf("unexpected further log records: %s", buf.String())
+	}
+}
+
+func TestExecute_ProviderTemperatureLimit(t *testing.T) {
+	tests := []struct {
+		temperature string
+		wantErr     bool
+	}{
+		{"1", false},
+		{"1.5", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.temperature, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				fmt.Fprint(w, `{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+			}))
+			defer server.Close()
+
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, fmt.Sprintf(`
+agent "a" {
+	model: "claude-sonnet-4-20250514"
+}
+
+pipeline "p" {
+	step "hot" {
+		use: agent("a")
+		temperature: %s
+	}
+}
+`, tt.temperature)))
+			provider := NewAnthropicProvider(WithAnthropicAPIKey("test-key"), WithAnthropicBaseURL(server.URL))
+			rt := New(ws, WithProvider("anthropic", provider))
+
+			_, err := rt.ExecuteByName(context.Background(), "pipeline", "p")
+			if !tt.wantErr {
+				if err != nil || requests != 1 {
+					t.Fatalf("execute error = %v after %d requests, want one successful request", err, requests)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "above the maximum of 1 that anthropic accepts") {
+				t.Fatalf("execute error = %v, want the temperature rejected", err)
+			}
+			if requests != 0 {
+				t.Errorf("sent %d requests, want none", requests)
+			}
+		})
+	}
+}
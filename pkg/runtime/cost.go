@@ -0,0 +1,120 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrBudgetExceeded is returned (wrapped) when a run has spent
+// Config.MaxCostUSD and another provider request would be made.
+var ErrBudgetExceeded = errors.New("cost budget exceeded")
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+// CostModel maps model names to prices. A model without an exact entry
+// uses the longest key that prefixes it, so "claude-sonnet-4" also prices
+// "claude-sonnet-4-20250514".
+type CostModel map[string]ModelPrice
+
+// DefaultCostModel returns list prices for commonly used models. Prices
+// change; set Config.CostModel to override them.
+func DefaultCostModel() CostModel {
+	return CostModel{
+		"claude-opus-4":     {InputPerMTok: 15, OutputPerMTok: 75},
+		"claude-sonnet-4":   {InputPerMTok: 3, OutputPerMTok: 15},
+		"claude-3-5-sonnet": {InputPerMTok: 3, OutputPerMTok: 15},
+		"claude-3-5-haiku":  {InputPerMTok: 0.8, OutputPerMTok: 4},
+		"claude-3-opus":     {InputPerMTok: 15, OutputPerMTok: 75},
+		"claude-3-haiku":    {InputPerMTok: 0.25, OutputPerMTok: 1.25},
+		"gpt-4o":            {InputPerMTok: 2.5, OutputPerMTok: 10},
+		"gpt-4o-mini":       {InputPerMTok: 0.15, OutputPerMTok: 0.6},
+		"o1":                {InputPerMTok: 15, OutputPerMTok: 60},
+		"o3-mini":           {InputPerMTok: 1.1, OutputPerMTok: 4.4},
+		OllamaModelPrefix:   {},
+	}
+}
+
+// Price returns the price for model, if known.
+func (c CostModel) Price(model string) (ModelPrice, bool) {
+	if p, ok := c[model]; ok {
+		return p, true
+	}
+
+	best := ""
+	for name := range c {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return c[best], true
+}
+
+// Cost returns the cost in USD of usage on model, or 0 if the model has no
+// known price.
+func (c CostModel) Cost(model string, usage TokenUsage) float64 {
+	p, ok := c.Price(model)
+	if !ok {
+		return 0
+	}
+	return (float64(usage.InputTokens)*p.InputPerMTok + float64(usage.OutputTokens)*p.OutputPerMTok) / 1e6
+}
+
+// costTracker accumulates spend across a run, including nested executions.
+// A nil tracker ignores updates and reports zero.
+type costTracker struct {
+	mu    sync.Mutex
+	spent float64
+}
+
+func (t *costTracker) add(usd float64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spent += usd
+}
+
+func (t *costTracker) total() float64 {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.spent
+}
+
+// costModel returns the configured cost model, or the defaults.
+func (r *Runtime) costModel() CostModel {
+	if r.config.CostModel != nil {
+		return r.config.CostModel
+	}
+	return DefaultCostModel()
+}
+
+// checkBudget returns ErrBudgetExceeded once the run's spend has reached
+// Config.MaxCostUSD, naming the pipeline step the run reached, if any. The
+// request that crosses the budget is allowed to complete; no further
+// requests are made.
+func (r *Runtime) checkBudget(ctx *ExecutionContext) error {
+	if r.config.MaxCostUSD <= 0 {
+		return nil
+	}
+	spent := ctx.cost.total()
+	if spent < r.config.MaxCostUSD {
+		return nil
+	}
+	if ctx.step != "" {
+		return fmt.Errorf("%w: spent $%.4f of $%.4f before step %q", ErrBudgetExceeded, spent, r.config.MaxCostUSD, ctx.step)
+	}
+	return fmt.Errorf("%w: spent $%.4f of $%.4f", ErrBudgetExceeded, spent, r.config.MaxCostUSD)
+}
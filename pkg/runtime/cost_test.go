@@ -0,0 +1,91 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+func TestCostModel_Cost(t *testing.T) {
+	costs := CostModel{
+		"gpt-4o":      {InputPerMTok: 2.5, OutputPerMTok: 10},
+		"gpt-4o-mini": {InputPerMTok: 0.15, OutputPerMTok: 0.6},
+	}
+	usage := TokenUsage{InputTokens: 2_000_000, OutputTokens: 500_000}
+
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		{"gpt-4o", 10},
+		{"gpt-4o-2024-08-06", 10},
+		{"gpt-4o-mini-2024-07-18", 0.6},
+		{"unknown", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := costs.Cost(tt.model, usage); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Cost(%s) = %v, want %v", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecute_PipelineBudget(t *testing.T) {
+	source := `
+agent "worker" {
+	model: "mock-model"
+	instruction: "Work"
+}
+
+pipeline "expensive" {
+	step "one" {
+		use: agent("worker")
+		input: "a"
+	}
+	step "two" {
+		use: agent("worker")
+		input: "b"
+	}
+	step "three" {
+		use: agent("worker")
+		input: "c"
+	}
+}
+`
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, source))
+	pipeline, _ := ws.GetEntityByName("pipeline", "expensive")
+
+	mock := NewMockProvider(WithMockResponses(MockResponse{
+		Content:      "done",
+		FinishReason: FinishReasonStop,
+		Usage:        TokenUsage{InputTokens: 1_000_000, TotalTokens: 1_000_000},
+	}))
+
+	cfg := DefaultConfig()
+	cfg.CostModel = CostModel{"mock": {InputPerMTok: 1}}
+	cfg.MaxCostUSD = 1.5
+	rt := New(ws, WithConfig(cfg), WithProvider("mock", mock))
+
+	result, err := rt.Execute(context.Background(), pipeline)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	for _, want := range []string{"step 3 of 3", `spent $2.0000 of $1.5000 before step "three"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should report the step reached (%s): %v", want, err)
+		}
+	}
+	if n := len(mock.GetRequests()); n != 2 {
+		t.Errorf("provider called %d times, want 2", n)
+	}
+	if math.Abs(result.CostUSD-2) > 1e-9 {
+		t.Errorf("CostUSD = %v, want 2", result.CostUSD)
+	}
+}
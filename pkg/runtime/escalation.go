@@ -0,0 +1,133 @@
+package runtime
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrNotEscalated is returned by an EscalationHandler that declines to
+// answer, leaving the step failed with its original error.
+var ErrNotEscalated = errors.New("escalation declined")
+
+// Escalation describes a pipeline step that failed and is handed to a person
+// to decide its output.
+type Escalation struct {
+	// Pipeline is the name of the pipeline the step belongs to
+	Pipeline string
+
+	// Step is the name of the failed step
+	Step string
+
+	// Err is the error the step failed with
+	Err error
+}
+
+// EscalationHandler is asked for the output of a failed pipeline step. The
+// output it returns is used as the step's output and the pipeline carries
+// on; returning an error, such as ErrNotEscalated, fails the step as before.
+type EscalationHandler func(ctx context.Context, e Escalation) (string, error)
+
+// WithEscalationHandler hands failed pipeline steps to h instead of failing
+// the pipeline outright. Steps stopped because the run was cancelled, timed
+// out or spent its cost budget are not escalated.
+func WithEscalationHandler(h EscalationHandler) Option {
+	return func(r *Runtime) {
+		r.escalate = h
+	}
+}
+
+// NoEscalation is an EscalationHandler that declines every escalation. It
+// behaves as if no handler were set.
+func NoEscalation(context.Context, Escalation) (string, error) {
+	return "", ErrNotEscalated
+}
+
+// StdinEscalationHandler asks for the output of a failed step on out and
+// reads it from in, one line per step. An empty line or the end of input
+// declines the escalation. Escalations from steps running in parallel are
+// asked one at a time.
+func StdinEscalationHandler(in io.Reader, out io.Writer) EscalationHandler {
+	type answer struct {
+		line string
+		err  error
+	}
+
+	var mu sync.Mutex
+	reader := bufio.NewReader(in)
+	// pending is a read left waiting by an escalation that was cancelled;
+	// the next escalation takes its answer rather than reading concurrently
+	var pending chan answer
+
+	return func(ctx context.Context, e Escalation) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if _, err := fmt.Fprintf(out, "Step %q of pipeline %q failed: %v\nEnter its output (empty to fail the step): ", e.Step, e.Pipeline, e.Err); err != nil {
+			return "", err
+		}
+
+		if pending == nil {
+			pending = make(chan answer, 1)
+			go func(read chan<- answer) {
+				line, err := reader.ReadString('\n')
+				read <- answer{line, err}
+			}(pending)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case a := <-pending:
+			pending = nil
+			line := strings.TrimRight(a.line, "\r\n")
+			if line == "" {
+				if a.err != nil && a.err != io.EOF {
+					return "", a.err
+				}
+				return "", ErrNotEscalated
+			}
+			return line, nil
+		}
+	}
+}
+
+// escalateStep hands a failed step to the runtime's escalation handler, if
+// there is one, and on an answer marks stepResult as succeeded with it. It
+// returns the error the step should finish with: nil once escalated,
+// otherwise err unchanged.
+func (r *Runtime) escalateStep(ctx *ExecutionContext, stepResult *StepResult, err error) error {
+	if r.escalate == nil || ctx.Context.Err() != nil || errors.Is(err, ErrBudgetExceeded) {
+		return err
+	}
+
+	ctx.EmitProgress(ProgressEvent{
+		Type:    ProgressTypeEscalation,
+		Message: fmt.Sprintf("Escalating failed step: %s", stepResult.Name),
+		Step:    stepResult.Name,
+		Metadata: map[string]string{
+			"error": err.Error(),
+		},
+	})
+
+	output, escErr := r.escalate(ctx.Context, Escalation{
+		Pipeline: ctx.pipeline,
+		Step:     stepResult.Name,
+		Err:      err,
+	})
+	if escErr != nil {
+		return err
+	}
+
+	stepResult.Success = true
+	stepResult.Escalated = true
+	stepResult.Error = nil
+	stepResult.Output = output
+	ctx.SetStepOutput(stepResult.Name, output)
+	ctx.SetStepOutput(stepResult.Name+".output", output)
+	return nil
+}
@@ -0,0 +1,161 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+func TestExecute_Escalation(t *testing.T) {
+	source := `
+agent "solver" {
+	model: "mock-model"
+}
+
+pipeline "hanoi" {
+	step "move" {
+		use: agent("solver")
+		input: "move disk 3"
+	}
+	step "check" {
+		use: agent("solver")
+		input: step("move").output
+	}
+}
+`
+	tests := []struct {
+		name    string
+		handler EscalationHandler
+		wantErr bool
+	}{
+		{
+			name: "answer used as output",
+			handler: func(_ context.Context, e Escalation) (string, error) {
+				if e.Pipeline != "hanoi" || e.Step != "move" || e.Err == nil {
+					t.Errorf("unexpected escalation: %+v", e)
+				}
+				return "disk 3 from A to C", nil
+			},
+		},
+		{name: "declined", handler: NoEscalation, wantErr: true},
+		{name: "no handler", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, source))
+
+			mockProvider := NewMockProvider(WithMockResponses(
+				MockResponse{Error: errors.New("malformed move")},
+				MockResponse{Content: "valid"},
+			))
+			opts := []Option{WithProvider("mock", mockProvider)}
+			if tt.handler != nil {
+				opts = append(opts, WithEscalationHandler(tt.handler))
+			}
+			rt := New(ws, opts...)
+
+			var escalations int
+			handler := &CallbackStreamHandler{ProgressFunc: func(e ProgressEvent) {
+				if e.Type == ProgressTypeEscalation {
+					escalations++
+				}
+			}}
+			result, err := rt.ExecuteByName(context.Background(), "pipeline", "hanoi", WithStreamHandler(handler))
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "malformed move") {
+					t.Fatalf("error = %v, want the step's own error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+			if move := result.StepResults["move"]; !move.Success || !move.Escalated || move.Output != "disk 3 from A to C" {
+				t.Errorf("move result = %+v, want the escalated answer", move)
+			}
+			if escalations != 1 {
+				t.Errorf("got %d escalation events, want 1", escalations)
+			}
+			if last := mockProvider.LastRequest(); !strings.Contains(last.Messages[0].Content, "disk 3 from A to C") {
+				t.Errorf("next step prompt = %q, want the escalated output", last.Messages[0].Content)
+			}
+		})
+	}
+}
+
+func TestExecute_BudgetStopNotEscalated(t *testing.T) {
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, `
+agent "worker" {
+	model: "mock-model"
+}
+
+pipeline "p" {
+	step "one" {
+		use: agent("worker")
+	}
+	step "two" {
+		use: agent("worker")
+	}
+}
+`))
+	mock := NewMockProvider(WithMockResponses(MockResponse{
+		Content: "done",
+		Usage:   TokenUsage{InputTokens: 1_000_000, TotalTokens: 1_000_000},
+	}))
+	cfg := DefaultConfig()
+	cfg.CostModel = CostModel{"mock": {InputPerMTok: 1}}
+	cfg.MaxCostUSD = 0.5
+
+	var escalations int
+	rt := New(ws, WithConfig(cfg), WithProvider("mock", mock), WithEscalationHandler(func(context.Context, Escalation) (string, error) {
+		escalations++
+		return "answer", nil
+	}))
+
+	_, err := rt.ExecuteByName(context.Background(), "pipeline", "p")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("execute error = %v, want ErrBudgetExceeded", err)
+	}
+	if escalations != 0 {
+		t.Errorf("got %d escalations, want none for a step stopped by the budget", escalations)
+	}
+}
+
+func TestStdinEscalationHandler(t *testing.T) {
+	var out strings.Builder
+	h := StdinEscalationHandler(strings.NewReader("disk 1 from A to B\n\n"), &out)
+	e := Escalation{Pipeline: "hanoi", Step: "move", Err: errors.New("no consensus")}
+
+	got, err := h(context.Background(), e)
+	if err != nil || got != "disk 1 from A to B" {
+		t.Errorf("first answer = %q, %v; want the first line", got, err)
+	}
+	if !strings.Contains(out.String(), `Step "move" of pipeline "hanoi" failed: no consensus`) {
+		t.Errorf("prompt = %q", out.String())
+	}
+
+	for _, want := range []string{"empty line", "end of input"} {
+		if _, err := h(context.Background(), e); !errors.Is(err, ErrNotEscalated) {
+			t.Errorf("%s: error = %v, want ErrNotEscalated", want, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	blocked := StdinEscalationHandler(blockingReader{}, &out)
+	if _, err := blocked(ctx, e); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: error = %v, want context.Canceled", err)
+	}
+}
+
+// blockingReader never returns, like a terminal nobody types into.
+type blockingReader struct{}
+
+func (blockingReader) Read([]byte) (int, error) { select {} }
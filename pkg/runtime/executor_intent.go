@@ -2,6 +2,7 @@ package runtime
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -10,6 +11,13 @@ import (
 
 // executeIntent executes an intent entity.
 func (r *Runtime) executeIntent(ctx *ExecutionContext, entity ast.Entity) (*ExecutionResult, error) {
+	// An intent may delegate to a pipeline instead of prompting an agent
+	if use, ok := entity.GetProperty("use"); ok {
+		if ref, ok := use.(ast.ReferenceValue); ok && ref.Type == "pipeline" {
+			return r.executeIntentPipeline(ctx, entity, ref.Name)
+		}
+	}
+
 	result := &ExecutionResult{
 		Metadata: make(map[string]string),
 	}
@@ -73,79 +81,34 @@ func (r *Runtime) executeIntent(ctx *ExecutionContext, entity ast.Entity) (*Exec
 		{Role: RoleUser, Content: prompt},
 	}
 
-	// Loop for tool execution
-	maxTurns := 10
-	for turn := 0; turn < maxTurns; turn++ {
-		// Build the request
-		req := &CompletionRequest{
-			Model:        model,
-			SystemPrompt: systemPrompt,
-			Messages:     messages,
-			Temperature:  temperature,
-			Tools:        tools,
-		}
-
-		// Execute the LLM call
-		var resp *CompletionResponse
-		var lastResp *CompletionResponse
-		if ctx.Handler != nil && r.config.EnableStreaming {
-			resp, err = provider.CompleteStream(ctx.Context, req, ctx.Handler)
-		} else {
-			resp, err = provider.Complete(ctx.Context, req)
-		}
-
-		if err != nil {
-			result.Error = fmt.Errorf("LLM request failed: %w", err)
-			ctx.EmitProgress(ProgressEvent{
-				Type:    ProgressTypeError,
-				Message: err.Error(),
-			})
-			return result, result.Error
-		}
-
-		lastResp = resp
-		// Update token usage
-		result.TokensUsed.Add(resp.Usage)
-
-		// Add assistant message to history
-		assistantMsg := Message{
-			Role:      RoleAssistant,
-			Content:   resp.Content,
-			ToolCalls: resp.ToolCalls,
-		}
-		messages = append(messages, assistantMsg)
-
-		// If no tool calls, we're done
-		if len(resp.ToolCalls) == 0 || resp.FinishReason != FinishReasonToolUse {
-			result.Output = resp.Content
-			result.Metadata["finish_reason"] = string(resp.FinishReason)
-			break
-		}
-
-		// Execute tool calls
-		for _, tc := range resp.ToolCalls {
-			ctx.EmitProgress(ProgressEvent{
-				Type:    ProgressTypeStep,
-				Message: fmt.Sprintf("Executing tool: %s", tc.Name),
-				Metadata: map[string]string{
-					"tool": tc.Name,
-				},
-			})
+	req := &CompletionRequest{
+		Model:         model,
+		SystemPrompt:  systemPrompt,
+		Messages:      messages,
+		Temperature:   temperature,
+		MaxTokens:     r.getAgentMaxTokens(agent),
+		Tools:         tools,
+		StopSequences: getStopSequences(agent),
+		Seed:          getSeed(agent),
 
-			toolResult, err := r.executeToolCall(ctx, tc, resolver)
-			if err != nil {
-				// We report the error back to the LLM so it can try to fix it
-				toolResult = fmt.Sprintf("Error: %v", err)
-			}
+		ProviderParams: getProviderParams(agent),
+	}
 
-			// Add tool result to history
-			messages = append(messages, Message{
-				Role:       RoleTool,
-				Content:    toString(toolResult),
-				ToolCallID: tc.ID,
-			})
-		}
-		_ = lastResp // Suppress unused warning, kept for future metadata access
+	// Execute the LLM call, running any tools the model asks for
+	resp, usage, err := r.completeWithTools(ctx, provider, req, resolver)
+	result.TokensUsed.Add(usage)
+	if err != nil {
+		result.Error = fmt.Errorf("LLM request failed: %w", err)
+		ctx.EmitProgress(ProgressEvent{
+			Type:    ProgressTypeError,
+			Message: err.Error(),
+		})
+		return result, result.Error
+	}
+	result.Output = resp.Content
+	result.Metadata["finish_reason"] = string(resp.FinishReason)
+	if resp.SystemFingerprint != "" {
+		result.Metadata["system_fingerprint"] = resp.SystemFingerprint
 	}
 
 	// Store the output
@@ -175,6 +138,77 @@ func (r *Runtime) executeIntent(ctx *ExecutionContext, entity ast.Entity) (*Exec
 	return result, nil
 }
 
+// executeIntentPipeline runs the pipeline an intent uses, with the intent's
+// input merged into the pipeline's $input (see mergeInput for precedence), and
+// writes the pipeline's output to the intent's output destination.
+func (r *Runtime) executeIntentPipeline(ctx *ExecutionContext, intent ast.Entity, name string) (*ExecutionResult, error) {
+	resolver := NewResolver(ctx)
+
+	pipeline, err := resolver.workspace.GetPipeline(name)
+	if err != nil {
+		err = fmt.Errorf("intent %q: %w", intent.Name(), err)
+		return &ExecutionResult{Error: err}, err
+	}
+
+	if inputProp, ok := intent.GetProperty("input"); ok {
+		input, err := resolver.Resolve(inputProp)
+		if err != nil {
+			err = fmt.Errorf("intent %q: failed to resolve input: %w", intent.Name(), err)
+			return &ExecutionResult{Error: err}, err
+		}
+		callerInput, _ := ctx.GetVariable("input")
+		ctx.SetVariable("input", mergeInput(input, callerInput))
+	}
+
+	result, err := r.executePipeline(ctx, pipeline)
+	if err != nil {
+		return result, err
+	}
+
+	result.Metadata["intent"] = intent.Name()
+	result.Metadata["pipeline"] = pipeline.Name()
+
+	if result.Output != nil {
+		if err := r.handleIntentOutput(ctx, intent, toString(result.Output), resolver); err != nil {
+			result.Success = false
+			result.Error = fmt.Errorf("failed to handle output: %w", err)
+			return result, result.Error
+		}
+	}
+
+	return result, nil
+}
+
+// mergeInput layers override on top of base. Inputs are merged from the
+// lowest to the highest precedence:
+//
+//  1. the pipeline's own input property (defaults)
+//  2. the input of an intent that uses the pipeline
+//  3. input passed by the caller, e.g. WithInput or the CLI's -input flag
+//
+// When both values are objects their keys are merged, with override's value
+// winning for keys present in both; nested objects are replaced, not merged.
+// Otherwise override, if set, replaces base entirely.
+func mergeInput(base, override interface{}) interface{} {
+	if override == nil {
+		return base
+	}
+	baseMap, baseOK := base.(map[string]interface{})
+	overrideMap, overrideOK := override.(map[string]interface{})
+	if !baseOK || !overrideOK {
+		return override
+	}
+
+	merged := make(map[string]interface{}, len(baseMap)+len(overrideMap))
+	for k, v := range baseMap {
+		merged[k] = v
+	}
+	for k, v := range overrideMap {
+		merged[k] = v
+	}
+	return merged
+}
+
 // getAgentTools extracts tool definitions from an agent.
 func (r *Runtime) getAgentTools(ctx *ExecutionContext, agent ast.Entity, resolver *Resolver) ([]ToolDefinition, error) {
 	toolsProp, ok := agent.GetProperty("tools")
@@ -198,6 +232,11 @@ func (r *Runtime) getAgentTools(ctx *ExecutionContext, agent ast.Entity, resolve
 
 	var definitions []ToolDefinition
 	for _, name := range toolNames {
+		if tool, ok := r.getTool(name); ok {
+			definitions = append(definitions, toolDefinition(tool))
+			continue
+		}
+
 		// Check if it's an MCP server reference
 		if mcpEntity, err := resolver.workspace.GetMCP(name); err == nil {
 			client, err := r.getMCPClient(mcpEntity.Name())
@@ -210,12 +249,14 @@ func (r *Runtime) getAgentTools(ctx *ExecutionContext, agent ast.Entity, resolve
 			}
 
 			// Track which tools belong to this MCP server
+			unlock := ctx.lock()
 			if ctx.MCPTools == nil {
 				ctx.MCPTools = make(map[string]string)
 			}
 			for _, t := range mcpTools {
 				ctx.MCPTools[t.Name] = mcpEntity.Name()
 			}
+			unlock()
 
 			definitions = append(definitions, mcpTools...)
 			continue
@@ -256,8 +297,15 @@ func (r *Runtime) getAgentTools(ctx *ExecutionContext, agent ast.Entity, resolve
 
 // executeToolCall executes a single tool call from the LLM.
 func (r *Runtime) executeToolCall(ctx *ExecutionContext, tc ToolCall, resolver *Resolver) (interface{}, error) {
+	if tool, ok := r.getTool(tc.Name); ok {
+		return tool.Invoke(tc.Arguments)
+	}
+
 	// Check if it's an MCP tool
-	if mcpServer, ok := ctx.MCPTools[tc.Name]; ok {
+	unlock := ctx.rlock()
+	mcpServer, ok := ctx.MCPTools[tc.Name]
+	unlock()
+	if ok {
 		return r.executeMCPTool(ctx, mcpServer, tc.Name, tc.Arguments)
 	}
 
@@ -336,7 +384,7 @@ func (r *Runtime) buildIntentPrompt(ctx *ExecutionContext, entity ast.Entity, re
 		}
 	} else if input, ok := ctx.GetVariable("input"); ok {
 		// Use input from execution context
-		promptParts = append(promptParts, "## Input\n\n"+toString(input))
+		promptParts = append(promptParts, "## Input\n\n"+formatContent(input))
 	}
 
 	// Get context
@@ -414,9 +462,16 @@ func formatContent(content interface{}) string {
 		return strings.Join(parts, "\n\n")
 
 	case map[string]interface{}:
-		var parts []string
-		for k, val := range v {
-			parts = append(parts, fmt.Sprintf("**%s**: %s", k, formatContent(val)))
+		// Sort the keys so the same value always gives the same prompt,
+		// which keeps responses cacheable and runs reproducible
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("**%s**: %s", k, formatContent(v[k])))
 		}
 		return strings.Join(parts, "\n")
 
@@ -454,32 +509,150 @@ func (r *Runtime) getAgentSystemPrompt(agent ast.Entity, resolver *Resolver) (st
 		return resolver.ResolveString(prompt)
 	}
 
+	if instruction, ok := r.workspaceDefault("default_instruction"); ok {
+		return resolver.ResolveString(instruction)
+	}
+
 	// Default system prompt based on agent name
 	return fmt.Sprintf("You are %s. Help the user with their request.", agent.Name()), nil
 }
 
-// getAgentModel gets the model to use for an agent.
+// getAgentModel gets the model to use for an agent: its own model property,
+// else the workspace config's default_model, else the runtime default.
 func (r *Runtime) getAgentModel(agent ast.Entity) string {
 	if model, ok := agent.GetProperty("model"); ok {
 		if sv, ok := model.(ast.StringValue); ok {
 			return sv.Value
 		}
 	}
+	if model, ok := r.workspaceDefault("default_model"); ok {
+		if sv, ok := model.(ast.StringValue); ok && sv.Value != "" {
+			return sv.Value
+		}
+	}
 	return r.defaultModel
 }
 
-// getAgentTemperature gets the temperature setting for an agent.
+// getAgentTemperature gets the temperature setting for an agent, falling back
+// to the workspace config's default_temperature.
 func (r *Runtime) getAgentTemperature(agent ast.Entity) float64 {
 	if temp, ok := agent.GetProperty("temperature"); ok {
 		if nv, ok := temp.(ast.NumberValue); ok {
 			return nv.Value
 		}
 	}
+	if temp, ok := r.workspaceDefault("default_temperature"); ok {
+		if nv, ok := temp.(ast.NumberValue); ok {
+			return nv.Value
+		}
+	}
 	return 0.7 // Default temperature
 }
 
-// getProviderForModel returns the appropriate provider for a model.
+// getAgentMaxTokens gets an agent's max_tokens setting, or 0 to leave the
+// limit to the provider.
+func (r *Runtime) getAgentMaxTokens(agent ast.Entity) int {
+	if v, ok := agent.GetProperty("max_tokens"); ok {
+		if nv, ok := v.(ast.NumberValue); ok {
+			return int(nv.Value)
+		}
+	}
+	return 0
+}
+
+// getStopSequences gets the sequences that end an agent's responses: its stop
+// property, written as a single string or an array of strings.
+func getStopSequences(agent ast.Entity) []string {
+	if v, ok := agent.GetProperty("stop"); ok {
+		return stopSequences(v)
+	}
+	return nil
+}
+
+// getSeed gets the sampling seed an agent or step sets with its seed
+// property, or nil to leave sampling unseeded.
+func getSeed(entity ast.Entity) *int {
+	if v, ok := entity.GetProperty("seed"); ok {
+		if nv, ok := v.(ast.NumberValue); ok {
+			seed := int(nv.Value)
+			return &seed
+		}
+	}
+	return nil
+}
+
+// getProviderParams collects the provider params (top_p, top_k and so on)
+// set as number properties of the given entities, later entities overriding
+// earlier ones, so a step's setting wins over its agent's. It returns nil if
+// none are set.
+func getProviderParams(entities ...ast.Entity) map[string]interface{} {
+	var params map[string]interface{}
+	for _, entity := range entities {
+		for _, key := range providerParamNames {
+			v, ok := entity.GetProperty(key)
+			if !ok {
+				continue
+			}
+			if nv, ok := v.(ast.NumberValue); ok {
+				if params == nil {
+					params = make(map[string]interface{})
+				}
+				params[key] = nv.Value
+			}
+		}
+	}
+	return params
+}
+
+// stopSequences converts a stop property value to a list of sequences,
+// skipping anything that isn't a non-empty string.
+func stopSequences(v ast.Value) []string {
+	var elems []ast.Value
+	switch v := v.(type) {
+	case ast.StringValue:
+		elems = []ast.Value{v}
+	case ast.ArrayValue:
+		elems = v.Elements
+	}
+
+	var stops []string
+	for _, elem := range elems {
+		if sv, ok := elem.(ast.StringValue); ok && sv.Value != "" {
+			stops = append(stops, sv.Value)
+		}
+	}
+	return stops
+}
+
+// workspaceDefault returns a property of the workspace's config entity, where
+// defaults shared by all agents (default_model, default_temperature,
+// default_instruction) are declared.
+func (r *Runtime) workspaceDefault(key string) (ast.Value, bool) {
+	if r.workspace == nil {
+		return nil, false
+	}
+	configs := r.workspace.GetEntitiesByType("config")
+	if len(configs) == 0 {
+		return nil, false
+	}
+	return configs[0].GetProperty(key)
+}
+
+// getProviderForModel returns the appropriate provider for a model: the
+// first registered model route that matches it, else the built-in provider
+// for its prefix, else the default provider, else any provider. Pipeline
+// preflight (checkProviders) and plans resolve through it too, so a model
+// they accept is one a step can run with.
 func (r *Runtime) getProviderForModel(model string) (LLMProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, route := range r.modelRoutes {
+		if route.match(model) {
+			return route.provider, nil
+		}
+	}
+
 	// Check model prefix to determine provider
 	switch {
 	case strings.HasPrefix(model, "claude"):
@@ -490,6 +663,10 @@ func (r *Runtime) getProviderForModel(model string) (LLMProvider, error) {
 		if p, ok := r.providers["openai"]; ok {
 			return p, nil
 		}
+	case strings.HasPrefix(model, OllamaModelPrefix):
+		if p, ok := r.providers["ollama"]; ok {
+			return p, nil
+		}
 	}
 
 	// Try default provider
@@ -1,13 +1,20 @@
 package runtime
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/shellkjell/langspace/pkg/ast"
+	"github.com/shellkjell/langspace/pkg/workspace"
 )
 
+// ErrStepTimeout is returned (wrapped) when a pipeline step exceeds its
+// timeout, as opposed to failing or being cancelled by the caller.
+var ErrStepTimeout = errors.New("step timed out")
+
 // executePipeline executes a pipeline entity.
 func (r *Runtime) executePipeline(ctx *ExecutionContext, entity ast.Entity) (*ExecutionResult, error) {
 	result := &ExecutionResult{
@@ -18,6 +25,7 @@ func (r *Runtime) executePipeline(ctx *ExecutionContext, entity ast.Entity) (*Ex
 
 	// Initialize step outputs map
 	ctx.StepOutputs = make(map[string]interface{})
+	ctx.pipeline = entity.Name()
 
 	// Emit start event
 	ctx.EmitProgress(ProgressEvent{
@@ -27,33 +35,63 @@ func (r *Runtime) executePipeline(ctx *ExecutionContext, entity ast.Entity) (*Ex
 
 	resolver := NewResolver(ctx)
 
+	// The pipeline's own input supplies defaults for anything not given by
+	// an intent or the caller
+	if inputProp, ok := entity.GetProperty("input"); ok {
+		defaults, err := resolver.Resolve(inputProp)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %q: failed to resolve input: %w", entity.Name(), err)
+		}
+		current, _ := ctx.GetVariable("input")
+		ctx.SetVariable("input", mergeInput(defaults, current))
+	}
+
 	// Get steps from the pipeline
 	pipeline, ok := entity.(*ast.PipelineEntity)
 	if !ok {
 		return nil, fmt.Errorf("entity is not a pipeline")
 	}
 
-	// Execute each step
-	totalSteps := len(pipeline.Steps)
-	for i, step := range pipeline.Steps {
-		stepResult, err := r.executeStep(ctx, step, resolver, i+1, totalSteps)
-		result.StepResults[step.Name()] = stepResult
+	// Fail before the first step if a step's model has no provider
+	if err := r.checkProviders(ctx, entity, resolver); err != nil {
+		result.Error = err
+		return result, err
+	}
 
-		if err != nil {
-			result.Error = fmt.Errorf("step %q failed: %w", step.Name(), err)
-			ctx.EmitProgress(ProgressEvent{
-				Type:    ProgressTypeError,
-				Message: err.Error(),
-				Step:    step.Name(),
-			})
-			return result, result.Error
+	stepDelay := r.config.StepDelay
+	if d, ok, err := durationProperty(entity, "step_delay"); err != nil {
+		return nil, fmt.Errorf("pipeline %q: %w", entity.Name(), err)
+	} else if ok {
+		stepDelay = d
+	}
+
+	// Execute each step, in order unless steps declare their dependencies
+	totalSteps := len(pipeline.Steps)
+	if workspace.HasStepDependencies(pipeline) {
+		if err := r.executeStepGraph(ctx, pipeline, resolver, result); err != nil {
+			result.Error = err
+			return result, err
 		}
+	} else {
+		for i, step := range pipeline.Steps {
+			if i > 0 {
+				if err := sleepContext(ctx.Context, stepDelay); err != nil {
+					result.Error = fmt.Errorf("pipeline cancelled before step %q: %w", step.Name(), err)
+					return result, result.Error
+				}
+			}
 
-		// Update token usage
-		if stepResult.Output != nil {
-			if usage, ok := stepResult.Output.(TokenUsage); ok {
-				result.TokensUsed.Add(usage)
+			ctx.run.stepStarted(step.Name())
+			stepResult, err := r.executeStep(ctx, step, resolver, i+1, totalSteps)
+			ctx.run.stepFinished(step.Name(), err)
+			result.StepResults[step.Name()] = stepResult
+			result.TokensUsed.Add(stepResult.TokensUsed)
+
+			if err != nil {
+				result.Error = r.stepFailed(ctx, step, i, totalSteps, err)
+				return result, result.Error
 			}
+			r.emitRunningCost(ctx, step, i, totalSteps)
 		}
 	}
 
@@ -128,14 +166,200 @@ func (r *Runtime) executePipeline(ctx *ExecutionContext, entity ast.Entity) (*Ex
 		Metadata: map[string]string{
 			"steps_executed": fmt.Sprintf("%d", len(result.StepResults)),
 			"duration":       result.Duration.String(),
+			"tokens_used":    fmt.Sprintf("%d", result.TokensUsed.TotalTokens),
 		},
 	})
 
 	return result, nil
 }
 
-// executeStep executes a single step in a pipeline.
+// stepFailed reports the failure of the step at index i and returns the
+// pipeline's error for it.
+func (r *Runtime) stepFailed(ctx *ExecutionContext, step *ast.StepEntity, i, totalSteps int, err error) error {
+	ctx.EmitProgress(ProgressEvent{
+		Type:    ProgressTypeError,
+		Message: err.Error(),
+		Step:    step.Name(),
+	})
+	if errors.Is(err, ErrBudgetExceeded) {
+		return fmt.Errorf("pipeline stopped at step %d of %d (%q): %w", i+1, totalSteps, step.Name(), err)
+	}
+	return fmt.Errorf("step %q failed: %w", step.Name(), err)
+}
+
+// emitRunningCost reports the run's spend so far after the step at index i.
+func (r *Runtime) emitRunningCost(ctx *ExecutionContext, step *ast.StepEntity, i, totalSteps int) {
+	if spent := ctx.cost.total(); spent > 0 {
+		ctx.EmitProgress(ProgressEvent{
+			Type:     ProgressTypeStep,
+			Message:  fmt.Sprintf("Run cost so far: $%.4f", spent),
+			Step:     step.Name(),
+			Progress: ((i + 1) * 100) / (totalSteps + 1),
+			Metadata: map[string]string{
+				"cost_usd": fmt.Sprintf("%.6f", spent),
+			},
+		})
+	}
+}
+
+// executeStepGraph runs the steps of a pipeline that declares depends_on.
+// Each step starts as soon as the steps it depends on have finished, so
+// independent steps run concurrently, up to Config.MaxParallelSteps at once.
+// Steps share the pipeline's step outputs and variables; when concurrent
+// steps set the same variable, the last write wins.
+//
+// The first failing step cancels the steps still running, and steps that
+// have not started yet are skipped. step_delay does not apply.
+func (r *Runtime) executeStepGraph(ctx *ExecutionContext, pipeline *ast.PipelineEntity, resolver *Resolver, result *ExecutionResult) error {
+	deps, err := workspace.StepDependencies(pipeline)
+	if err != nil {
+		return fmt.Errorf("pipeline %q: %w", pipeline.Name(), err)
+	}
+
+	if ctx.mu == nil {
+		ctx.mu = &sync.RWMutex{}
+	}
+	graphCtx, cancel := context.WithCancel(ctx.Context)
+	defer cancel()
+	scoped := *ctx
+	scoped.Context = graphCtx
+
+	var sem chan struct{}
+	if r.config.MaxParallelSteps > 0 {
+		sem = make(chan struct{}, r.config.MaxParallelSteps)
+	}
+
+	finished := make(map[string]chan struct{}, len(pipeline.Steps))
+	for _, step := range pipeline.Steps {
+		finished[step.Name()] = make(chan struct{})
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	totalSteps := len(pipeline.Steps)
+	for i, step := range pipeline.Steps {
+		wg.Add(1)
+		go func(i int, step *ast.StepEntity) {
+			defer wg.Done()
+			defer close(finished[step.Name()])
+
+			for _, dep := range deps[step.Name()] {
+				<-finished[dep]
+			}
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-graphCtx.Done():
+					return
+				}
+			}
+			if graphCtx.Err() != nil {
+				return
+			}
+
+			ctx.run.stepStarted(step.Name())
+			stepResult, err := r.executeStep(&scoped, step, resolver, i+1, totalSteps)
+			ctx.run.stepFinished(step.Name(), err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.StepResults[step.Name()] = stepResult
+			result.TokensUsed.Add(stepResult.TokensUsed)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = r.stepFailed(ctx, step, i, totalSteps, err)
+					cancel()
+				}
+				return
+			}
+			r.emitRunningCost(ctx, step, i, totalSteps)
+		}(i, step)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// executeStep executes a single step in a pipeline, bounded by the step's
+// timeout property or Config.StepTimeout.
 func (r *Runtime) executeStep(ctx *ExecutionContext, step *ast.StepEntity, resolver *Resolver, stepNum, totalSteps int) (*StepResult, error) {
+	if err := waitIfPaused(ctx, step.Name()); err != nil {
+		now := time.Now()
+		err = fmt.Errorf("cancelled while paused before step %q: %w", step.Name(), err)
+		return &StepResult{Name: step.Name(), Error: err, StartTime: now, EndTime: now}, err
+	}
+
+	withStep := *ctx
+	withStep.step = step.Name()
+	ctx = &withStep
+
+	timeout := r.config.StepTimeout
+	if d, ok, err := durationProperty(step, "timeout"); err != nil {
+		now := time.Now()
+		return &StepResult{Name: step.Name(), Error: err, StartTime: now, EndTime: now}, err
+	} else if ok {
+		timeout = d
+	}
+
+	if timeout <= 0 {
+		stepResult, err := r.runStep(ctx, step, resolver, stepNum, totalSteps)
+		if err != nil {
+			err = r.escalateStep(ctx, stepResult, err)
+		}
+		return stepResult, err
+	}
+
+	stepCtx, cancel := context.WithTimeout(ctx.Context, timeout)
+	defer cancel()
+	scoped := *ctx
+	scoped.Context = stepCtx
+
+	stepResult, err := r.runStep(&scoped, step, resolver, stepNum, totalSteps)
+	if err != nil && errors.Is(stepCtx.Err(), context.DeadlineExceeded) && ctx.Context.Err() == nil {
+		err = fmt.Errorf("%w after %s: %v", ErrStepTimeout, timeout, err)
+		stepResult.Error = err
+	}
+	if err != nil {
+		err = r.escalateStep(ctx, stepResult, err)
+	}
+	return stepResult, err
+}
+
+// waitIfPaused blocks while the run is paused, before step starts, so a
+// paused run resumes with the same state it stopped with. It returns the
+// context's error if the run is cancelled while waiting.
+func waitIfPaused(ctx *ExecutionContext, step string) error {
+	resume := ctx.run.pausedUntil()
+	if resume == nil {
+		return nil
+	}
+
+	ctx.EmitProgress(ProgressEvent{
+		Type:     ProgressTypeStep,
+		Message:  fmt.Sprintf("Paused before step: %s", step),
+		Step:     step,
+		Metadata: map[string]string{"state": "paused"},
+	})
+	select {
+	case <-resume:
+	case <-ctx.Context.Done():
+		return ctx.Context.Err()
+	}
+	ctx.EmitProgress(ProgressEvent{
+		Type:     ProgressTypeStep,
+		Message:  fmt.Sprintf("Resumed at step: %s", step),
+		Step:     step,
+		Metadata: map[string]string{"state": "resumed"},
+	})
+	return nil
+}
+
+// runStep performs the LLM call for a single pipeline step.
+func (r *Runtime) runStep(ctx *ExecutionContext, step *ast.StepEntity, resolver *Resolver, stepNum, totalSteps int) (*StepResult, error) {
 	stepResult := &StepResult{
 		Name:      step.Name(),
 		StartTime: time.Now(),
@@ -185,9 +409,30 @@ func (r *Runtime) executeStep(ctx *ExecutionContext, step *ast.StepEntity, resol
 		}
 	}
 
-	// Get model and temperature
-	model := r.getAgentModel(agent)
-	temperature := r.getAgentTemperature(agent)
+	// Get model settings; the step may override the agent's per setting
+	model, temperature, maxTokens := r.getStepModelSettings(step, agent)
+	defer func() {
+		r.metrics.observeStep(ctx.pipeline, model, stepResult.Success, stepResult.Duration)
+	}()
+
+	// Keep the prompt within its token budget, if it has one
+	if budget := r.maxPromptTokens(step); budget > 0 {
+		if err := r.fitStepPrompt(ctx, step.Name(), model, systemPrompt, prompt, budget); err != nil {
+			stepResult.Error = err
+			stepResult.EndTime = time.Now()
+			stepResult.Duration = stepResult.EndTime.Sub(stepResult.StartTime)
+			return stepResult, err
+		}
+	}
+
+	// Get the agent's tools
+	tools, err := r.getAgentTools(ctx, agent, resolver)
+	if err != nil {
+		stepResult.Error = fmt.Errorf("failed to get agent tools: %w", err)
+		stepResult.EndTime = time.Now()
+		stepResult.Duration = stepResult.EndTime.Sub(stepResult.StartTime)
+		return stepResult, stepResult.Error
+	}
 
 	// Get provider
 	provider, err := r.getProviderForModel(model)
@@ -198,32 +443,58 @@ func (r *Runtime) executeStep(ctx *ExecutionContext, step *ast.StepEntity, resol
 		return stepResult, err
 	}
 
+	// Get the output format the step requires, if any
+	validate, err := r.outputValidator(step)
+	if err != nil {
+		stepResult.Error = fmt.Errorf("step %q: %w", step.Name(), err)
+		stepResult.EndTime = time.Now()
+		stepResult.Duration = stepResult.EndTime.Sub(stepResult.StartTime)
+		return stepResult, stepResult.Error
+	}
+
 	// Build request
 	req := &CompletionRequest{
 		Model:        model,
 		SystemPrompt: systemPrompt,
 		Messages: []Message{
-			{Role: RoleUser, Content: prompt},
+			{Role: RoleUser, Content: prompt.String()},
 		},
-		Temperature: temperature,
-	}
+		Temperature:   temperature,
+		MaxTokens:     maxTokens,
+		Tools:         tools,
+		StopSequences: getStopSequences(agent),
+		Seed:          getSeed(agent),
 
-	// Execute
-	var resp *CompletionResponse
-	if ctx.Handler != nil && r.config.EnableStreaming {
-		resp, err = provider.CompleteStream(ctx.Context, req, ctx.Handler)
-	} else {
-		resp, err = provider.Complete(ctx.Context, req)
+		ProviderParams: getProviderParams(agent, step),
+	}
+	if stop, ok := step.GetProperty("stop"); ok {
+		req.StopSequences = stopSequences(stop)
 	}
+	if seed := getSeed(step); seed != nil {
+		req.Seed = seed
+	}
+
+	// Execute, running any tools the model asks for
+	resp, usage, err := r.completeWithTools(ctx, provider, req, resolver)
 
 	stepResult.EndTime = time.Now()
 	stepResult.Duration = stepResult.EndTime.Sub(stepResult.StartTime)
+	stepResult.TokensUsed = usage
 
 	if err != nil {
 		stepResult.Error = err
 		return stepResult, err
 	}
 
+	// Reject output that doesn't have the format the step requires
+	if validate != nil {
+		if err := validate(resp.Content); err != nil {
+			stepResult.Output = resp.Content
+			stepResult.Error = fmt.Errorf("step %q: output %w", step.Name(), err)
+			return stepResult, stepResult.Error
+		}
+	}
+
 	// Store the step output
 	stepResult.Success = true
 	stepResult.Output = resp.Content
@@ -231,11 +502,37 @@ func (r *Runtime) executeStep(ctx *ExecutionContext, step *ast.StepEntity, resol
 
 	// Also store in a structured format for property access
 	ctx.SetStepOutput(step.Name()+".output", resp.Content)
-	ctx.SetStepOutput(step.Name()+".tokens", resp.Usage)
+	ctx.SetStepOutput(step.Name()+".tokens", usage)
 
 	return stepResult, nil
 }
 
+// getStepModelSettings returns the model, temperature and max_tokens for a
+// step. Each setting the step declares overrides the agent's; the rest come
+// from the agent, so a step that only sets temperature keeps the agent's model.
+func (r *Runtime) getStepModelSettings(step *ast.StepEntity, agent ast.Entity) (string, float64, int) {
+	model := r.getAgentModel(agent)
+	temperature := r.getAgentTemperature(agent)
+	maxTokens := r.getAgentMaxTokens(agent)
+
+	if v, ok := step.GetProperty("model"); ok {
+		if sv, ok := v.(ast.StringValue); ok && sv.Value != "" {
+			model = sv.Value
+		}
+	}
+	if v, ok := step.GetProperty("temperature"); ok {
+		if nv, ok := v.(ast.NumberValue); ok {
+			temperature = nv.Value
+		}
+	}
+	if v, ok := step.GetProperty("max_tokens"); ok {
+		if nv, ok := v.(ast.NumberValue); ok {
+			maxTokens = int(nv.Value)
+		}
+	}
+	return model, temperature, maxTokens
+}
+
 // resolveStepAgent resolves the agent for a step.
 func (r *Runtime) resolveStepAgent(ctx *ExecutionContext, step *ast.StepEntity, resolver *Resolver) (ast.Entity, error) {
 	useProp, ok := step.GetProperty("use")
@@ -266,45 +563,46 @@ func (r *Runtime) resolveStepAgent(ctx *ExecutionContext, step *ast.StepEntity,
 }
 
 // buildStepPrompt builds the prompt for a pipeline step.
-func (r *Runtime) buildStepPrompt(ctx *ExecutionContext, step *ast.StepEntity, resolver *Resolver) (string, error) {
-	var promptParts []string
+func (r *Runtime) buildStepPrompt(ctx *ExecutionContext, step *ast.StepEntity, resolver *Resolver) (*stepPrompt, error) {
+	p := &stepPrompt{}
 
 	// Get input
 	if inputProp, ok := step.GetProperty("input"); ok {
 		inputContent, err := r.resolveStepInput(ctx, inputProp, resolver)
 		if err != nil {
-			return "", fmt.Errorf("failed to resolve input: %w", err)
-		}
-		if inputContent != "" {
-			promptParts = append(promptParts, "## Input\n\n"+inputContent)
+			return nil, fmt.Errorf("failed to resolve input: %w", err)
 		}
+		p.input = inputContent
 	}
 
 	// Get context
 	if contextProp, ok := step.GetProperty("context"); ok {
 		contextContent, err := r.resolveContextContent(contextProp, resolver)
 		if err != nil {
-			return "", fmt.Errorf("failed to resolve context: %w", err)
-		}
-		if contextContent != "" {
-			promptParts = append(promptParts, "## Context\n\n"+contextContent)
+			return nil, fmt.Errorf("failed to resolve context: %w", err)
 		}
+		p.context = contextContent
 	}
 
 	// Get explicit prompt if provided
 	if promptProp, ok := step.GetProperty("prompt"); ok {
 		promptStr, err := resolver.ResolveString(promptProp)
 		if err != nil {
-			return "", fmt.Errorf("failed to resolve prompt: %w", err)
+			return nil, fmt.Errorf("failed to resolve prompt: %w", err)
 		}
-		promptParts = append(promptParts, promptStr)
+		p.prompt = promptStr
+	}
+
+	if p.input == "" && p.context == "" && p.prompt == "" {
+		p.prompt = fmt.Sprintf("Please help me with step: %s", step.Name())
 	}
 
-	if len(promptParts) == 0 {
-		return fmt.Sprintf("Please help me with step: %s", step.Name()), nil
+	// Describe the expected output when the step declares a schema
+	if schema, ok := step.GetProperty("output_schema"); ok {
+		p.format = renderOutputSchema(schema)
 	}
 
-	return joinNonEmpty(promptParts, "\n\n"), nil
+	return p, nil
 }
 
 // resolveStepInput resolves the input for a step, which may reference previous step outputs.
@@ -347,7 +645,7 @@ func (r *Runtime) executeParallelBlock(ctx *ExecutionContext, entity ast.Entity,
 		wg.Add(1)
 		go func(idx int, e ast.Entity) {
 			defer wg.Done()
-			res, err := r.Execute(ctx.Context, e)
+			res, err := r.Execute(ctx.Context, e, withParent(ctx))
 			execResults[idx] = res
 			errors[idx] = err
 		}(i, ent)
@@ -357,6 +655,9 @@ func (r *Runtime) executeParallelBlock(ctx *ExecutionContext, entity ast.Entity,
 
 	// Collect results
 	for i, ent := range entities {
+		if execResults[i] != nil {
+			result.TokensUsed.Add(execResults[i].TokensUsed)
+		}
 		if errors[i] != nil {
 			return fmt.Errorf("parallel entity %q failed: %w", ent.Name(), errors[i])
 		}
@@ -364,10 +665,11 @@ func (r *Runtime) executeParallelBlock(ctx *ExecutionContext, entity ast.Entity,
 		// If it was a step, add to step results
 		if stepEntity, ok := ent.(*ast.StepEntity); ok {
 			result.StepResults[stepEntity.Name()] = &StepResult{
-				Name:     stepEntity.Name(),
-				Success:  execResults[i].Success,
-				Output:   execResults[i].Output,
-				Duration: execResults[i].Duration,
+				Name:       stepEntity.Name(),
+				Success:    execResults[i].Success,
+				Output:     execResults[i].Output,
+				Duration:   execResults[i].Duration,
+				TokensUsed: execResults[i].TokensUsed,
 			}
 		}
 	}
@@ -397,7 +699,10 @@ func (r *Runtime) executeBranchBlock(ctx *ExecutionContext, branch ast.BranchVal
 	}
 
 	// Execute the matched case
-	execResult, err := r.Execute(ctx.Context, caseEntity.Entity)
+	execResult, err := r.Execute(ctx.Context, caseEntity.Entity, withParent(ctx))
+	if execResult != nil {
+		result.TokensUsed.Add(execResult.TokensUsed)
+	}
 	if err != nil {
 		return fmt.Errorf("branch case %q failed: %w", conditionStr, err)
 	}
@@ -412,10 +717,11 @@ func (r *Runtime) executeBranchBlock(ctx *ExecutionContext, branch ast.BranchVal
 	// If it was a step, add to step results
 	if stepEntity, ok := caseEntity.Entity.(*ast.StepEntity); ok {
 		result.StepResults[stepEntity.Name()] = &StepResult{
-			Name:     stepEntity.Name(),
-			Success:  execResult.Success,
-			Output:   execResult.Output,
-			Duration: execResult.Duration,
+			Name:       stepEntity.Name(),
+			Success:    execResult.Success,
+			Output:     execResult.Output,
+			Duration:   execResult.Duration,
+			TokensUsed: execResult.TokensUsed,
 		}
 	}
 
@@ -442,7 +748,10 @@ func (r *Runtime) executeLoopBlock(ctx *ExecutionContext, loop ast.LoopValue, re
 
 		// Execute loop body entities
 		for _, nestedEntity := range loop.Body {
-			execResult, err := r.Execute(ctx.Context, nestedEntity.Entity)
+			execResult, err := r.Execute(ctx.Context, nestedEntity.Entity, withParent(ctx))
+			if execResult != nil {
+				result.TokensUsed.Add(execResult.TokensUsed)
+			}
 			if err != nil {
 				return fmt.Errorf("loop iteration %d, entity %q failed: %w", i+1, nestedEntity.Entity.Name(), err)
 			}
@@ -451,10 +760,11 @@ func (r *Runtime) executeLoopBlock(ctx *ExecutionContext, loop ast.LoopValue, re
 			if stepEntity, ok := nestedEntity.Entity.(*ast.StepEntity); ok {
 				stepName := fmt.Sprintf("%s_iter%d", stepEntity.Name(), i+1)
 				result.StepResults[stepName] = &StepResult{
-					Name:     stepName,
-					Success:  execResult.Success,
-					Output:   execResult.Output,
-					Duration: execResult.Duration,
+					Name:       stepName,
+					Success:    execResult.Success,
+					Output:     execResult.Output,
+					Duration:   execResult.Duration,
+					TokensUsed: execResult.TokensUsed,
 				}
 			}
 		}
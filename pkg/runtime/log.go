@@ -0,0 +1,34 @@
+package runtime
+
+import (
+	"log/slog"
+)
+
+// WithLogger sends the runtime's debug logging to logger: each provider
+// request as it is sent and answered or fails, retries, and cache hits,
+// with the provider, model, pipeline, step and attempt as attributes. It
+// complements progress events, which report a run's course to its caller,
+// with the detail needed to debug providers. By default nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Runtime) {
+		r.logger = logger
+	}
+}
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// requestLogger returns the runtime's logger with the attributes that
+// identify a request to provider in ctx.
+func (r *Runtime) requestLogger(ctx *ExecutionContext, provider LLMProvider, req *CompletionRequest) *slog.Logger {
+	if r.logger == nil {
+		return discardLogger
+	}
+	attrs := []any{slog.String("provider", provider.Name()), slog.String("model", req.Model)}
+	if ctx.pipeline != "" {
+		attrs = append(attrs, slog.String("pipeline", ctx.pipeline))
+	}
+	if ctx.step != "" {
+		attrs = append(attrs, slog.String("step", ctx.step))
+	}
+	return r.logger.With(attrs...)
+}
@@ -0,0 +1,266 @@
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	goruntime "runtime"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+// modulePath is the import path of this module, used to find its version in
+// the build info of the binary embedding it.
+const modulePath = "github.com/shellkjell/langspace"
+
+// RunManifest records everything needed to reproduce a run: the LangSpace
+// version, a hash of the executed entity and everything it references, the
+// effective runtime configuration, the input and the models involved.
+type RunManifest struct {
+	// Version is the LangSpace module version ("(devel)" for local builds)
+	Version string `json:"version"`
+
+	// GoVersion is the Go toolchain the binary was built with
+	GoVersion string `json:"go_version"`
+
+	// EntityType and EntityName identify the executed entity
+	EntityType string `json:"entity_type"`
+	EntityName string `json:"entity_name"`
+
+	// EntityHash is a SHA-256 over the executed entity, its nested steps and
+	// every workspace entity they reference (agents, files, tools, ...)
+	EntityHash string `json:"entity_hash"`
+
+	// Config is the effective runtime configuration. RunSeed is the seed the
+	// run uses: the configured one, the workspace config's run_seed, or one
+	// chosen for it; pass it to Execute with WithRunSeed. Environment values
+	// are omitted because they commonly hold secrets; only their keys are
+	// kept.
+	Config Config `json:"config"`
+
+	// EnvironmentKeys lists the keys of Config.Environment
+	EnvironmentKeys []string `json:"environment_keys,omitempty"`
+
+	// Input is the execution input, if any
+	Input interface{} `json:"input,omitempty"`
+
+	// Models lists the models used by the agents and steps involved, sorted
+	Models []string `json:"models"`
+
+	// CreatedAt is when the manifest was built
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Manifest builds a RunManifest for executing entity with the given input.
+func (r *Runtime) Manifest(entity ast.Entity, input interface{}) (*RunManifest, error) {
+	if entity == nil {
+		return nil, fmt.Errorf("cannot build manifest for nil entity")
+	}
+
+	cfg := *r.config
+	cfg.RunSeed = r.chooseRunSeed()
+	cfg.Environment = nil
+	var envKeys []string
+	for k := range r.config.Environment {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+
+	h := sha256.New()
+	models := make(map[string]bool)
+	visited := make(map[string]bool)
+	r.hashEntity(h, entity, visited, models)
+
+	modelList := make([]string, 0, len(models))
+	for m := range models {
+		modelList = append(modelList, m)
+	}
+	sort.Strings(modelList)
+
+	return &RunManifest{
+		Version:         moduleVersion(),
+		GoVersion:       goruntime.Version(),
+		EntityType:      entity.Type(),
+		EntityName:      entity.Name(),
+		EntityHash:      hex.EncodeToString(h.Sum(nil)),
+		Config:          cfg,
+		EnvironmentKeys: envKeys,
+		Input:           input,
+		Models:          modelList,
+		CreatedAt:       timeNow().UTC(),
+	}, nil
+}
+
+// WriteTo writes the manifest as indented JSON.
+func (m *RunManifest) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	data = append(data, '\n')
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// WriteFile writes the manifest as indented JSON to path.
+func (m *RunManifest) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest file: %w", err)
+	}
+	if _, err := m.WriteTo(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// moduleVersion returns the LangSpace version recorded in the build info.
+func moduleVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(unknown)"
+	}
+	if bi.Main.Path == modulePath {
+		return bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
+
+// hashEntity writes a canonical description of entity, its nested entities and
+// the workspace entities they reference to h. Agent models, and models steps
+// set for themselves, are collected into models.
+func (r *Runtime) hashEntity(h hash.Hash, entity ast.Entity, visited, models map[string]bool) {
+	key := entity.Type() + ":" + entity.Name()
+	if visited[key] {
+		return
+	}
+	visited[key] = true
+
+	var refs []ast.ReferenceValue
+	workspace.WalkEntity(entity, func(e ast.Entity) {
+		fmt.Fprintf(h, "entity %q %q\n", e.Type(), e.Name())
+		props := e.Properties()
+		keys := make([]string, 0, len(props))
+		for k := range props {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "  %q = ", k)
+			writeCanonicalValue(h, props[k])
+			fmt.Fprintln(h)
+		}
+		switch e.Type() {
+		case "agent":
+			models[r.getAgentModel(e)] = true
+		case "step":
+			if v, ok := e.GetProperty("model"); ok {
+				if sv, ok := v.(ast.StringValue); ok && sv.Value != "" {
+					models[sv.Value] = true
+				}
+			}
+		}
+		for _, ref := range workspace.EntityReferences(e) {
+			refs = append(refs, ref.Target)
+		}
+	})
+
+	for _, ref := range refs {
+		target, found := r.workspace.GetEntityByName(ref.Type, ref.Name)
+		if !found {
+			// Step references and undefined entities are covered by the
+			// reference itself, which is already part of the hash
+			continue
+		}
+		r.hashEntity(h, target, visited, models)
+	}
+}
+
+// writeCanonicalValue writes a deterministic representation of v to w.
+// Nested entities are written by name only; WalkEntity visits their bodies.
+func writeCanonicalValue(w io.Writer, v ast.Value) {
+	switch val := v.(type) {
+	case nil:
+		fmt.Fprint(w, "nil")
+	case ast.StringValue:
+		fmt.Fprintf(w, "%q", val.Value)
+	case ast.NumberValue:
+		fmt.Fprintf(w, "%v", val.Value)
+	case ast.BoolValue:
+		fmt.Fprintf(w, "%t", val.Value)
+	case ast.ArrayValue:
+		fmt.Fprint(w, "[")
+		for _, elem := range val.Elements {
+			writeCanonicalValue(w, elem)
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "]")
+	case ast.ObjectValue:
+		keys := make([]string, 0, len(val.Properties))
+		for k := range val.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprint(w, "{")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%q:", k)
+			writeCanonicalValue(w, val.Properties[k])
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "}")
+	case ast.NestedEntityValue:
+		if val.Entity != nil {
+			fmt.Fprintf(w, "nested(%q %q)", val.Entity.Type(), val.Entity.Name())
+		}
+	case ast.BranchValue:
+		fmt.Fprint(w, "branch(")
+		writeCanonicalValue(w, val.Condition)
+		cases := make([]string, 0, len(val.Cases))
+		for k := range val.Cases {
+			cases = append(cases, k)
+		}
+		sort.Strings(cases)
+		for _, k := range cases {
+			fmt.Fprintf(w, ",%q=>", k)
+			writeCanonicalValue(w, val.Cases[k])
+		}
+		fmt.Fprint(w, ")")
+	case ast.LoopValue:
+		fmt.Fprintf(w, "loop(%d,", val.MaxIterations)
+		for _, body := range val.Body {
+			writeCanonicalValue(w, body)
+			fmt.Fprint(w, ",")
+		}
+		writeCanonicalValue(w, val.BreakCondition)
+		fmt.Fprint(w, ")")
+	case ast.MethodCallValue:
+		fmt.Fprint(w, "call(")
+		writeCanonicalValue(w, val.Object)
+		fmt.Fprintf(w, ".%s", val.Method)
+		for _, arg := range val.Arguments {
+			fmt.Fprint(w, ",")
+			writeCanonicalValue(w, arg)
+		}
+		if val.InlineBody != nil {
+			fmt.Fprintf(w, ",inline(%q %q)", val.InlineBody.Type(), val.InlineBody.Name())
+		}
+		fmt.Fprint(w, ")")
+	default:
+		// Remaining value types contain only scalars, slices and other values
+		fmt.Fprintf(w, "%#v", val)
+	}
+}
@@ -0,0 +1,148 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+func TestRuntime_Manifest(t *testing.T) {
+	source := `
+agent "writer" {
+	model: "claude-sonnet-4-20250514"
+	instruction: "Write"
+}
+
+agent "editor" {
+	model: "gpt-4o"
+	instruction: "Edit"
+}
+
+pipeline "draft" {
+	step "write" {
+		use: agent("writer")
+		input: $input
+	}
+	step "edit" {
+		use: agent("editor")
+		input: step("write").output
+	}
+}
+`
+	build := func(t *testing.T, src string) *RunManifest {
+		t.Helper()
+		ws := workspace.New()
+		addEntities(t, ws, parseSource(t, src))
+		cfg := DefaultConfig()
+		cfg.Environment = map[string]string{"API_TOKEN": "secret"}
+		rt := New(ws, WithConfig(cfg))
+
+		pipeline, _ := ws.GetEntityByName("pipeline", "draft")
+		m, err := rt.Manifest(pipeline, "hello")
+		if err != nil {
+			t.Fatalf("Manifest() error: %v", err)
+		}
+		return m
+	}
+
+	m := build(t, source)
+
+	if m.EntityType != "pipeline" || m.EntityName != "draft" {
+		t.Errorf("unexpected entity: %s %q", m.EntityType, m.EntityName)
+	}
+	if want := []string{"claude-sonnet-4-20250514", "gpt-4o"}; len(m.Models) != 2 || m.Models[0] != want[0] || m.Models[1] != want[1] {
+		t.Errorf("Models = %v, want %v", m.Models, want)
+	}
+	if m.Input != "hello" {
+		t.Errorf("Input = %v, want hello", m.Input)
+	}
+	if m.Config.Environment != nil {
+		t.Error("manifest must not include environment values")
+	}
+	if len(m.EnvironmentKeys) != 1 || m.EnvironmentKeys[0] != "API_TOKEN" {
+		t.Errorf("EnvironmentKeys = %v", m.EnvironmentKeys)
+	}
+
+	t.Run("hash is stable", func(t *testing.T) {
+		if again := build(t, source); again.EntityHash != m.EntityHash {
+			t.Errorf("hash changed between identical builds: %s != %s", again.EntityHash, m.EntityHash)
+		}
+	})
+
+	t.Run("hash covers referenced agents", func(t *testing.T) {
+		changed := build(t, `
+agent "writer" {
+	model: "claude-sonnet-4-20250514"
+	instruction: "Write tersely"
+}
+
+agent "editor" {
+	model: "gpt-4o"
+	instruction: "Edit"
+}
+
+pipeline "draft" {
+	step "write" {
+		use: agent("writer")
+		input: $input
+	}
+	step "edit" {
+		use: agent("editor")
+		input: step("write").output
+	}
+}
+`)
+		if changed.EntityHash == m.EntityHash {
+			t.Error("expected hash to change when a referenced agent changes")
+		}
+	})
+
+	t.Run("records the run seed", func(t *testing.T) {
+		if m.Config.RunSeed == 0 {
+			t.Error("Config.RunSeed = 0 without a configured seed, want the seed chosen for the run")
+		}
+		seeded := build(t, source+`
+config {
+	run_seed: 42
+}
+`)
+		if seeded.Config.RunSeed != 42 {
+			t.Errorf("Config.RunSeed = %d, want the workspace run_seed 42", seeded.Config.RunSeed)
+		}
+	})
+
+	t.Run("records step models", func(t *testing.T) {
+		overridden := build(t, `
+agent "writer" {
+	model: "claude-sonnet-4-20250514"
+}
+
+pipeline "draft" {
+	step "write" {
+		use: agent("writer")
+		model: "gpt-4o-mini"
+	}
+}
+`)
+		if want := []string{"claude-sonnet-4-20250514", "gpt-4o-mini"}; !reflect.DeepEqual(overridden.Models, want) {
+			t.Errorf("Models = %v, want %v", overridden.Models, want)
+		}
+	})
+
+	t.Run("json round trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		if _, err := m.WriteTo(&buf); err != nil {
+			t.Fatalf("WriteTo() error: %v", err)
+		}
+		var decoded RunManifest
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("invalid manifest JSON: %v", err)
+		}
+		if decoded.EntityHash != m.EntityHash {
+			t.Errorf("decoded hash = %s, want %s", decoded.EntityHash, m.EntityHash)
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStepDurationBuckets are the upper bounds, in seconds, of the step
+// latency histogram.
+var DefaultStepDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// metricHelp describes each metric a Metrics collector exports.
+var metricHelp = map[string]string{
+	"langspace_requests_total":        "Provider requests, by outcome.",
+	"langspace_request_retries_total": "Provider requests retried after a retryable failure.",
+	"langspace_cache_hits_total":      "Requests answered from the response cache.",
+	"langspace_tokens_total":          "Tokens used by provider requests.",
+	"langspace_steps_total":           "Pipeline steps run, by outcome.",
+	"langspace_step_duration_seconds": "Time taken by pipeline steps.",
+}
+
+// Metrics collects counters and histograms about the requests and pipeline
+// steps a Runtime runs, labelled by pipeline and model. Requests made by an
+// intent outside a pipeline have an empty pipeline label. Register it with
+// WithMetrics and expose it with WriteProm or as an http.Handler.
+//
+// The methods of a nil *Metrics do nothing, so a runtime without metrics
+// pays nothing for them.
+type Metrics struct {
+	buckets    []float64
+	counters   map[string]float64
+	histograms map[string]*histogram
+	mu         sync.Mutex
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics creates an empty collector using DefaultStepDurationBuckets.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		buckets:    DefaultStepDurationBuckets,
+		counters:   make(map[string]float64),
+		histograms: make(map[string]*histogram),
+	}
+}
+
+// WithMetrics records the runtime's requests and steps in m.
+func WithMetrics(m *Metrics) Option {
+	return func(r *Runtime) {
+		r.metrics = m
+	}
+}
+
+// series identifies a metric and its labels, rendered as in the exposition
+// format so it can serve as a map key and be written out as-is. Labels must
+// be given as name, value pairs in alphabetical order of name.
+func series(name string, labels ...string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func (m *Metrics) add(key string, v float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[key] += v
+}
+
+// observeRequest records a provider request and the tokens it used.
+func (m *Metrics) observeRequest(pipeline, model string, resp *CompletionResponse, err error) {
+	if m == nil {
+		return
+	}
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.add(series("langspace_requests_total", "model", model, "pipeline", pipeline, "status", status), 1)
+	if resp != nil {
+		m.add(series("langspace_tokens_total", "direction", "input", "model", model, "pipeline", pipeline), float64(resp.Usage.InputTokens))
+		m.add(series("langspace_tokens_total", "direction", "output", "model", model, "pipeline", pipeline), float64(resp.Usage.OutputTokens))
+	}
+}
+
+// observeRetry records a request about to be retried.
+func (m *Metrics) observeRetry(pipeline, model string) {
+	if m == nil {
+		return
+	}
+	m.add(series("langspace_request_retries_total", "model", model, "pipeline", pipeline), 1)
+}
+
+// observeCacheHit records a request answered from the response cache.
+func (m *Metrics) observeCacheHit(pipeline, model string) {
+	if m == nil {
+		return
+	}
+	m.add(series("langspace_cache_hits_total", "model", model, "pipeline", pipeline), 1)
+}
+
+// observeStep records a finished pipeline step and how long it took.
+func (m *Metrics) observeStep(pipeline, model string, success bool, d time.Duration) {
+	if m == nil {
+		return
+	}
+	status := "success"
+	if !success {
+		status = "failure"
+	}
+	m.add(series("langspace_steps_total", "model", model, "pipeline", pipeline, "status", status), 1)
+
+	key := series("langspace_step_duration_seconds", "model", model, "pipeline", pipeline)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.histograms[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets)+1)}
+		m.histograms[key] = h
+	}
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(m.buckets, seconds)
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// WriteProm writes every metric in the Prometheus text exposition format,
+// with series sorted so the output is stable.
+func (m *Metrics) WriteProm(w io.Writer) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	byName := make(map[string][]string)
+	for key := range m.counters {
+		name := key[:strings.IndexByte(key, '{')]
+		byName[name] = append(byName[name], key)
+	}
+	for key := range m.histograms {
+		name := key[:strings.IndexByte(key, '{')]
+		byName[name] = append(byName[name], key)
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		keys := byName[name]
+		sort.Strings(keys)
+
+		typ := "counter"
+		if _, ok := m.histograms[keys[0]]; ok {
+			typ = "histogram"
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, metricHelp[name])
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, typ)
+
+		for _, key := range keys {
+			if typ == "counter" {
+				fmt.Fprintf(&b, "%s %s\n", key, formatMetricValue(m.counters[key]))
+				continue
+			}
+			h := m.histograms[key]
+			labels := key[len(name)+1 : len(key)-1]
+			var cumulative uint64
+			for i, count := range h.counts {
+				cumulative += count
+				le := "+Inf"
+				if i < len(m.buckets) {
+					le = formatMetricValue(m.buckets[i])
+				}
+				fmt.Fprintf(&b, "%s_bucket{%s,le=%q} %d\n", name, labels, le, cumulative)
+			}
+			fmt.Fprintf(&b, "%s_sum{%s} %s\n", name, labels, formatMetricValue(h.sum))
+			fmt.Fprintf(&b, "%s_count{%s} %d\n", name, labels, h.count)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves the metrics for a Prometheus scrape.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_ = m.WriteProm(w)
+}
+
+func formatMetricValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
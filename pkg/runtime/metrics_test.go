@@ -0,0 +1,92 @@
+package runtime
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+// promLine matches a sample line of the Prometheus text exposition format.
+var promLine = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{([a-zA-Z_][a-zA-Z0-9_]*="([^"\\]|\\.)*",?)*\})? [0-9.e+-]+$`)
+
+func TestMetrics_WriteProm(t *testing.T) {
+	source := `
+agent "solver" {
+	model: "mock-model"
+}
+
+pipeline "hanoi" {
+	step "plan" {
+		use: agent("solver")
+		input: "three disks"
+	}
+	step "move" {
+		use: agent("solver")
+		input: step("plan").output
+	}
+}
+`
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, source))
+
+	metrics := NewMetrics()
+	mockProvider := NewMockProvider(WithMockResponses(MockResponse{
+		Content: "ok",
+		Usage:   TokenUsage{InputTokens: 10, OutputTokens: 4, TotalTokens: 14},
+	}))
+	rt := New(ws, WithProvider("mock", mockProvider), WithMetrics(metrics))
+
+	if _, err := rt.ExecuteByName(context.Background(), "pipeline", "hanoi"); err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+
+	var b strings.Builder
+	if err := metrics.WriteProm(&b); err != nil {
+		t.Fatalf("WriteProm() error: %v", err)
+	}
+	out := b.String()
+
+	types := 0
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			types++
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !promLine.MatchString(line) {
+			t.Errorf("malformed sample line %q", line)
+		}
+	}
+	if types != 4 {
+		t.Errorf("got %d metric families, want 4:\n%s", types, out)
+	}
+
+	for _, want := range []string{
+		`langspace_requests_total{model="mock-model",pipeline="hanoi",status="ok"} 2`,
+		`langspace_tokens_total{direction="input",model="mock-model",pipeline="hanoi"} 20`,
+		`langspace_tokens_total{direction="output",model="mock-model",pipeline="hanoi"} 8`,
+		`langspace_steps_total{model="mock-model",pipeline="hanoi",status="success"} 2`,
+		`langspace_step_duration_seconds_bucket{model="mock-model",pipeline="hanoi",le="+Inf"} 2`,
+		`langspace_step_duration_seconds_count{model="mock-model",pipeline="hanoi"} 2`,
+		"# TYPE langspace_step_duration_seconds histogram",
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+	m.observeRequest("p", "m", nil, nil)
+	m.observeStep("p", "m", true, 0)
+
+	var b strings.Builder
+	if err := m.WriteProm(&b); err != nil || b.Len() != 0 {
+		t.Errorf("WriteProm() on nil = %q, %v; want nothing", b.String(), err)
+	}
+}
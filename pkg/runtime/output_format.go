@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// OutputValidator checks the output of a pipeline step, returning an error
+// that says why the output is rejected.
+type OutputValidator func(output string) error
+
+// builtinOutputFormats are the named formats a step's output_format can
+// select without registering them.
+var builtinOutputFormats = map[string]OutputValidator{
+	"json":        validateJSONOutput,
+	"single_line": validateSingleLineOutput,
+}
+
+// WithOutputFormat registers a named output format that steps can require
+// with output_format, replacing a built-in format of the same name.
+func WithOutputFormat(name string, validate OutputValidator) Option {
+	return func(r *Runtime) {
+		if r.outputFormats == nil {
+			r.outputFormats = make(map[string]OutputValidator)
+		}
+		r.outputFormats[name] = validate
+	}
+}
+
+// outputValidator returns the validator a step asks for, or nil: its
+// output_pattern if set, otherwise the format named by its output_format,
+// followed by a check against its output_schema when schema validation is
+// on for the step.
+func (r *Runtime) outputValidator(step ast.Entity) (OutputValidator, error) {
+	format, err := r.outputFormatValidator(step)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, ok := step.GetProperty("output_schema")
+	if !ok || !r.validatesOutputSchema(step) {
+		return format, nil
+	}
+	validateSchema := schemaValidator(schema)
+	if format == nil {
+		return validateSchema, nil
+	}
+	return func(output string) error {
+		if err := format(output); err != nil {
+			return err
+		}
+		return validateSchema(output)
+	}, nil
+}
+
+// validatesOutputSchema reports whether a step's output is checked against
+// its output_schema: its validate_output property if set, otherwise
+// Config.ValidateOutputSchema.
+func (r *Runtime) validatesOutputSchema(step ast.Entity) bool {
+	if v, ok := step.GetProperty("validate_output"); ok {
+		if bv, ok := v.(ast.BoolValue); ok {
+			return bv.Value
+		}
+	}
+	return r.config.ValidateOutputSchema
+}
+
+// outputFormatValidator returns the validator for a step's output_pattern
+// if set, otherwise for the format named by its output_format, otherwise nil.
+func (r *Runtime) outputFormatValidator(step ast.Entity) (OutputValidator, error) {
+	if v, ok := step.GetProperty("output_pattern"); ok {
+		sv, ok := v.(ast.StringValue)
+		if !ok {
+			return nil, fmt.Errorf("output_pattern must be a string")
+		}
+		re, err := regexp.Compile(sv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output_pattern: %w", err)
+		}
+		return func(output string) error {
+			if !re.MatchString(output) {
+				return fmt.Errorf("does not match output_pattern %q", sv.Value)
+			}
+			return nil
+		}, nil
+	}
+
+	v, ok := step.GetProperty("output_format")
+	if !ok {
+		return nil, nil
+	}
+	sv, ok := v.(ast.StringValue)
+	if !ok {
+		return nil, fmt.Errorf("output_format must be a string")
+	}
+	if validate, ok := r.outputFormats[sv.Value]; ok {
+		return validate, nil
+	}
+	if validate, ok := builtinOutputFormats[sv.Value]; ok {
+		return validate, nil
+	}
+	return nil, fmt.Errorf("unknown output_format %q", sv.Value)
+}
+
+// validateJSONOutput accepts output that is a single JSON value, optionally
+// wrapped in a ```json fence.
+func validateJSONOutput(output string) error {
+	if !json.Valid([]byte(unfenceJSON(output))) {
+		return errors.New("is not valid JSON")
+	}
+	return nil
+}
+
+// validateSingleLineOutput accepts non-empty output without line breaks,
+// ignoring surrounding whitespace.
+func validateSingleLineOutput(output string) error {
+	trimmed := strings.TrimSpace(output)
+	if trimmed == "" {
+		return errors.New("is empty")
+	}
+	if strings.ContainsAny(trimmed, "\r\n") {
+		return errors.New("spans more than one line")
+	}
+	return nil
+}
+
+// unfenceJSON strips surrounding whitespace and a Markdown code fence, as
+// models often wrap JSON in one.
+func unfenceJSON(output string) string {
+	s := strings.TrimSpace(output)
+	if !strings.HasPrefix(s, "```") || !strings.HasSuffix(s, "```") || len(s) < 6 {
+		return s
+	}
+	s = strings.TrimSuffix(s[3:], "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 && !strings.ContainsAny(s[:i], "{[\"") {
+		s = s[i+1:] // drop the info string, e.g. json
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+func TestOutputFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		output string
+		valid  bool
+	}{
+		{"json", `{"move": "A->C"}`, true},
+		{"json", "```json\n[1, 2, 3]\n```", true},
+		{"json", `  "text"  `, true},
+		{"json", `{"move": }`, false},
+		{"json", "Sure! {\"move\": \"A->C\"}", false},
+		{"single_line", "move disk 1 from A to C\n", true},
+		{"single_line", "move disk 1\nfrom A to C", false},
+		{"single_line", "   ", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%q", tt.format, tt.output), func(t *testing.T) {
+			err := builtinOutputFormats[tt.format](tt.output)
+			if (err == nil) != tt.valid {
+				t.Errorf("validate(%q) = %v, want valid %v", tt.output, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestExecute_StepOutputFormat(t *testing.T) {
+	const source = `
+agent "solver" {
+	model: "mock-model"
+}
+
+pipeline "p" {
+	step "move" {
+		use: agent("solver")
+		%s
+	}
+}
+`
+	upper := func(output string) error {
+		if output != strings.ToUpper(output) {
+			return errors.New("is not upper case")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		props    string
+		output   string
+		wantErr  string
+		requests int
+	}{
+		{name: "no format", props: "", output: "anything", requests: 1},
+		{name: "preset accepted", props: `output_format: "json"`, output: `{"move": 1}`, requests: 1},
+		{name: "preset rejected", props: `output_format: "json"`, output: "move 1", wantErr: "output is not valid JSON", requests: 1},
+		{name: "pattern overrides format", props: `output_format: "json"
+		output_pattern: "^move \\d+$"`, output: "move 1", requests: 1},
+		{name: "pattern rejected", props: `output_pattern: "^move \\d+$"`, output: "jump 1", wantErr: "does not match output_pattern", requests: 1},
+		{name: "registered format", props: `output_format: "upper"`, output: "lower", wantErr: "is not upper case", requests: 1},
+		{name: "unknown format", props: `output_format: "yaml"`, wantErr: `unknown output_format "yaml"`},
+		{name: "invalid pattern", props: `output_pattern: "("`, wantErr: "invalid output_pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, fmt.Sprintf(source, tt.props)))
+
+			mockProvider := NewMockProvider(WithMockResponses(MockResponse{Content: tt.output}))
+			rt := New(ws, WithProvider("mock", mockProvider), WithOutputFormat("upper", upper))
+
+			result, err := rt.ExecuteByName(context.Background(), "pipeline", "p")
+			if n := len(mockProvider.GetRequests()); n != tt.requests {
+				t.Errorf("got %d requests, want %d", n, tt.requests)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("execute error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if tt.requests > 0 && result.StepResults["move"].Output != tt.output {
+				t.Errorf("rejected step output = %v, want it kept for inspection", result.StepResults["move"].Output)
+			}
+		})
+	}
+}
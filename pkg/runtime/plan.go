@@ -0,0 +1,213 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// ExecutionPlan describes how an intent or pipeline would run: the agent,
+// model and provider each step resolves to. It is built by Plan without
+// calling any provider.
+type ExecutionPlan struct {
+	// EntityType and EntityName identify the planned entity
+	EntityType string `json:"entity_type"`
+	EntityName string `json:"entity_name"`
+
+	// Steps lists the steps that would run, in pipeline order. An intent
+	// that prompts an agent directly has a single step named after it.
+	Steps []PlannedStep `json:"steps"`
+
+	// TotalSteps is the number of steps that would run
+	TotalSteps int `json:"total_steps"`
+
+	// Warnings lists everything that would make the run fail, such as a
+	// step whose agent or provider can't be found
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PlannedStep is one step of an ExecutionPlan. Fields that couldn't be
+// resolved are empty and explained by a warning on the plan.
+type PlannedStep struct {
+	Name     string `json:"name"`
+	Agent    string `json:"agent,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Provider string `json:"provider,omitempty"`
+}
+
+// Plan resolves the agents, models and providers an intent or pipeline
+// would use, without calling any provider or running scripts and tools.
+// Problems with individual steps are reported as warnings on the plan; an
+// error means the entity can't be planned at all.
+func (r *Runtime) Plan(ctx context.Context, entity ast.Entity) (*ExecutionPlan, error) {
+	if entity == nil {
+		return nil, fmt.Errorf("cannot plan nil entity")
+	}
+
+	execCtx := &ExecutionContext{
+		Context:   ctx,
+		Runtime:   r,
+		Workspace: r.workspace,
+		Variables: make(map[string]interface{}),
+		Metadata:  make(map[string]string),
+		StartTime: time.Now(),
+		cost:      &costTracker{},
+		mu:        &sync.RWMutex{},
+	}
+	resolver := NewResolver(execCtx)
+
+	plan := &ExecutionPlan{
+		EntityType: entity.Type(),
+		EntityName: entity.Name(),
+	}
+
+	switch entity.Type() {
+	case "intent":
+		if use, ok := entity.GetProperty("use"); ok {
+			if ref, ok := use.(ast.ReferenceValue); ok && ref.Type == "pipeline" {
+				pipeline, err := resolver.workspace.GetPipeline(ref.Name)
+				if err != nil {
+					plan.Warnings = append(plan.Warnings, fmt.Sprintf("intent %q: %v", entity.Name(), err))
+					break
+				}
+				r.planPipeline(execCtx, pipeline, resolver, plan)
+				break
+			}
+		}
+		agent, err := r.resolveAgent(execCtx, entity, resolver)
+		r.planStep(plan, entity.Name(), agent, err, func(agent ast.Entity) string {
+			return r.getAgentModel(agent)
+		})
+	case "pipeline":
+		r.planPipeline(execCtx, entity, resolver, plan)
+	default:
+		return nil, fmt.Errorf("cannot plan entity of type %q", entity.Type())
+	}
+
+	plan.TotalSteps = len(plan.Steps)
+	return plan, nil
+}
+
+// planPipeline adds the steps of a pipeline, including those of a parallel
+// block, to plan.
+func (r *Runtime) planPipeline(ctx *ExecutionContext, entity ast.Entity, resolver *Resolver, plan *ExecutionPlan) {
+	pipeline, ok := entity.(*ast.PipelineEntity)
+	if !ok {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s %q is not a pipeline", entity.Type(), entity.Name()))
+		return
+	}
+
+	steps := pipeline.Steps
+	if value, ok := entity.GetProperty("parallel"); ok {
+		if nested, ok := value.(ast.NestedEntityValue); ok {
+			if parallel, ok := nested.Entity.(*ast.ParallelEntity); ok {
+				steps = append(steps[:len(steps):len(steps)], parallel.Steps...)
+			}
+		}
+	}
+
+	for _, step := range steps {
+		agent, err := r.resolveStepAgent(ctx, step, resolver)
+		r.planStep(plan, step.Name(), agent, err, func(agent ast.Entity) string {
+			model, _, _ := r.getStepModelSettings(step, agent)
+			return model
+		})
+	}
+}
+
+// planStep adds a step using agent, or the error resolving it, to plan.
+// model picks the step's model once its agent is known.
+func (r *Runtime) planStep(plan *ExecutionPlan, name string, agent ast.Entity, err error, model func(ast.Entity) string) {
+	planned := PlannedStep{Name: name}
+	defer func() {
+		plan.Steps = append(plan.Steps, planned)
+	}()
+
+	if err != nil {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("step %q: %v", name, err))
+		return
+	}
+	planned.Agent = agent.Name()
+	planned.Model = model(agent)
+
+	provider, err := r.getProviderForModel(planned.Model)
+	if err != nil {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("step %q: %v", name, err))
+		return
+	}
+	planned.Provider = provider.Name()
+}
+
+// checkProviders makes sure a provider serves the model of every step of a
+// pipeline before any step runs, so a run that can't finish fails up front
+// with every unserved model named. Models resolve through
+// getProviderForModel, exactly as they do when the step runs. Steps whose
+// agent can't be resolved yet are left to report that when they run.
+func (r *Runtime) checkProviders(ctx *ExecutionContext, pipeline ast.Entity, resolver *Resolver) error {
+	plan := &ExecutionPlan{}
+	r.planPipeline(ctx, pipeline, resolver, plan)
+
+	var models []string
+	steps := make(map[string][]string)
+	for _, step := range plan.Steps {
+		if step.Agent == "" || step.Provider != "" {
+			continue
+		}
+		if _, ok := steps[step.Model]; !ok {
+			models = append(models, step.Model)
+		}
+		steps[step.Model] = append(steps[step.Model], fmt.Sprintf("%q", step.Name))
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	unserved := make([]string, len(models))
+	for i, model := range models {
+		noun := "step"
+		if len(steps[model]) > 1 {
+			noun = "steps"
+		}
+		unserved[i] = fmt.Sprintf("model %q (%s %s)", model, noun, strings.Join(steps[model], ", "))
+	}
+	return fmt.Errorf("pipeline %q: no LLM provider for %s; %s; register one with WithProvider or RegisterModelProvider",
+		pipeline.Name(), strings.Join(unserved, ", "), r.describeProviders())
+}
+
+// describeProviders summarizes what can serve a model: the registered
+// providers, the model routes and the default provider.
+func (r *Runtime) describeProviders() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, fmt.Sprintf("%q", name))
+	}
+	sort.Strings(names)
+
+	var parts []string
+	if len(names) == 0 {
+		parts = append(parts, "no providers are registered")
+	} else {
+		parts = append(parts, "registered providers: "+strings.Join(names, ", "))
+	}
+	switch n := len(r.modelRoutes); n {
+	case 0:
+	case 1:
+		parts = append(parts, "1 model route")
+	default:
+		parts = append(parts, fmt.Sprintf("%d model routes", n))
+	}
+	if _, ok := r.providers[r.config.DefaultProvider]; ok {
+		parts = append(parts, fmt.Sprintf("default provider %q", r.config.DefaultProvider))
+	} else {
+		parts = append(parts, fmt.Sprintf("default provider %q is not registered", r.config.DefaultProvider))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,236 @@
+package runtime
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+func TestPlan(t *testing.T) {
+	const source = `
+agent "writer" {
+	model: "mock-model"
+}
+
+pipeline "report" {
+	step "draft" {
+		use: agent("writer")
+	}
+	step "polish" {
+		use: agent("writer")
+		model: "mock-large"
+	}
+}
+
+pipeline "broken" {
+	step "draft" {
+		use: agent("ghost")
+	}
+}
+
+intent "ask" {
+	use: agent("writer")
+}
+
+intent "run-report" {
+	use: pipeline("report")
+}
+
+script "cleanup" {
+	language: "bash"
+	code: "true"
+}
+`
+	tests := []struct {
+		name         string
+		entityType   string
+		entityName   string
+		wantSteps    []PlannedStep
+		wantWarnings []string
+		wantErr      bool
+	}{
+		{
+			name:       "pipeline",
+			entityType: "pipeline",
+			entityName: "report",
+			wantSteps: []PlannedStep{
+				{Name: "draft", Agent: "writer", Model: "mock-model", Provider: "mock"},
+				{Name: "polish", Agent: "writer", Model: "mock-large", Provider: "mock"},
+			},
+		},
+		{
+			name:         "unresolved agent",
+			entityType:   "pipeline",
+			entityName:   "broken",
+			wantSteps:    []PlannedStep{{Name: "draft"}},
+			wantWarnings: []string{`step "draft"`},
+		},
+		{
+			name:       "intent",
+			entityType: "intent",
+			entityName: "ask",
+			wantSteps:  []PlannedStep{{Name: "ask", Agent: "writer", Model: "mock-model", Provider: "mock"}},
+		},
+		{
+			name:       "intent using a pipeline",
+			entityType: "intent",
+			entityName: "run-report",
+			wantSteps: []PlannedStep{
+				{Name: "draft", Agent: "writer", Model: "mock-model", Provider: "mock"},
+				{Name: "polish", Agent: "writer", Model: "mock-large", Provider: "mock"},
+			},
+		},
+		{
+			name:       "script",
+			entityType: "script",
+			entityName: "cleanup",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, source))
+
+			mockProvider := NewMockProvider()
+			rt := New(ws, WithProvider("mock", mockProvider))
+
+			entity, ok := ws.GetEntityByName(tt.entityType, tt.entityName)
+			if !ok {
+				t.Fatalf("%s %q not found", tt.entityType, tt.entityName)
+			}
+			plan, err := rt.Plan(context.Background(), entity)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("plan error: %v", err)
+			}
+
+			if !reflect.DeepEqual(plan.Steps, tt.wantSteps) {
+				t.Errorf("steps = %+v, want %+v", plan.Steps, tt.wantSteps)
+			}
+			if plan.TotalSteps != len(tt.wantSteps) {
+				t.Errorf("total steps = %d, want %d", plan.TotalSteps, len(tt.wantSteps))
+			}
+			if len(plan.Warnings) != len(tt.wantWarnings) {
+				t.Fatalf("warnings = %q, want %d", plan.Warnings, len(tt.wantWarnings))
+			}
+			for i, want := range tt.wantWarnings {
+				if !strings.Contains(plan.Warnings[i], want) {
+					t.Errorf("warning %q does not mention %q", plan.Warnings[i], want)
+				}
+			}
+			if n := len(mockProvider.GetRequests()); n != 0 {
+				t.Errorf("provider got %d requests, want none", n)
+			}
+		})
+	}
+}
+
+func TestPlan_NoProvider(t *testing.T) {
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, `
+agent "writer" {
+	model: "mock-model"
+}
+
+intent "ask" {
+	use: agent("writer")
+}
+`))
+	rt := New(ws)
+
+	entity, _ := ws.GetEntityByName("intent", "ask")
+	plan, err := rt.Plan(context.Background(), entity)
+	if err != nil {
+		t.Fatalf("plan error: %v", err)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "no LLM provider") {
+		t.Errorf("warnings = %q, want a missing provider", plan.Warnings)
+	}
+	if step := plan.Steps[0]; step.Model != "mock-model" || step.Provider != "" {
+		t.Errorf("step = %+v, want the model without a provider", step)
+	}
+}
+
+func TestExecute_PipelineChecksProvidersFirst(t *testing.T) {
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, `
+agent "writer" {
+	model: "gpt-4o"
+}
+
+agent "critic" {
+	model: "claude-unknown"
+}
+
+pipeline "p" {
+	step "draft" {
+		use: agent("writer")
+	}
+	step "review" {
+		use: agent("critic")
+	}
+	step "revise" {
+		use: agent("writer")
+	}
+}
+`))
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "no providers",
+			want: []string{
+				`model "gpt-4o" (steps "draft", "revise")`,
+				`model "claude-unknown" (step "review")`,
+				"no providers are registered",
+			},
+		},
+		{
+			name: "unmatched model route",
+			opts: []Option{
+				WithModelProvider(func(model string) bool { return model == "acme" }, NewMockProvider()),
+			},
+			want: []string{
+				`model "claude-unknown" (step "review")`,
+				`no providers are registered, 1 model route, default provider "anthropic" is not registered`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := New(ws, tt.opts...)
+
+			var steps int
+			handler := &CallbackStreamHandler{ProgressFunc: func(e ProgressEvent) {
+				if e.Type == ProgressTypeStep {
+					steps++
+				}
+			}}
+			_, err := rt.ExecuteByName(context.Background(), "pipeline", "p", WithStreamHandler(handler))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %s", err, want)
+				}
+			}
+			if steps != 0 {
+				t.Errorf("got %d step events, want the run to stop before the first step", steps)
+			}
+		})
+	}
+}
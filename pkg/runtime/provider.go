@@ -2,6 +2,8 @@ package runtime
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 )
 
 // LLMProvider defines the interface for LLM providers.
@@ -20,6 +22,13 @@ type LLMProvider interface {
 	ListModels(ctx context.Context) ([]ModelInfo, error)
 }
 
+// TemperatureLimiter is implemented by providers that accept a narrower
+// temperature range than the 0 to 2 the validator allows. Requests above a
+// provider's maximum fail before they are sent.
+type TemperatureLimiter interface {
+	MaxTemperature() float64
+}
+
 // CompletionRequest represents a request to an LLM.
 type CompletionRequest struct {
 	// Model specifies which model to use
@@ -43,6 +52,15 @@ type CompletionRequest struct {
 	// StopSequences to end generation
 	StopSequences []string `json:"stop_sequences,omitempty"`
 
+	// Seed asks for reproducible sampling. OpenAI and Ollama honour it;
+	// providers without seeded sampling ignore it.
+	Seed *int `json:"seed,omitempty"`
+
+	// ProviderParams holds further sampling settings, such as top_p, keyed
+	// by their property name. Each provider passes on the ones its API
+	// supports and ignores the rest.
+	ProviderParams map[string]interface{} `json:"provider_params,omitempty"`
+
 	// Metadata for tracking/logging
 	Metadata map[string]string `json:"metadata,omitempty"`
 }
@@ -98,6 +116,11 @@ type CompletionResponse struct {
 
 	// Model that was used
 	Model string `json:"model"`
+
+	// SystemFingerprint identifies the backend configuration that served a
+	// seeded request, where the provider reports one; responses to the same
+	// seed are only expected to match while it stays the same.
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
 }
 
 // FinishReason indicates why the model stopped generating.
@@ -185,6 +208,14 @@ const (
 	ProgressTypeStep     ProgressType = "step"
 	ProgressTypeComplete ProgressType = "complete"
 	ProgressTypeError    ProgressType = "error"
+
+	// ProgressTypeEscalation reports a failed step handed to the runtime's
+	// EscalationHandler
+	ProgressTypeEscalation ProgressType = "escalation"
+
+	// ProgressTypeWarning reports something the runtime changed or skipped
+	// to keep going, such as a step prompt truncated to fit its budget
+	ProgressTypeWarning ProgressType = "warning"
 )
 
 // DefaultStreamHandler provides a no-op implementation of StreamHandler.
@@ -261,3 +292,79 @@ func (h *BufferedStreamHandler) Content() string {
 	}
 	return content
 }
+
+// The ProviderParams keys the built-in providers understand. An agent or
+// step sets them as properties of the same name.
+const (
+	ParamTopP             = "top_p"
+	ParamTopK             = "top_k"
+	ParamFrequencyPenalty = "frequency_penalty"
+	ParamPresencePenalty  = "presence_penalty"
+)
+
+// providerParamNames lists the properties copied into ProviderParams.
+var providerParamNames = []string{ParamTopP, ParamTopK, ParamFrequencyPenalty, ParamPresencePenalty}
+
+// floatParam returns a ProviderParams entry as a float, or nil if it is
+// unset or not a number.
+func floatParam(params map[string]interface{}, key string) *float64 {
+	switch v := params[key].(type) {
+	case float64:
+		return &v
+	case int:
+		f := float64(v)
+		return &f
+	}
+	return nil
+}
+
+// intParam returns a ProviderParams entry as an int, or nil if it is unset
+// or not a number.
+func intParam(params map[string]interface{}, key string) *int {
+	if f := floatParam(params, key); f != nil {
+		n := int(*f)
+		return &n
+	}
+	return nil
+}
+
+// streamedToolCalls assembles the tool calls of a streamed response, whose
+// IDs, names and JSON arguments arrive in fragments tagged with the index
+// of the call they belong to.
+type streamedToolCalls struct {
+	order []int
+	calls map[int]*streamedToolCall
+}
+
+type streamedToolCall struct {
+	id, name string
+	args     strings.Builder
+}
+
+// get returns the call at index, starting a new one if needed.
+func (s *streamedToolCalls) get(index int) *streamedToolCall {
+	if s.calls == nil {
+		s.calls = make(map[int]*streamedToolCall)
+	}
+	tc, ok := s.calls[index]
+	if !ok {
+		tc = &streamedToolCall{}
+		s.calls[index] = tc
+		s.order = append(s.order, index)
+	}
+	return tc
+}
+
+// toolCalls returns the assembled calls in the order they started.
+func (s *streamedToolCalls) toolCalls() []ToolCall {
+	var result []ToolCall
+	for _, i := range s.order {
+		tc := s.calls[i]
+		var args map[string]interface{}
+		if tc.args.Len() > 0 {
+			_ = json.Unmarshal([]byte(tc.args.String()), &args)
+		}
+		result = append(result, ToolCall{ID: tc.id, Name: tc.name, Arguments: args})
+	}
+	return result
+}
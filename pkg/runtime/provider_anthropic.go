@@ -68,15 +68,26 @@ func (p *AnthropicProvider) Name() string {
 	return "anthropic"
 }
 
+// MaxTemperature implements TemperatureLimiter: Anthropic's API accepts
+// temperatures from 0 to 1.
+func (p *AnthropicProvider) MaxTemperature() float64 {
+	return 1
+}
+
 // anthropicRequest is the request format for Anthropic's API.
 type anthropicRequest struct {
 	Model       string             `json:"model"`
 	Messages    []anthropicMessage `json:"messages"`
 	System      string             `json:"system,omitempty"`
 	MaxTokens   int                `json:"max_tokens"`
-	Temperature float64            `json:"temperature,omitempty"`
+	Temperature float64            `json:"temperature"`
 	Stream      bool               `json:"stream,omitempty"`
 	Tools       []anthropicTool    `json:"tools,omitempty"`
+
+	StopSequences []string `json:"stop_sequences,omitempty"`
+
+	TopP *float64 `json:"top_p,omitempty"`
+	TopK *int     `json:"top_k,omitempty"`
 }
 
 type anthropicMessage struct {
@@ -116,11 +127,9 @@ type anthropicUsage struct {
 	OutputTokens int `json:"output_tokens"`
 }
 
-func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
-	if p.apiKey == "" {
-		return nil, fmt.Errorf("anthropic API key not set")
-	}
-
+// buildRequest converts req to Anthropic's format, including its tools and
+// the tool calls and results of earlier turns.
+func (p *AnthropicProvider) buildRequest(req *CompletionRequest) anthropicRequest {
 	// Convert messages to Anthropic format
 	anthropicMsgs := make([]anthropicMessage, 0, len(req.Messages))
 	for _, msg := range req.Messages {
@@ -179,15 +188,26 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 		maxTokens = 4096
 	}
 
-	anthropicReq := anthropicRequest{
-		Model:       req.Model,
-		Messages:    anthropicMsgs,
-		System:      req.SystemPrompt,
-		MaxTokens:   maxTokens,
-		Temperature: req.Temperature,
-		Tools:       anthropicTools,
+	return anthropicRequest{
+		Model:         req.Model,
+		Messages:      anthropicMsgs,
+		System:        req.SystemPrompt,
+		MaxTokens:     maxTokens,
+		Temperature:   req.Temperature,
+		StopSequences: req.StopSequences,
+		Tools:         anthropicTools,
+		TopP:          floatParam(req.ProviderParams, ParamTopP),
+		TopK:          intParam(req.ProviderParams, ParamTopK),
+	}
+}
+
+func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("anthropic API key not set")
 	}
 
+	anthropicReq := p.buildRequest(req)
+
 	body, err := json.Marshal(anthropicReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -214,7 +234,7 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError("anthropic", resp.StatusCode, respBody)
 	}
 
 	var anthropicResp anthropicResponse
@@ -271,49 +291,8 @@ func (p *AnthropicProvider) CompleteStream(ctx context.Context, req *CompletionR
 		return nil, fmt.Errorf("anthropic API key not set")
 	}
 
-	// Convert messages to Anthropic format
-	anthropicMsgs := make([]anthropicMessage, 0, len(req.Messages))
-	for _, msg := range req.Messages {
-		role := string(msg.Role)
-		if role == "tool" {
-			role = "user"
-		}
-
-		content := []anthropicContentBlock{}
-		if msg.Content != "" {
-			if msg.ToolCallID != "" {
-				content = append(content, anthropicContentBlock{
-					Type:      "tool_result",
-					ToolUseID: msg.ToolCallID,
-					Content:   msg.Content,
-				})
-			} else {
-				content = append(content, anthropicContentBlock{
-					Type: "text",
-					Text: msg.Content,
-				})
-			}
-		}
-
-		anthropicMsgs = append(anthropicMsgs, anthropicMessage{
-			Role:    role,
-			Content: content,
-		})
-	}
-
-	maxTokens := req.MaxTokens
-	if maxTokens == 0 {
-		maxTokens = 4096
-	}
-
-	anthropicReq := anthropicRequest{
-		Model:       req.Model,
-		Messages:    anthropicMsgs,
-		System:      req.SystemPrompt,
-		MaxTokens:   maxTokens,
-		Temperature: req.Temperature,
-		Stream:      true,
-	}
+	anthropicReq := p.buildRequest(req)
+	anthropicReq.Stream = true
 
 	body, err := json.Marshal(anthropicReq)
 	if err != nil {
@@ -341,7 +320,7 @@ func (p *AnthropicProvider) CompleteStream(ctx context.Context, req *CompletionR
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError("anthropic", resp.StatusCode, respBody)
 	}
 
 	return p.handleStream(resp.Body, handler)
@@ -350,6 +329,7 @@ func (p *AnthropicProvider) CompleteStream(ctx context.Context, req *CompletionR
 func (p *AnthropicProvider) handleStream(body io.Reader, handler StreamHandler) (*CompletionResponse, error) {
 	result := &CompletionResponse{}
 	var contentBuilder strings.Builder
+	var toolCalls streamedToolCalls
 	chunkIndex := 0
 
 	reader := NewSSEReader(body)
@@ -364,13 +344,35 @@ func (p *AnthropicProvider) handleStream(body io.Reader, handler StreamHandler)
 		}
 
 		switch event.Event {
+		case "content_block_start":
+			var start struct {
+				Index        int                   `json:"index"`
+				ContentBlock anthropicContentBlock `json:"content_block"`
+			}
+			if err := json.Unmarshal([]byte(event.Data), &start); err != nil {
+				continue
+			}
+
+			if start.ContentBlock.Type == "tool_use" {
+				tc := toolCalls.get(start.Index)
+				tc.id = start.ContentBlock.ID
+				tc.name = start.ContentBlock.Name
+				handler.OnChunk(StreamChunk{
+					Content: tc.name,
+					Type:    ChunkTypeToolStart,
+					Index:   chunkIndex,
+				})
+				chunkIndex++
+			}
+
 		case "content_block_delta":
 			var delta struct {
 				Type  string `json:"type"`
 				Index int    `json:"index"`
 				Delta struct {
-					Type string `json:"type"`
-					Text string `json:"text"`
+					Type        string `json:"type"`
+					Text        string `json:"text"`
+					PartialJSON string `json:"partial_json"`
 				} `json:"delta"`
 			}
 			if err := json.Unmarshal([]byte(event.Data), &delta); err != nil {
@@ -386,6 +388,9 @@ func (p *AnthropicProvider) handleStream(body io.Reader, handler StreamHandler)
 				})
 				chunkIndex++
 			}
+			if delta.Delta.Type == "input_json_delta" {
+				toolCalls.get(delta.Index).args.WriteString(delta.Delta.PartialJSON)
+			}
 
 		case "message_delta":
 			var delta struct {
@@ -425,10 +430,17 @@ func (p *AnthropicProvider) handleStream(body io.Reader, handler StreamHandler)
 
 		case "message_stop":
 			// Stream complete
+
+		case "error":
+			// Errors such as overload can arrive mid-stream after a 200
+			apiErr := newAPIError("anthropic", 0, []byte(event.Data))
+			handler.OnError(apiErr)
+			return nil, apiErr
 		}
 	}
 
 	result.Content = contentBuilder.String()
+	result.ToolCalls = toolCalls.toolCalls()
 	handler.OnComplete(result)
 	return result, nil
 }
@@ -453,6 +465,7 @@ type bufioReader struct {
 	buf []byte
 	pos int
 	end int
+	err error // deferred read error, returned once the buffer is drained
 }
 
 func newBufioReader(r io.Reader) *bufioReader {
@@ -480,15 +493,18 @@ func (b *bufioReader) ReadLine() (string, error) {
 			line = append(line, b.buf[b.pos:b.end]...)
 		}
 
-		// Read more data
-		n, err := b.r.Read(b.buf)
-		b.pos = 0
-		b.end = n
-		if err != nil {
+		// Read more data. A reader may return data together with an error
+		// (typically io.EOF), so the error is only surfaced once that data
+		// has been consumed.
+		b.pos, b.end = 0, 0
+		if b.err == nil {
+			b.end, b.err = b.r.Read(b.buf)
+		}
+		if b.end == 0 && b.err != nil {
 			if len(line) > 0 {
 				return string(line), nil
 			}
-			return "", err
+			return "", b.err
 		}
 	}
 }
@@ -0,0 +1,124 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnthropicProvider_Complete(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("x-api-key"); key != "test-key" {
+			t.Errorf("x-api-key = %q", key)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		fmt.Fprint(w, `{
+			"model": "claude-sonnet-4-20250514",
+			"content": [{"type": "text", "text": "hi"}],
+			"stop_reason": "end_turn",
+			"usage": {"input_tokens": 40, "output_tokens": 3}
+		}`)
+	}))
+	defer server.Close()
+
+	p := NewAnthropicProvider(WithAnthropicAPIKey("test-key"), WithAnthropicBaseURL(server.URL))
+	resp, err := p.Complete(context.Background(), &CompletionRequest{
+		Model:         "claude-sonnet-4-20250514",
+		SystemPrompt:  "Be brief",
+		Messages:      []Message{{Role: RoleUser, Content: "Hello"}},
+		Temperature:   0,
+		StopSequences: []string{"\n\n"},
+		ProviderParams: map[string]interface{}{
+			ParamTopK:             40.0,
+			ParamFrequencyPenalty: 0.5, // not supported by Anthropic
+		},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+
+	if got["system"] != "Be brief" {
+		t.Errorf("system = %v, want top-level system prompt", got["system"])
+	}
+	if msgs, _ := got["messages"].([]interface{}); len(msgs) != 1 {
+		t.Errorf("expected only the user message, got %v", got["messages"])
+	}
+	if temp, ok := got["temperature"]; !ok || temp != float64(0) {
+		t.Errorf("temperature = %v (present %v), want explicit 0", temp, ok)
+	}
+	if stops, _ := got["stop_sequences"].([]interface{}); len(stops) != 1 || stops[0] != "\n\n" {
+		t.Errorf("stop_sequences = %v, want [\"\\n\\n\"]", got["stop_sequences"])
+	}
+	if got["top_k"] != float64(40) {
+		t.Errorf("top_k = %v, want 40", got["top_k"])
+	}
+	for _, key := range []string{"top_p", "frequency_penalty"} {
+		if v, ok := got[key]; ok {
+			t.Errorf("%s = %v, want it omitted", key, v)
+		}
+	}
+
+	if resp.Usage.InputTokens != 40 || resp.Usage.OutputTokens != 3 || resp.Usage.TotalTokens != 43 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicProvider_Errors(t *testing.T) {
+	overloaded := `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+
+	t.Run("overloaded status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, overloaded, 529)
+		}))
+		defer server.Close()
+
+		p := NewAnthropicProvider(WithAnthropicAPIKey("test-key"), WithAnthropicBaseURL(server.URL))
+		_, err := p.Complete(context.Background(), &CompletionRequest{Model: "claude-sonnet-4-20250514"})
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Type != "overloaded_error" {
+			t.Fatalf("expected overloaded_error APIError, got %v", err)
+		}
+		if !IsRetryable(err) {
+			t.Error("overloaded_error should be retryable")
+		}
+	})
+
+	t.Run("overloaded mid-stream", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: message_start\ndata: {\"message\":{\"model\":\"claude-sonnet-4-20250514\",\"usage\":{\"input_tokens\":5}}}\n\n")
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", overloaded)
+		}))
+		defer server.Close()
+
+		p := NewAnthropicProvider(WithAnthropicAPIKey("test-key"), WithAnthropicBaseURL(server.URL))
+		_, err := p.CompleteStream(context.Background(), &CompletionRequest{Model: "claude-sonnet-4-20250514"}, &DefaultStreamHandler{})
+		if err == nil {
+			t.Fatal("expected stream error")
+		}
+		if !IsRetryable(err) {
+			t.Errorf("mid-stream overload should be retryable: %v", err)
+		}
+	})
+
+	t.Run("invalid request is fatal", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`, http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		p := NewAnthropicProvider(WithAnthropicAPIKey("test-key"), WithAnthropicBaseURL(server.URL))
+		_, err := p.Complete(context.Background(), &CompletionRequest{Model: "claude-sonnet-4-20250514"})
+		if err == nil || IsRetryable(err) {
+			t.Errorf("expected fatal error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,409 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// OllamaModelPrefix is the model prefix that routes a model to the Ollama
+// provider, e.g. "ollama/llama3.1". The prefix is stripped before the model
+// name is sent to the server.
+const OllamaModelPrefix = "ollama/"
+
+// OllamaProvider implements LLMProvider for a local Ollama server.
+type OllamaProvider struct {
+	baseURL    string
+	httpClient *http.Client
+	sem        chan struct{} // limits concurrent requests; nil means unlimited
+}
+
+// OllamaOption is a functional option for configuring OllamaProvider.
+type OllamaOption func(*OllamaProvider)
+
+// WithOllamaBaseURL sets the server URL (default http://localhost:11434).
+func WithOllamaBaseURL(url string) OllamaOption {
+	return func(p *OllamaProvider) {
+		p.baseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithOllamaHTTPClient sets a custom HTTP client.
+func WithOllamaHTTPClient(client *http.Client) OllamaOption {
+	return func(p *OllamaProvider) {
+		p.httpClient = client
+	}
+}
+
+// WithOllamaMaxConcurrentRequests caps the number of requests in flight at
+// once. Local models usually share a single GPU, so the default is 1; a value
+// of 0 or less removes the limit.
+func WithOllamaMaxConcurrentRequests(n int) OllamaOption {
+	return func(p *OllamaProvider) {
+		if n <= 0 {
+			p.sem = nil
+			return
+		}
+		p.sem = make(chan struct{}, n)
+	}
+}
+
+// NewOllamaProvider creates a new Ollama provider.
+func NewOllamaProvider(opts ...OllamaOption) *OllamaProvider {
+	p := &OllamaProvider{
+		baseURL:    "http://localhost:11434",
+		httpClient: http.DefaultClient,
+		sem:        make(chan struct{}, 1),
+	}
+
+	// Honour the same variable the Ollama CLI uses
+	if host := os.Getenv("OLLAMA_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		p.baseURL = strings.TrimSuffix(host, "/")
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+func (p *OllamaProvider) Name() string {
+	return "ollama"
+}
+
+// ollamaRequest is the request format for Ollama's chat API.
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+	Stream   bool            `json:"stream"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+
+	// ToolName names the tool whose result a tool message carries
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+type ollamaTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+// ollamaToolCall is a tool call in Ollama's format. Unlike OpenAI's, the
+// arguments are a JSON object rather than a string, and calls carry no ID.
+type ollamaToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaOptions struct {
+	Temperature float64  `json:"temperature"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+
+	TopP             *float64 `json:"top_p,omitempty"`
+	TopK             *int     `json:"top_k,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+}
+
+// ollamaResponse is a response (or, when streaming, a single chunk) from
+// Ollama's chat API. Token counts are only present on the final chunk.
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (p *OllamaProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	body, err := p.post(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(body)
+
+	var ollamaResp ollamaResponse
+	if err := json.NewDecoder(body).Decode(&ollamaResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if ollamaResp.Error != "" {
+		return nil, fmt.Errorf("ollama error: %s", ollamaResp.Error)
+	}
+
+	result := &CompletionResponse{
+		Content:   ollamaResp.Message.Content,
+		ToolCalls: ollamaToolCalls(ollamaResp.Message.ToolCalls, 0),
+	}
+	p.finish(result, &ollamaResp, req.Model)
+	return result, nil
+}
+
+func (p *OllamaProvider) CompleteStream(ctx context.Context, req *CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	body, err := p.post(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(body)
+
+	result := &CompletionResponse{}
+	var contentBuilder strings.Builder
+	chunkIndex := 0
+
+	// Ollama streams newline-delimited JSON rather than SSE
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			continue
+		}
+		if chunk.Error != "" {
+			err := fmt.Errorf("ollama error: %s", chunk.Error)
+			handler.OnError(err)
+			return nil, err
+		}
+
+		if chunk.Message.Content != "" {
+			contentBuilder.WriteString(chunk.Message.Content)
+			handler.OnChunk(StreamChunk{
+				Content: chunk.Message.Content,
+				Type:    ChunkTypeContent,
+				Index:   chunkIndex,
+			})
+			chunkIndex++
+		}
+
+		// Ollama sends each tool call whole, in a single chunk
+		for _, tc := range ollamaToolCalls(chunk.Message.ToolCalls, len(result.ToolCalls)) {
+			result.ToolCalls = append(result.ToolCalls, tc)
+			handler.OnChunk(StreamChunk{
+				Content: tc.Name,
+				Type:    ChunkTypeToolStart,
+				Index:   chunkIndex,
+			})
+			chunkIndex++
+		}
+
+		if chunk.Done {
+			p.finish(result, &chunk, req.Model)
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+
+	result.Content = contentBuilder.String()
+	handler.OnComplete(result)
+	return result, nil
+}
+
+// post sends req to the chat endpoint once a concurrency slot is free. On
+// success the caller owns the returned body and must pass it to release.
+func (p *OllamaProvider) post(ctx context.Context, req *CompletionRequest, stream bool) (io.ReadCloser, error) {
+	msgs := make([]ollamaMessage, 0, len(req.Messages)+1)
+	if req.SystemPrompt != "" {
+		msgs = append(msgs, ollamaMessage{Role: "system", Content: req.SystemPrompt})
+	}
+	// Tool results name their tool rather than the call's ID
+	toolNames := make(map[string]string)
+	for _, msg := range req.Messages {
+		m := ollamaMessage{Role: string(msg.Role), Content: msg.Content}
+		for _, tc := range msg.ToolCalls {
+			var call ollamaToolCall
+			call.Function.Name = tc.Name
+			call.Function.Arguments = tc.Arguments
+			if call.Function.Arguments == nil {
+				call.Function.Arguments = map[string]interface{}{}
+			}
+			m.ToolCalls = append(m.ToolCalls, call)
+			toolNames[tc.ID] = tc.Name
+		}
+		if msg.ToolCallID != "" {
+			m.ToolName = toolNames[msg.ToolCallID]
+		}
+		msgs = append(msgs, m)
+	}
+
+	var tools []ollamaTool
+	for _, tool := range req.Tools {
+		var t ollamaTool
+		t.Type = "function"
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		t.Function.Parameters = tool.Parameters
+		tools = append(tools, t)
+	}
+
+	ollamaReq := ollamaRequest{
+		Model:    strings.TrimPrefix(req.Model, OllamaModelPrefix),
+		Messages: msgs,
+		Tools:    tools,
+		Stream:   stream,
+		Options: ollamaOptions{
+			Temperature: req.Temperature,
+			NumPredict:  req.MaxTokens,
+			Stop:        req.StopSequences,
+			Seed:        req.Seed,
+
+			TopP:             floatParam(req.ProviderParams, ParamTopP),
+			TopK:             intParam(req.ProviderParams, ParamTopK),
+			FrequencyPenalty: floatParam(req.ProviderParams, ParamFrequencyPenalty),
+			PresencePenalty:  floatParam(req.ProviderParams, ParamPresencePenalty),
+		},
+	}
+
+	body, err := json.Marshal(ollamaReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	if p.sem != nil {
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		p.release(nil)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		p.release(nil)
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		p.release(resp.Body)
+		return nil, newAPIError("ollama", resp.StatusCode, respBody)
+	}
+
+	return resp.Body, nil
+}
+
+// release closes body, if any, and frees the request's concurrency slot.
+func (p *OllamaProvider) release(body io.ReadCloser) {
+	if body != nil {
+		if err := body.Close(); err != nil {
+			log.Printf("failed to close response body: %v", err)
+		}
+	}
+	if p.sem != nil {
+		<-p.sem
+	}
+}
+
+// finish fills in the model, usage and finish reason from the final response.
+func (p *OllamaProvider) finish(result *CompletionResponse, resp *ollamaResponse, model string) {
+	result.Model = model
+	result.Usage = TokenUsage{
+		InputTokens:  resp.PromptEvalCount,
+		OutputTokens: resp.EvalCount,
+		TotalTokens:  resp.PromptEvalCount + resp.EvalCount,
+	}
+
+	switch {
+	case len(result.ToolCalls) > 0:
+		result.FinishReason = FinishReasonToolUse
+	case resp.DoneReason == "length":
+		result.FinishReason = FinishReasonLength
+	default:
+		result.FinishReason = FinishReasonStop
+	}
+}
+
+// ollamaToolCalls converts Ollama tool calls, numbering them from first
+// since Ollama doesn't give them IDs of its own.
+func ollamaToolCalls(calls []ollamaToolCall, first int) []ToolCall {
+	var out []ToolCall
+	for i, call := range calls {
+		out = append(out, ToolCall{
+			ID:        fmt.Sprintf("call_%d", first+i),
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return out
+}
+
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError("ollama", resp.StatusCode, respBody)
+	}
+
+	var tagsResp struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tagsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	models := make([]ModelInfo, 0, len(tagsResp.Models))
+	for _, m := range tagsResp.Models {
+		models = append(models, ModelInfo{
+			ID:       OllamaModelPrefix + m.Name,
+			Name:     m.Name,
+			Provider: "ollama",
+		})
+	}
+
+	return models, nil
+}
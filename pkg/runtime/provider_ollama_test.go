@@ -0,0 +1,229 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOllamaProvider_Complete(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		fmt.Fprint(w, `{"model":"llama3.1","message":{"role":"assistant","content":"hi"},"done":true,"done_reason":"length","prompt_eval_count":9,"eval_count":4}`)
+	}))
+	defer server.Close()
+
+	p := NewOllamaProvider(WithOllamaBaseURL(server.URL))
+	resp, err := p.Complete(context.Background(), &CompletionRequest{
+		Model:        "ollama/llama3.1",
+		SystemPrompt: "Be brief",
+		Messages:     []Message{{Role: RoleUser, Content: "Hello"}},
+		Temperature:  0.2,
+		MaxTokens:    32,
+		ProviderParams: map[string]interface{}{
+			ParamTopP:  0.9,
+			ParamTopK:  40.0,
+			"mirostat": 2.0, // unknown params are ignored
+		},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+
+	if got["model"] != "llama3.1" {
+		t.Errorf("model = %v, want prefix stripped", got["model"])
+	}
+	opts, _ := got["options"].(map[string]interface{})
+	if opts["temperature"] != 0.2 || opts["num_predict"] != float64(32) {
+		t.Errorf("options = %v", opts)
+	}
+	if opts["top_p"] != 0.9 || opts["top_k"] != float64(40) {
+		t.Errorf("options = %v, want top_p 0.9 and top_k 40", opts)
+	}
+	if _, ok := opts["mirostat"]; ok {
+		t.Errorf("options = %v, want unknown params ignored", opts)
+	}
+
+	if resp.Content != "hi" || resp.FinishReason != FinishReasonLength || resp.Model != "ollama/llama3.1" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.Usage.InputTokens != 9 || resp.Usage.OutputTokens != 4 || resp.Usage.TotalTokens != 13 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestOllamaProvider_CompleteStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":3,"eval_count":2}`)
+	}))
+	defer server.Close()
+
+	var chunks []string
+	handler := &CallbackStreamHandler{ChunkFunc: func(c StreamChunk) { chunks = append(chunks, c.Content) }}
+
+	p := NewOllamaProvider(WithOllamaBaseURL(server.URL))
+	resp, err := p.CompleteStream(context.Background(), &CompletionRequest{Model: "ollama/llama3.1"}, handler)
+	if err != nil {
+		t.Fatalf("CompleteStream() error: %v", err)
+	}
+	if resp.Content != "Hello" || len(chunks) != 2 {
+		t.Errorf("Content = %q, chunks = %v", resp.Content, chunks)
+	}
+	if resp.Usage.OutputTokens != 2 {
+		t.Errorf("OutputTokens = %d, want 2", resp.Usage.OutputTokens)
+	}
+}
+
+func TestOllamaProvider_Tools(t *testing.T) {
+	var got ollamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		call := `{"function":{"name":"get_weather","arguments":{"city":"Oslo"}}}`
+		if got.Stream {
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"","tool_calls":[`+call+`]},"done":false}`)
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`)
+			return
+		}
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"","tool_calls":[`+call+`]},"done":true,"done_reason":"stop"}`)
+	}))
+	defer server.Close()
+
+	req := &CompletionRequest{
+		Model: "ollama/llama3.1",
+		Messages: []Message{
+			{Role: RoleUser, Content: "Weather in Bergen and Oslo?"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_0", Name: "get_weather", Arguments: map[string]interface{}{"city": "Bergen"}}}},
+			{Role: RoleTool, Content: "rain", ToolCallID: "call_0"},
+		},
+		Tools: []ToolDefinition{{
+			Name:        "get_weather",
+			Description: "Current weather for a city",
+			Parameters:  map[string]interface{}{"type": "object"},
+		}},
+	}
+	check := func(t *testing.T, resp *CompletionResponse) {
+		t.Helper()
+		if len(got.Tools) != 1 || got.Tools[0].Type != "function" || got.Tools[0].Function.Name != "get_weather" {
+			t.Errorf("tools = %+v, want get_weather as a function", got.Tools)
+		}
+		if calls := got.Messages[1].ToolCalls; len(calls) != 1 || calls[0].Function.Arguments["city"] != "Bergen" {
+			t.Errorf("assistant tool calls = %+v, want the Bergen call", calls)
+		}
+		if m := got.Messages[2]; m.Role != "tool" || m.ToolName != "get_weather" || m.Content != "rain" {
+			t.Errorf("tool message = %+v, want the result named by its tool", m)
+		}
+		if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" || resp.ToolCalls[0].Arguments["city"] != "Oslo" || resp.ToolCalls[0].ID == "" {
+			t.Errorf("ToolCalls = %+v, want the Oslo call", resp.ToolCalls)
+		}
+		if resp.FinishReason != FinishReasonToolUse {
+			t.Errorf("FinishReason = %s, want %s", resp.FinishReason, FinishReasonToolUse)
+		}
+	}
+
+	p := NewOllamaProvider(WithOllamaBaseURL(server.URL))
+
+	t.Run("complete", func(t *testing.T) {
+		resp, err := p.Complete(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Complete() error: %v", err)
+		}
+		check(t, resp)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var starts []string
+		handler := &CallbackStreamHandler{ChunkFunc: func(c StreamChunk) {
+			if c.Type == ChunkTypeToolStart {
+				starts = append(starts, c.Content)
+			}
+		}}
+		resp, err := p.CompleteStream(context.Background(), req, handler)
+		if err != nil {
+			t.Fatalf("CompleteStream() error: %v", err)
+		}
+		check(t, resp)
+		if len(starts) != 1 || starts[0] != "get_weather" {
+			t.Errorf("tool start chunks = %v, want get_weather", starts)
+		}
+	})
+}
+
+func TestOllamaProvider_MaxConcurrentRequests(t *testing.T) {
+	var inFlight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, `{"message":{"content":"ok"},"done":true}`)
+	}))
+	defer server.Close()
+
+	p := NewOllamaProvider(WithOllamaBaseURL(server.URL), WithOllamaMaxConcurrentRequests(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Complete(context.Background(), &CompletionRequest{Model: "ollama/llama3.1"}); err != nil {
+				t.Errorf("Complete() error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("peak concurrent requests = %d, want at most 2", peak)
+	}
+}
+
+func TestGetProviderForModel(t *testing.T) {
+	rt := New(nil)
+	rt.RegisterProvider("anthropic", NewAnthropicProvider())
+	rt.RegisterProvider("openai", NewOpenAIProvider())
+	rt.RegisterProvider("ollama", NewOllamaProvider())
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"claude-sonnet-4-20250514", "anthropic"},
+		{"gpt-4o", "openai"},
+		{"ollama/llama3.1", "ollama"},
+		{"unknown-model", "anthropic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			p, err := rt.getProviderForModel(tt.model)
+			if err != nil {
+				t.Fatalf("getProviderForModel() error: %v", err)
+			}
+			if p.Name() != tt.want {
+				t.Errorf("provider = %s, want %s", p.Name(), tt.want)
+			}
+		})
+	}
+}
@@ -71,9 +71,29 @@ type openaiRequest struct {
 	Model       string          `json:"model"`
 	Messages    []openaiMessage `json:"messages"`
 	MaxTokens   int             `json:"max_tokens,omitempty"`
-	Temperature float64         `json:"temperature,omitempty"`
+	Temperature float64         `json:"temperature"`
 	Stream      bool            `json:"stream,omitempty"`
 	Tools       []openaiTool    `json:"tools,omitempty"`
+	Stop        []string        `json:"stop,omitempty"`
+	Seed        *int            `json:"seed,omitempty"`
+
+	TopP             *float64 `json:"top_p,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+
+	// StreamOptions asks the API to append a final usage chunk to streams
+	StreamOptions *openaiStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openaiStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openaiUsage is the token usage block returned by OpenAI's API.
+type openaiUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type openaiMessage struct {
@@ -114,18 +134,13 @@ type openaiResponse struct {
 		Message      openaiMessage `json:"message"`
 		FinishReason string        `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage             openaiUsage `json:"usage"`
+	SystemFingerprint string      `json:"system_fingerprint"`
 }
 
-func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
-	if p.apiKey == "" {
-		return nil, fmt.Errorf("openai API key not set")
-	}
-
+// buildRequest converts req to OpenAI's format, including its tools and
+// the tool calls and results of earlier turns.
+func (p *OpenAIProvider) buildRequest(req *CompletionRequest) openaiRequest {
 	// Convert messages to OpenAI format
 	openaiMsgs := make([]openaiMessage, 0, len(req.Messages)+1)
 
@@ -175,13 +190,27 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 		})
 	}
 
-	openaiReq := openaiRequest{
+	return openaiRequest{
 		Model:       req.Model,
 		Messages:    openaiMsgs,
 		MaxTokens:   req.MaxTokens,
 		Temperature: req.Temperature,
+		Stop:        req.StopSequences,
+		Seed:        req.Seed,
 		Tools:       openaiTools,
+
+		TopP:             floatParam(req.ProviderParams, ParamTopP),
+		FrequencyPenalty: floatParam(req.ProviderParams, ParamFrequencyPenalty),
+		PresencePenalty:  floatParam(req.ProviderParams, ParamPresencePenalty),
 	}
+}
+
+func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("openai API key not set")
+	}
+
+	openaiReq := p.buildRequest(req)
 
 	body, err := json.Marshal(openaiReq)
 	if err != nil {
@@ -208,7 +237,7 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError("openai", resp.StatusCode, respBody)
 	}
 
 	var openaiResp openaiResponse
@@ -219,14 +248,19 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 	return p.convertResponse(&openaiResp), nil
 }
 
+func (u openaiUsage) toTokenUsage() TokenUsage {
+	return TokenUsage{
+		InputTokens:  u.PromptTokens,
+		OutputTokens: u.CompletionTokens,
+		TotalTokens:  u.TotalTokens,
+	}
+}
+
 func (p *OpenAIProvider) convertResponse(resp *openaiResponse) *CompletionResponse {
 	result := &CompletionResponse{
-		Model: resp.Model,
-		Usage: TokenUsage{
-			InputTokens:  resp.Usage.PromptTokens,
-			OutputTokens: resp.Usage.CompletionTokens,
-			TotalTokens:  resp.Usage.TotalTokens,
-		},
+		Model:             resp.Model,
+		Usage:             resp.Usage.toTokenUsage(),
+		SystemFingerprint: resp.SystemFingerprint,
 	}
 
 	if len(resp.Choices) > 0 {
@@ -265,29 +299,10 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *CompletionRequ
 		return nil, fmt.Errorf("openai API key not set")
 	}
 
-	// Convert messages to OpenAI format
-	openaiMsgs := make([]openaiMessage, 0, len(req.Messages)+1)
-
-	if req.SystemPrompt != "" {
-		openaiMsgs = append(openaiMsgs, openaiMessage{
-			Role:    "system",
-			Content: req.SystemPrompt,
-		})
-	}
-
-	for _, msg := range req.Messages {
-		openaiMsgs = append(openaiMsgs, openaiMessage{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		})
-	}
-
-	openaiReq := openaiRequest{
-		Model:       req.Model,
-		Messages:    openaiMsgs,
-		MaxTokens:   req.MaxTokens,
-		Temperature: req.Temperature,
-		Stream:      true,
+	openaiReq := p.buildRequest(req)
+	openaiReq.Stream = true
+	openaiReq.StreamOptions = &openaiStreamOptions{
+		IncludeUsage: true,
 	}
 
 	body, err := json.Marshal(openaiReq)
@@ -315,7 +330,7 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *CompletionRequ
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError("openai", resp.StatusCode, respBody)
 	}
 
 	return p.handleStream(resp.Body, handler)
@@ -324,6 +339,7 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *CompletionRequ
 func (p *OpenAIProvider) handleStream(body io.Reader, handler StreamHandler) (*CompletionResponse, error) {
 	result := &CompletionResponse{}
 	var contentBuilder strings.Builder
+	var toolCalls streamedToolCalls
 	chunkIndex := 0
 
 	reader := NewSSEReader(body)
@@ -348,10 +364,20 @@ func (p *OpenAIProvider) handleStream(body io.Reader, handler StreamHandler) (*C
 			Choices []struct {
 				Index int `json:"index"`
 				Delta struct {
-					Content string `json:"content"`
+					Content   string `json:"content"`
+					ToolCalls []struct {
+						Index    int    `json:"index"`
+						ID       string `json:"id"`
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
 				} `json:"delta"`
 				FinishReason string `json:"finish_reason"`
 			} `json:"choices"`
+			Usage             *openaiUsage `json:"usage"`
+			SystemFingerprint string       `json:"system_fingerprint"`
 		}
 
 		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
@@ -359,6 +385,14 @@ func (p *OpenAIProvider) handleStream(body io.Reader, handler StreamHandler) (*C
 		}
 
 		result.Model = chunk.Model
+		if chunk.SystemFingerprint != "" {
+			result.SystemFingerprint = chunk.SystemFingerprint
+		}
+
+		// The usage chunk arrives last, with an empty choices list
+		if chunk.Usage != nil {
+			result.Usage = chunk.Usage.toTokenUsage()
+		}
 
 		if len(chunk.Choices) > 0 {
 			choice := chunk.Choices[0]
@@ -372,6 +406,25 @@ func (p *OpenAIProvider) handleStream(body io.Reader, handler StreamHandler) (*C
 				chunkIndex++
 			}
 
+			// A call's ID and name come in its first fragment, its
+			// arguments spread over the rest
+			for _, d := range choice.Delta.ToolCalls {
+				tc := toolCalls.get(d.Index)
+				if d.ID != "" {
+					tc.id = d.ID
+				}
+				if d.Function.Name != "" {
+					tc.name = d.Function.Name
+					handler.OnChunk(StreamChunk{
+						Content: d.Function.Name,
+						Type:    ChunkTypeToolStart,
+						Index:   chunkIndex,
+					})
+					chunkIndex++
+				}
+				tc.args.WriteString(d.Function.Arguments)
+			}
+
 			if choice.FinishReason != "" {
 				switch choice.FinishReason {
 				case "stop":
@@ -386,6 +439,7 @@ func (p *OpenAIProvider) handleStream(body io.Reader, handler StreamHandler) (*C
 	}
 
 	result.Content = contentBuilder.String()
+	result.ToolCalls = toolCalls.toolCalls()
 	handler.OnComplete(result)
 	return result, nil
 }
@@ -414,7 +468,7 @@ func (p *OpenAIProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError("openai", resp.StatusCode, respBody)
 	}
 
 	var listResp struct {
@@ -0,0 +1,149 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIProvider_Complete(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		fmt.Fprint(w, `{
+			"model": "gpt-4o",
+			"choices": [{"message": {"role": "assistant", "content": "hi"}, "finish_reason": "length"}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 5, "total_tokens": 17},
+			"system_fingerprint": "fp_44709d6fcb"
+		}`)
+	}))
+	defer server.Close()
+
+	seed := 42
+	p := NewOpenAIProvider(WithOpenAIAPIKey("test-key"), WithOpenAIBaseURL(server.URL))
+	resp, err := p.Complete(context.Background(), &CompletionRequest{
+		Model:         "gpt-4o",
+		SystemPrompt:  "Be brief",
+		Messages:      []Message{{Role: RoleUser, Content: "Hello"}},
+		Temperature:   0,
+		MaxTokens:     64,
+		StopSequences: []string{"\n\n"},
+		Seed:          &seed,
+		ProviderParams: map[string]interface{}{
+			ParamTopP:            0.9,
+			ParamPresencePenalty: 0.5,
+			ParamTopK:            40.0, // not supported by OpenAI
+		},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+
+	msgs, _ := got["messages"].([]interface{})
+	if len(msgs) != 2 || msgs[0].(map[string]interface{})["role"] != "system" {
+		t.Errorf("expected system message first, got %v", got["messages"])
+	}
+	if temp, ok := got["temperature"]; !ok || temp != float64(0) {
+		t.Errorf("temperature = %v (present %v), want explicit 0", temp, ok)
+	}
+	if got["max_tokens"] != float64(64) {
+		t.Errorf("max_tokens = %v, want 64", got["max_tokens"])
+	}
+	if stops, _ := got["stop"].([]interface{}); len(stops) != 1 || stops[0] != "\n\n" {
+		t.Errorf("stop = %v, want [\"\\n\\n\"]", got["stop"])
+	}
+	if got["seed"] != float64(42) {
+		t.Errorf("seed = %v, want 42", got["seed"])
+	}
+	if got["top_p"] != 0.9 || got["presence_penalty"] != 0.5 {
+		t.Errorf("top_p = %v, presence_penalty = %v, want 0.9 and 0.5", got["top_p"], got["presence_penalty"])
+	}
+	for _, key := range []string{"top_k", "frequency_penalty"} {
+		if v, ok := got[key]; ok {
+			t.Errorf("%s = %v, want it omitted", key, v)
+		}
+	}
+
+	if resp.Content != "hi" || resp.FinishReason != FinishReasonLength || resp.SystemFingerprint != "fp_44709d6fcb" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.Usage.InputTokens != 12 || resp.Usage.OutputTokens != 5 || resp.Usage.TotalTokens != 17 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestOpenAIProvider_CompleteStream(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"model\":\"gpt-4o\",\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"gpt-4o\",\"choices\":[{\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2,\"total_tokens\":5}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	p := NewOpenAIProvider(WithOpenAIAPIKey("test-key"), WithOpenAIBaseURL(server.URL))
+	resp, err := p.CompleteStream(context.Background(), &CompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []Message{{Role: RoleUser, Content: "Hello"}},
+	}, &DefaultStreamHandler{})
+	if err != nil {
+		t.Fatalf("CompleteStream() error: %v", err)
+	}
+
+	if opts, _ := got["stream_options"].(map[string]interface{}); opts["include_usage"] != true {
+		t.Errorf("stream_options = %v, want include_usage", got["stream_options"])
+	}
+	if resp.Content != "Hello" || resp.FinishReason != FinishReasonStop {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.Usage.OutputTokens != 2 || resp.Usage.TotalTokens != 5 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestOpenAIProvider_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		status    int
+		retryable bool
+	}{
+		{http.StatusTooManyRequests, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusUnauthorized, false},
+		{http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"error":{"message":"nope"}}`, tt.status)
+			}))
+			defer server.Close()
+
+			p := NewOpenAIProvider(WithOpenAIAPIKey("test-key"), WithOpenAIBaseURL(server.URL))
+			_, err := p.Complete(context.Background(), &CompletionRequest{Model: "gpt-4o"})
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Provider != "openai" {
+				t.Fatalf("expected openai APIError %d, got %v", tt.status, err)
+			}
+			if IsRetryable(err) != tt.retryable {
+				t.Errorf("IsRetryable = %v, want %v", IsRetryable(err), tt.retryable)
+			}
+		})
+	}
+}
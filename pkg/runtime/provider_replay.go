@@ -0,0 +1,228 @@
+package runtime
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrReplayMiss is returned (wrapped) by a strict ReplayProvider when a
+// request has no recorded response.
+var ErrReplayMiss = errors.New("no recorded response")
+
+// RecordedExchange is one line of a recording: a request, the key it is
+// replayed by, and the response the provider returned.
+type RecordedExchange struct {
+	Key      string              `json:"key"`
+	Request  *CompletionRequest  `json:"request"`
+	Response *CompletionResponse `json:"response"`
+}
+
+// ReplayKey returns the key a ReplayProvider matches requests by: the model,
+// system prompt, messages and temperature.
+func ReplayKey(req *CompletionRequest) string {
+	data, _ := json.Marshal(struct {
+		Model        string    `json:"model"`
+		SystemPrompt string    `json:"system"`
+		Messages     []Message `json:"messages"`
+		Temperature  float64   `json:"temperature"`
+	}{
+		Model:        req.Model,
+		SystemPrompt: req.SystemPrompt,
+		Messages:     req.Messages,
+		Temperature:  req.Temperature,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Recorder writes every completion made through the providers it wraps to a
+// JSONL log that a ReplayProvider can later serve from. One Recorder may wrap
+// several providers; writes are serialised.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewRecorder creates a Recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Wrap returns a provider that forwards to inner and records each successful
+// completion.
+func (r *Recorder) Wrap(inner LLMProvider) *RecordingProvider {
+	return &RecordingProvider{inner: inner, recorder: r}
+}
+
+func (r *Recorder) record(req *CompletionRequest, resp *CompletionResponse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(RecordedExchange{Key: ReplayKey(req), Request: req, Response: resp})
+}
+
+// RecordingProvider is an LLMProvider that records the responses of the
+// provider it wraps. Create one with Recorder.Wrap.
+type RecordingProvider struct {
+	inner    LLMProvider
+	recorder *Recorder
+}
+
+// Name returns the wrapped provider's name.
+func (p *RecordingProvider) Name() string {
+	return p.inner.Name()
+}
+
+func (p *RecordingProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	resp, err := p.inner.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.recorder.record(req, resp); err != nil {
+		return nil, fmt.Errorf("recording response: %w", err)
+	}
+	return resp, nil
+}
+
+func (p *RecordingProvider) CompleteStream(ctx context.Context, req *CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	resp, err := p.inner.CompleteStream(ctx, req, handler)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.recorder.record(req, resp); err != nil {
+		return nil, fmt.Errorf("recording response: %w", err)
+	}
+	return resp, nil
+}
+
+func (p *RecordingProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return p.inner.ListModels(ctx)
+}
+
+// ReplayProvider answers completion requests from a recording made by a
+// Recorder. Requests are matched by ReplayKey; when the same request was
+// recorded several times its responses are returned in recorded order, and
+// the last one is repeated once they run out.
+//
+// By default a request without a recorded response fails with ErrReplayMiss.
+// WithReplayFallback makes the provider forward such requests instead.
+type ReplayProvider struct {
+	responses map[string][]*CompletionResponse
+	served    map[string]int
+	fallback  LLMProvider
+	mu        sync.Mutex
+}
+
+// ReplayOption is a functional option for configuring ReplayProvider.
+type ReplayOption func(*ReplayProvider)
+
+// WithReplayFallback forwards requests that have no recorded response to
+// provider instead of failing.
+func WithReplayFallback(provider LLMProvider) ReplayOption {
+	return func(p *ReplayProvider) {
+		p.fallback = provider
+	}
+}
+
+// NewReplayProvider reads a recording from r.
+func NewReplayProvider(r io.Reader, opts ...ReplayOption) (*ReplayProvider, error) {
+	p := &ReplayProvider{
+		responses: make(map[string][]*CompletionResponse),
+		served:    make(map[string]int),
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var ex RecordedExchange
+		if err := json.Unmarshal(scanner.Bytes(), &ex); err != nil {
+			return nil, fmt.Errorf("recording line %d: %w", line, err)
+		}
+		if ex.Response == nil {
+			return nil, fmt.Errorf("recording line %d: missing response", line)
+		}
+		key := ex.Key
+		if ex.Request != nil {
+			key = ReplayKey(ex.Request)
+		}
+		p.responses[key] = append(p.responses[key], ex.Response)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading recording: %w", err)
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p, nil
+}
+
+func (p *ReplayProvider) Name() string {
+	return "replay"
+}
+
+// next returns the next recorded response for req, if any.
+func (p *ReplayProvider) next(req *CompletionRequest) (*CompletionResponse, bool) {
+	key := ReplayKey(req)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	recorded := p.responses[key]
+	if len(recorded) == 0 {
+		return nil, false
+	}
+	i := p.served[key]
+	if i >= len(recorded) {
+		i = len(recorded) - 1
+	}
+	p.served[key]++
+
+	resp := *recorded[i]
+	return &resp, true
+}
+
+func (p *ReplayProvider) miss(req *CompletionRequest) error {
+	return fmt.Errorf("%w for model %q", ErrReplayMiss, req.Model)
+}
+
+func (p *ReplayProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if resp, ok := p.next(req); ok {
+		return resp, nil
+	}
+	if p.fallback != nil {
+		return p.fallback.Complete(ctx, req)
+	}
+	return nil, p.miss(req)
+}
+
+func (p *ReplayProvider) CompleteStream(ctx context.Context, req *CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	if resp, ok := p.next(req); ok {
+		handler.OnChunk(StreamChunk{Content: resp.Content, Type: ChunkTypeContent})
+		handler.OnComplete(resp)
+		return resp, nil
+	}
+	if p.fallback != nil {
+		return p.fallback.CompleteStream(ctx, req, handler)
+	}
+	err := p.miss(req)
+	handler.OnError(err)
+	return nil, err
+}
+
+func (p *ReplayProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if p.fallback != nil {
+		return p.fallback.ListModels(ctx)
+	}
+	return nil, nil
+}
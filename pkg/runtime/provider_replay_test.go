@@ -0,0 +1,142 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	req := func(prompt string, temperature float64) *CompletionRequest {
+		return &CompletionRequest{
+			Model:        "mock-model",
+			SystemPrompt: "be brief",
+			Messages:     []Message{{Role: RoleUser, Content: prompt}},
+			Temperature:  temperature,
+		}
+	}
+
+	var log bytes.Buffer
+	recorder := NewRecorder(&log)
+	recording := recorder.Wrap(NewMockProvider(WithMockResponses(
+		MockResponse{Content: "first", Usage: TokenUsage{InputTokens: 3, OutputTokens: 1, TotalTokens: 4}},
+		MockResponse{Content: "second"},
+		MockResponse{Content: "other"},
+	)))
+
+	for _, r := range []*CompletionRequest{req("hi", 0), req("hi", 0), req("bye", 0)} {
+		if _, err := recording.Complete(ctx, r); err != nil {
+			t.Fatalf("Complete() error: %v", err)
+		}
+	}
+	if got := strings.Count(log.String(), "\n"); got != 3 {
+		t.Fatalf("recorded %d lines, want 3", got)
+	}
+
+	t.Run("strict replay", func(t *testing.T) {
+		replay, err := NewReplayProvider(bytes.NewReader(log.Bytes()))
+		if err != nil {
+			t.Fatalf("NewReplayProvider() error: %v", err)
+		}
+
+		// Repeated requests replay in recorded order, then repeat the last
+		for _, want := range []string{"first", "second", "second"} {
+			resp, err := replay.Complete(ctx, req("hi", 0))
+			if err != nil {
+				t.Fatalf("Complete() error: %v", err)
+			}
+			if resp.Content != want {
+				t.Errorf("Content = %q, want %q", resp.Content, want)
+			}
+		}
+
+		var chunks []string
+		handler := &CallbackStreamHandler{ChunkFunc: func(c StreamChunk) { chunks = append(chunks, c.Content) }}
+		resp, err := replay.CompleteStream(ctx, req("bye", 0), handler)
+		if err != nil || resp.Content != "other" || len(chunks) != 1 {
+			t.Errorf("CompleteStream() = %v, %v with chunks %v", resp, err, chunks)
+		}
+
+		_, err = replay.Complete(ctx, req("hi", 0.7))
+		if !errors.Is(err, ErrReplayMiss) {
+			t.Errorf("different temperature: error = %v, want ErrReplayMiss", err)
+		}
+	})
+
+	t.Run("lenient replay falls through", func(t *testing.T) {
+		fallback := NewMockProvider(WithMockResponses(MockResponse{Content: "live"}))
+		replay, err := NewReplayProvider(bytes.NewReader(log.Bytes()), WithReplayFallback(fallback))
+		if err != nil {
+			t.Fatalf("NewReplayProvider() error: %v", err)
+		}
+
+		resp, err := replay.Complete(ctx, req("unseen", 0))
+		if err != nil || resp.Content != "live" {
+			t.Errorf("Complete() = %v, %v, want the fallback's response", resp, err)
+		}
+		resp, err = replay.Complete(ctx, req("hi", 0))
+		if err != nil || resp.Content != "first" {
+			t.Errorf("Complete() = %v, %v, want the recorded response", resp, err)
+		}
+		if n := len(fallback.GetRequests()); n != 1 {
+			t.Errorf("fallback received %d requests, want 1", n)
+		}
+	})
+
+	t.Run("malformed recording", func(t *testing.T) {
+		_, err := NewReplayProvider(strings.NewReader("{\"key\":\"x\",\"response\":{}}\nnot json\n"))
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("error = %v, want one naming line 2", err)
+		}
+	})
+}
+
+func TestReplay_PipelineRun(t *testing.T) {
+	source := `
+agent "writer" {
+	model: "mock-model"
+}
+
+pipeline "draft" {
+	step "outline" {
+		use: agent("writer")
+		prompt: "Outline"
+	}
+	step "write" {
+		use: agent("writer")
+		prompt: step("outline").output
+	}
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+	pipeline, _ := ws.GetEntityByName("pipeline", "draft")
+
+	var log bytes.Buffer
+	live := NewSequenceProvider("an outline", "the essay")
+	rt := New(ws, WithProvider("mock", NewRecorder(&log).Wrap(live)))
+	recorded, err := rt.Execute(context.Background(), pipeline)
+	if err != nil {
+		t.Fatalf("recording run: %v", err)
+	}
+
+	replay, err := NewReplayProvider(&log)
+	if err != nil {
+		t.Fatalf("NewReplayProvider() error: %v", err)
+	}
+	rt = New(ws, WithProvider("mock", replay))
+	replayed, err := rt.Execute(context.Background(), pipeline)
+	if err != nil {
+		t.Fatalf("replayed run: %v", err)
+	}
+
+	if replayed.Output != recorded.Output || replayed.Output != "the essay" {
+		t.Errorf("replayed output = %v, recorded %v", replayed.Output, recorded.Output)
+	}
+}
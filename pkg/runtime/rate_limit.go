@@ -0,0 +1,88 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that paces requests to a provider. The
+// bucket holds up to burst tokens and refills at a steady rate; each
+// request takes a token, waiting for one if the bucket is empty. Waiters
+// are served in the order they arrive. A RateLimiter is safe for
+// concurrent use, so parallel steps sharing a provider share its limit.
+type RateLimiter struct {
+	rate  float64 // tokens added per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64   // may go negative while requests wait for tokens
+	last   time.Time // when tokens was last brought up to date
+
+	// now and sleep are the clock, replaced in tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRateLimiter returns a RateLimiter allowing requestsPerSecond requests
+// on average and bursts of up to burst requests. The bucket starts full. A
+// burst below 1 is treated as 1.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+}
+
+// Wait takes a token, blocking until one is available or ctx is done. It
+// returns ctx.Err() if the context ended the wait, in which case the token
+// it reserved is handed back.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.rate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if err := l.sleep(ctx, wait); err != nil {
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// WithRateLimit paces requests to the named provider, as reported by its
+// Name method, to requestsPerSecond on average with bursts of up to burst
+// requests. Every request waits its turn before it is sent, including
+// retries and requests from steps running in parallel, so backoff is left
+// to deal with rate limiting the provider reports itself.
+func WithRateLimit(provider string, requestsPerSecond float64, burst int) Option {
+	return func(r *Runtime) {
+		if r.rateLimiters == nil {
+			r.rateLimiters = make(map[string]*RateLimiter)
+		}
+		r.rateLimiters[provider] = NewRateLimiter(requestsPerSecond, burst)
+	}
+}
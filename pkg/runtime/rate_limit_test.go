@@ -0,0 +1,150 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+// fakeClock is a clock for RateLimiter that only moves when a waiter
+// sleeps, so tests can check pacing without waiting.
+type fakeClock struct {
+	mu    sync.Mutex
+	t     time.Time
+	slept time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+	c.slept += d
+	return nil
+}
+
+func (c *fakeClock) attach(l *RateLimiter) {
+	l.now = c.now
+	l.sleep = c.sleep
+}
+
+func TestRateLimiter(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		calls int
+	}{
+		{name: "steady", rate: 2, burst: 1, calls: 10},
+		{name: "burst", rate: 5, burst: 4, calls: 20},
+		{name: "slow", rate: 0.5, burst: 2, calls: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			limiter := NewRateLimiter(tt.rate, tt.burst)
+			clock.attach(limiter)
+
+			start := clock.now()
+			var times []time.Duration
+			for i := 0; i < tt.calls; i++ {
+				if err := limiter.Wait(context.Background()); err != nil {
+					t.Fatalf("Wait() error: %v", err)
+				}
+				times = append(times, clock.now().Sub(start))
+			}
+
+			// No window may hold more calls than the burst plus what the
+			// rate refills over it
+			for i := range times {
+				for j := i; j < len(times); j++ {
+					window := (times[j] - times[i]).Seconds()
+					if n := j - i + 1; float64(n) > float64(tt.burst)+tt.rate*window+1e-9 {
+						t.Fatalf("%d calls in %.2fs, over the ceiling of %d + %.1f/s", n, window, tt.burst, tt.rate)
+					}
+				}
+			}
+
+			// and the limiter shouldn't wait longer than it has to
+			want := time.Duration(float64(tt.calls-tt.burst) / tt.rate * float64(time.Second))
+			if got := times[len(times)-1]; got != want {
+				t.Errorf("last call at %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_CanceledWait(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewRateLimiter(1, 1)
+	clock.attach(limiter)
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Wait() = %v, want context.Canceled", err)
+	}
+
+	// The canceled wait handed its token back, so the next waits one second
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error: %v", err)
+	}
+	if clock.slept != time.Second {
+		t.Errorf("slept %v, want 1s", clock.slept)
+	}
+}
+
+func TestExecute_ProviderRateLimit(t *testing.T) {
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, `
+agent "a" {
+	model: "mock-model"
+}
+
+pipeline "p" {
+	step "one" {
+		use: agent("a")
+	}
+	step "two" {
+		use: agent("a")
+	}
+	step "three" {
+		use: agent("a")
+	}
+}
+`))
+
+	mockProvider := NewMockProvider(WithMockResponses(MockResponse{Content: "ok"}))
+	rt := New(ws, WithProvider("mock", mockProvider), WithRateLimit("mock", 1, 1))
+	clock := newFakeClock()
+	clock.attach(rt.rateLimiters["mock"])
+
+	if _, err := rt.ExecuteByName(context.Background(), "pipeline", "p"); err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	if n := len(mockProvider.GetRequests()); n != 3 {
+		t.Fatalf("got %d requests, want 3", n)
+	}
+	if clock.slept != 2*time.Second {
+		t.Errorf("waited %v for the rate limit, want 2s", clock.slept)
+	}
+}
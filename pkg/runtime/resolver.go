@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/shellkjell/langspace/pkg/ast"
@@ -113,49 +115,89 @@ func toString(v interface{}) string {
 }
 
 // interpolateString handles template interpolation in strings.
-// Supports {{variable}} and {{expression}} syntax.
+// Supports {{variable}}, {{expression}} and {{type("name").path}} syntax.
+// Substituted values are not interpolated again, and \{{ stands for a
+// literal {{.
 func (r *Resolver) interpolateString(s string) (string, error) {
-	result := s
+	var b strings.Builder
+	rest := s
 
-	// Find and replace {{...}} patterns
 	for {
-		start := strings.Index(result, "{{")
+		start := strings.Index(rest, "{{")
 		if start == -1 {
 			break
 		}
+		if start > 0 && rest[start-1] == '\\' {
+			b.WriteString(rest[:start-1])
+			b.WriteString("{{")
+			rest = rest[start+2:]
+			continue
+		}
 
-		end := strings.Index(result[start:], "}}")
+		end := strings.Index(rest[start:], "}}")
 		if end == -1 {
 			break
 		}
 		end += start + 2
 
-		expr := strings.TrimSpace(result[start+2 : end-2])
+		expr := strings.TrimSpace(rest[start+2 : end-2])
 		value, err := r.resolveExpression(expr)
 		if err != nil {
 			return "", fmt.Errorf("failed to interpolate {{%s}}: %w", expr, err)
 		}
 
-		result = result[:start] + toString(value) + result[end:]
+		b.WriteString(rest[:start])
+		b.WriteString(toString(value))
+		rest = rest[end:]
 	}
 
-	return result, nil
+	b.WriteString(rest)
+	return b.String(), nil
+}
+
+// referenceExpr matches a reference in an interpolated expression, such as
+// file("strategy") or agent("planner").instruction, capturing the type, the
+// name and any path after it.
+var referenceExpr = regexp.MustCompile(`^([a-z_]+)\("([^"]*)"\)((?:\.[\w-]+|\[\d+\])*)$`)
+
+// interpolatedReferenceTypes are the reference types resolveExpression
+// recognises, so that text such as {{note("x")}} is left as a literal.
+var interpolatedReferenceTypes = map[string]bool{
+	"agent":    true,
+	"file":     true,
+	"tool":     true,
+	"pipeline": true,
+	"intent":   true,
+	"script":   true,
+	"step":     true,
+	"env":      true,
 }
 
 // resolveExpression parses and resolves a string expression.
 func (r *Resolver) resolveExpression(expr string) (interface{}, error) {
 	expr = strings.TrimSpace(expr)
 
-	// Handle variable references: $var or var
+	// Handle variable references: $var, $var.field or $var.items[0]
 	if strings.HasPrefix(expr, "$") {
-		return r.resolveVariable(expr[1:])
+		base, path := splitPath(expr[1:])
+		return r.resolveVariablePath(base, path)
+	}
+
+	// Handle references: file("name"), agent("name").instruction
+	if m := referenceExpr.FindStringSubmatch(expr); m != nil && interpolatedReferenceTypes[m[1]] {
+		ref := ast.ReferenceValue{Type: m[1], Name: m[2]}
+		if m[3] != "" {
+			_, ref.Path = splitPath("ref" + m[3])
+		}
+		return r.resolveReference(ref)
 	}
 
 	// Handle property access: params.field, step.output
-	if strings.Contains(expr, ".") {
-		parts := strings.SplitN(expr, ".", 2)
-		base := parts[0]
-		path := strings.Split(parts[1], ".")
+	if strings.ContainsAny(expr, ".[") {
+		base, path := splitPath(expr)
+		if len(path) == 0 {
+			return nil, fmt.Errorf("cannot resolve expression: %s", expr)
+		}
 
 		// Check if base is a variable
 		if baseVal, ok := r.ctx.GetVariable(base); ok {
@@ -221,13 +263,56 @@ func (r *Resolver) resolveVariable(name string) (interface{}, error) {
 	return nil, fmt.Errorf("undefined variable: $%s", name)
 }
 
+// resolveVariablePath resolves a path such as $state.pegs.A[0] against the
+// variable name. Values reached through literal objects and arrays are
+// resolved in turn, so the result is a plain Go value.
+func (r *Resolver) resolveVariablePath(name string, path []string) (interface{}, error) {
+	val, err := r.resolveVariable(name)
+	if err != nil {
+		return nil, err
+	}
+
+	nested, err := getNestedValue(val, path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve $%s: %w", formatPath(append([]string{name}, path...)), err)
+	}
+	if v, ok := nested.(ast.Value); ok {
+		return r.Resolve(v)
+	}
+	return nested, nil
+}
+
+// propertyReferenceTypes are the entity types whose references can be
+// followed by a property path, as in agent("reviewer").instruction. MCP
+// references are not among them: mcp("fs").read_file names a tool.
+var propertyReferenceTypes = map[string]bool{
+	"agent":    true,
+	"file":     true,
+	"tool":     true,
+	"pipeline": true,
+	"intent":   true,
+	"script":   true,
+}
+
 // resolveReference resolves an entity reference.
 func (r *Resolver) resolveReference(ref ast.ReferenceValue) (interface{}, error) {
+	if len(ref.Path) > 0 && propertyReferenceTypes[ref.Type] {
+		entity, found := r.workspace.ws.GetEntityByName(ref.Type, ref.Name)
+		if !found {
+			return nil, fmt.Errorf("%s not found: %s", ref.Type, ref.Name)
+		}
+		return r.resolveEntityProperty(entity, ref.Path)
+	}
+
 	switch ref.Type {
 	case "agent":
 		return r.workspace.GetAgent(ref.Name)
 
 	case "file":
+		// A file entity of that name takes precedence over a path on disk
+		if entity, found := r.workspace.ws.GetEntityByName("file", ref.Name); found {
+			return r.resolveFileEntity(entity)
+		}
 		return r.resolveFileReference(ref.Name)
 
 	case "step":
@@ -301,6 +386,40 @@ func (r *Resolver) resolveReference(ref ast.ReferenceValue) (interface{}, error)
 	}
 }
 
+// resolveEntityProperty resolves a path into an entity: the first element
+// names one of its properties, which is resolved in turn, and any further
+// elements index into the result.
+func (r *Resolver) resolveEntityProperty(entity ast.Entity, path []string) (interface{}, error) {
+	prop, ok := entity.GetProperty(path[0])
+	if !ok {
+		return nil, fmt.Errorf("%s %q has no property %q", entity.Type(), entity.Name(), path[0])
+	}
+	val, err := r.Resolve(prop)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %s: %w", entity.Type(), entity.Name(), path[0], err)
+	}
+	if len(path) == 1 {
+		return val, nil
+	}
+	return getNestedValue(val, path[1:])
+}
+
+// resolveFileEntity resolves a file entity to its contents: its inline
+// contents if it has them, otherwise the file at its path.
+func (r *Resolver) resolveFileEntity(entity ast.Entity) (interface{}, error) {
+	if contents, ok := entity.GetProperty("contents"); ok {
+		return r.Resolve(contents)
+	}
+	if path, ok := entity.GetProperty("path"); ok {
+		p, err := r.ResolveString(path)
+		if err != nil {
+			return nil, fmt.Errorf("file %q: %w", entity.Name(), err)
+		}
+		return r.resolveFileReference(p)
+	}
+	return nil, fmt.Errorf("file %q has neither contents nor a path", entity.Name())
+}
+
 // resolveFileReference resolves a file reference.
 func (r *Resolver) resolveFileReference(path string) (interface{}, error) {
 	// Check if it's a glob pattern
@@ -359,12 +478,7 @@ func (r *Resolver) resolvePropertyAccess(pa ast.PropertyAccessValue) (interface{
 	// Handle variable prefix ($varname.property)
 	base := pa.Base
 	if strings.HasPrefix(base, "$") {
-		varName := base[1:]
-		varVal, err := r.resolveVariable(varName)
-		if err != nil {
-			return nil, err
-		}
-		return getNestedValue(varVal, pa.Path)
+		return r.resolveVariablePath(base[1:], pa.Path)
 	}
 
 	// Resolve base as a variable or special reference
@@ -582,7 +696,11 @@ func (r *Resolver) resolveFunctionCall(fc ast.FunctionCallValue) (interface{}, e
 
 	case "file":
 		if len(args) > 0 {
-			return r.resolveFileReference(toString(args[0]))
+			name := toString(args[0])
+			if entity, found := r.workspace.ws.GetEntityByName("file", name); found {
+				return r.resolveFileEntity(entity)
+			}
+			return r.resolveFileReference(name)
 		}
 		return nil, fmt.Errorf("file() requires a path argument")
 
@@ -754,42 +872,137 @@ func toFloat(v interface{}) (float64, bool) {
 	return 0, false
 }
 
-// getNestedValue retrieves a nested value from a complex object.
+// getNestedValue walks path through obj. Segments of the form "[n]" index
+// into arrays; other segments look up object keys or entity properties. A
+// missing segment is reported together with the part of the path that did
+// resolve.
 func getNestedValue(obj interface{}, path []string) (interface{}, error) {
-	if len(path) == 0 {
-		return obj, nil
+	current := obj
+	for i, segment := range path {
+		next, err := nestedSegment(current, segment)
+		if err != nil {
+			if i > 0 {
+				return nil, fmt.Errorf("%w (after %s)", err, formatPath(path[:i]))
+			}
+			return nil, err
+		}
+		current = next
 	}
+	return current, nil
+}
 
-	current := obj
-	for _, key := range path {
+// nestedSegment resolves a single path segment against current.
+func nestedSegment(current interface{}, segment string) (interface{}, error) {
+	if strings.HasPrefix(segment, "[") && strings.HasSuffix(segment, "]") {
+		index, err := strconv.Atoi(segment[1 : len(segment)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid index: %s", segment)
+		}
+
+		var elements []interface{}
 		switch v := current.(type) {
-		case map[string]interface{}:
-			val, ok := v[key]
-			if !ok {
-				return nil, fmt.Errorf("key not found: %s", key)
+		case []interface{}:
+			elements = v
+		case []string:
+			for _, e := range v {
+				elements = append(elements, e)
 			}
-			current = val
-
-		case map[string]string:
-			val, ok := v[key]
-			if !ok {
-				return nil, fmt.Errorf("key not found: %s", key)
+		case ast.ArrayValue:
+			for _, e := range v.Elements {
+				elements = append(elements, e)
 			}
-			current = val
+		default:
+			return nil, fmt.Errorf("cannot index type %T with %s", current, segment)
+		}
 
-		case ast.Entity:
-			val, ok := v.GetProperty(key)
-			if !ok {
-				return nil, fmt.Errorf("property not found: %s", key)
-			}
-			current = val
+		if index < 0 || index >= len(elements) {
+			return nil, fmt.Errorf("index %d out of range (length %d)", index, len(elements))
+		}
+		return elements[index], nil
+	}
+
+	switch v := current.(type) {
+	case map[string]interface{}:
+		val, ok := v[segment]
+		if !ok {
+			return nil, fmt.Errorf("key not found: %s", segment)
+		}
+		return val, nil
+
+	case map[string]string:
+		val, ok := v[segment]
+		if !ok {
+			return nil, fmt.Errorf("key not found: %s", segment)
+		}
+		return val, nil
 
+	case ast.ObjectValue:
+		val, ok := v.Properties[segment]
+		if !ok {
+			return nil, fmt.Errorf("key not found: %s", segment)
+		}
+		return val, nil
+
+	case ast.Entity:
+		val, ok := v.GetProperty(segment)
+		if !ok {
+			return nil, fmt.Errorf("property not found: %s", segment)
+		}
+		return val, nil
+
+	default:
+		return nil, fmt.Errorf("cannot access property %s on type %T", segment, current)
+	}
+}
+
+// splitPath splits an expression such as "state.pegs.A[0]" into its base
+// ("state") and path segments (["pegs", "A", "[0]"]).
+func splitPath(expr string) (string, []string) {
+	var segments []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			segments = append(segments, current.String())
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; c {
+		case '.':
+			flush()
+		case '[':
+			flush()
+			end := strings.IndexByte(expr[i:], ']')
+			if end == -1 {
+				current.WriteString(expr[i:])
+				i = len(expr)
+				continue
+			}
+			segments = append(segments, expr[i:i+end+1])
+			i += end
 		default:
-			return nil, fmt.Errorf("cannot access property %s on type %T", key, current)
+			current.WriteByte(c)
 		}
 	}
+	flush()
 
-	return current, nil
+	if len(segments) == 0 {
+		return "", nil
+	}
+	return segments[0], segments[1:]
+}
+
+// formatPath renders path segments the way they are written in source.
+func formatPath(path []string) string {
+	var b strings.Builder
+	for i, segment := range path {
+		if i > 0 && !strings.HasPrefix(segment, "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(segment)
+	}
+	return b.String()
 }
 
 // formatDate formats a date component.
@@ -1,6 +1,11 @@
 package runtime
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/shellkjell/langspace/pkg/ast"
@@ -164,3 +169,261 @@ func TestResolver_MethodCalls(t *testing.T) {
 		})
 	}
 }
+
+func TestResolver_Arrays(t *testing.T) {
+	ws := workspace.New()
+	ctx := &ExecutionContext{
+		Runtime:   New(ws),
+		Workspace: ws,
+		Variables: map[string]interface{}{
+			"input": "hello",
+		},
+		StepOutputs: map[string]interface{}{
+			"analyze": "analysis",
+		},
+	}
+	resolver := NewResolver(ctx)
+
+	value := ast.ArrayValue{Elements: []ast.Value{
+		ast.VariableValue{Name: "input"},
+		ast.ReferenceValue{Type: "step", Name: "analyze", Path: []string{"output"}},
+		ast.ArrayValue{Elements: []ast.Value{ast.NumberValue{Value: 1}, ast.VariableValue{Name: "input"}}},
+		ast.ObjectValue{Properties: map[string]ast.Value{"k": ast.VariableValue{Name: "input"}}},
+	}}
+
+	got, err := resolver.Resolve(value)
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+
+	want := []interface{}{
+		"hello",
+		"analysis",
+		[]interface{}{float64(1), "hello"},
+		map[string]interface{}{"k": "hello"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve() = %#v, want %#v", got, want)
+	}
+
+	t.Run("error names the element", func(t *testing.T) {
+		_, err := resolver.Resolve(ast.ArrayValue{Elements: []ast.Value{
+			ast.StringValue{Value: "ok"},
+			ast.VariableValue{Name: "missing"},
+		}})
+		if err == nil || !strings.Contains(err.Error(), "array element 1") {
+			t.Errorf("expected error naming element 1, got %v", err)
+		}
+	})
+}
+
+func TestResolver_VariablePaths(t *testing.T) {
+	ws := workspace.New()
+	ctx := &ExecutionContext{
+		Runtime:   New(ws),
+		Workspace: ws,
+		Variables: map[string]interface{}{
+			"state": map[string]interface{}{
+				"pegs": map[string]interface{}{
+					"A": []interface{}{float64(3), float64(2), float64(1)},
+					"B": []interface{}{},
+				},
+			},
+			"literal": ast.ObjectValue{Properties: map[string]ast.Value{
+				"names": ast.ArrayValue{Elements: []ast.Value{ast.StringValue{Value: "x"}}},
+			}},
+		},
+	}
+	resolver := NewResolver(ctx)
+
+	tests := []struct {
+		name    string
+		value   ast.Value
+		want    interface{}
+		wantErr string
+	}{
+		{
+			name:  "nested key",
+			value: ast.PropertyAccessValue{Base: "$state", Path: []string{"pegs", "A"}},
+			want:  []interface{}{float64(3), float64(2), float64(1)},
+		},
+		{
+			name:  "array index",
+			value: ast.PropertyAccessValue{Base: "$state", Path: []string{"pegs", "A", "[0]"}},
+			want:  float64(3),
+		},
+		{
+			name:  "literal object and array",
+			value: ast.PropertyAccessValue{Base: "$literal", Path: []string{"names", "[0]"}},
+			want:  "x",
+		},
+		{
+			name:  "interpolated path",
+			value: ast.StringValue{Value: "top of A: {{$state.pegs.A[2]}}"},
+			want:  "top of A: 1",
+		},
+		{
+			name:    "missing key",
+			value:   ast.PropertyAccessValue{Base: "$state", Path: []string{"pegs", "C"}},
+			wantErr: "cannot resolve $state.pegs.C: key not found: C (after pegs)",
+		},
+		{
+			name:    "index out of range",
+			value:   ast.PropertyAccessValue{Base: "$state", Path: []string{"pegs", "B", "[0]"}},
+			wantErr: "index 0 out of range (length 0)",
+		},
+		{
+			name:    "index into non-array",
+			value:   ast.PropertyAccessValue{Base: "$state", Path: []string{"[1]"}},
+			wantErr: "cannot index type map[string]interface {} with [1]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.Resolve(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolver_EntityReferences(t *testing.T) {
+	dir := t.TempDir()
+	onDisk := filepath.Join(dir, "rules.md")
+	if err := os.WriteFile(onDisk, []byte("Never place a larger disk on a smaller one."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, fmt.Sprintf(`
+file "strategy" {
+	contents: "Move the smallest disk every other turn."
+}
+
+file "rules" {
+	path: %q
+}
+
+file "prompt" {
+	contents: "Strategy: {{agent(\"planner\").instruction}}"
+}
+
+agent "planner" {
+	model: "mock-model"
+	instruction: file("strategy")
+	tags: ["hanoi", "planning"]
+}
+`, onDisk)))
+	resolver := NewResolver(&ExecutionContext{
+		Runtime:   New(ws),
+		Workspace: ws,
+		Variables: map[string]interface{}{"raw": "{{$raw}}"},
+	})
+
+	tests := []struct {
+		name    string
+		value   ast.Value
+		want    interface{}
+		wantErr string
+	}{
+		{
+			name:  "file entity contents",
+			value: ast.ReferenceValue{Type: "file", Name: "strategy"},
+			want:  "Move the smallest disk every other turn.",
+		},
+		{
+			name:  "file entity path",
+			value: ast.ReferenceValue{Type: "file", Name: "rules"},
+			want:  "Never place a larger disk on a smaller one.",
+		},
+		{
+			name:  "path on disk",
+			value: ast.ReferenceValue{Type: "file", Name: onDisk},
+			want:  "Never place a larger disk on a smaller one.",
+		},
+		{
+			name:  "file call",
+			value: ast.FunctionCallValue{Function: "file", Arguments: []ast.Value{ast.StringValue{Value: "strategy"}}},
+			want:  "Move the smallest disk every other turn.",
+		},
+		{
+			name:  "agent property resolved in turn",
+			value: ast.ReferenceValue{Type: "agent", Name: "planner", Path: []string{"instruction"}},
+			want:  "Move the smallest disk every other turn.",
+		},
+		{
+			name:  "nested property path",
+			value: ast.ReferenceValue{Type: "agent", Name: "planner", Path: []string{"tags", "[1]"}},
+			want:  "planning",
+		},
+		{
+			name:  "interpolated reference",
+			value: ast.StringValue{Value: `Rules: {{ file("rules") }} Tag: {{agent("planner").tags[0]}}`},
+			want:  "Rules: Never place a larger disk on a smaller one. Tag: hanoi",
+		},
+		{
+			name:  "nested interpolation",
+			value: ast.StringValue{Value: `{{file("prompt")}}`},
+			want:  "Strategy: Move the smallest disk every other turn.",
+		},
+		{
+			name:  "escaped braces",
+			value: ast.StringValue{Value: `Write \{{file("strategy")}} literally`},
+			want:  `Write {{file("strategy")}} literally`,
+		},
+		{
+			name:  "values not interpolated again",
+			value: ast.StringValue{Value: "raw: {{$raw}}"},
+			want:  "raw: {{$raw}}",
+		},
+		{
+			name:  "unknown call left literal",
+			value: ast.StringValue{Value: `{{note("x")}}`},
+			want:  `note("x")`,
+		},
+		{
+			name:    "interpolated missing entity",
+			value:   ast.StringValue{Value: `{{agent("solver").instruction}}`},
+			wantErr: `failed to interpolate {{agent("solver").instruction}}: agent not found: solver`,
+		},
+		{
+			name:    "missing property",
+			value:   ast.ReferenceValue{Type: "agent", Name: "planner", Path: []string{"strategy"}},
+			wantErr: `agent "planner" has no property "strategy"`,
+		},
+		{
+			name:    "missing entity",
+			value:   ast.ReferenceValue{Type: "agent", Name: "solver", Path: []string{"instruction"}},
+			wantErr: "agent not found: solver",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.Resolve(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// executionResultJSON is the wire form of ExecutionResult. Errors are
+// encoded as their message and outputs as already-encoded JSON.
+type executionResultJSON struct {
+	Success     bool                   `json:"success"`
+	Output      json.RawMessage        `json:"output,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Duration    time.Duration          `json:"duration"`
+	StepResults map[string]*StepResult `json:"step_results,omitempty"`
+	Metadata    map[string]string      `json:"metadata,omitempty"`
+	TokensUsed  TokenUsage             `json:"tokens_used"`
+	CostUSD     float64                `json:"cost_usd,omitempty"`
+}
+
+type stepResultJSON struct {
+	Name       string          `json:"name"`
+	Success    bool            `json:"success"`
+	Output     json.RawMessage `json:"output,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Duration   time.Duration   `json:"duration"`
+	StartTime  time.Time       `json:"start_time"`
+	EndTime    time.Time       `json:"end_time"`
+	TokensUsed TokenUsage      `json:"tokens_used"`
+}
+
+// MarshalJSON encodes the result in a stable schema for downstream tooling.
+// The error is encoded as its message, and an Output that cannot be encoded
+// as JSON is encoded as its fmt.Sprint form instead of failing.
+func (r ExecutionResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(executionResultJSON{
+		Success:     r.Success,
+		Output:      encodeOutput(r.Output),
+		Error:       errorString(r.Error),
+		Duration:    r.Duration,
+		StepResults: r.StepResults,
+		Metadata:    r.Metadata,
+		TokensUsed:  r.TokensUsed,
+		CostUSD:     r.CostUSD,
+	})
+}
+
+// UnmarshalJSON decodes a result written by MarshalJSON. Outputs decode to
+// their generic JSON form (string, float64, map, slice) and errors to plain
+// errors carrying the original message.
+func (r *ExecutionResult) UnmarshalJSON(data []byte) error {
+	var aux executionResultJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	output, err := decodeOutput(aux.Output)
+	if err != nil {
+		return fmt.Errorf("decoding output: %w", err)
+	}
+
+	*r = ExecutionResult{
+		Success:     aux.Success,
+		Output:      output,
+		Error:       stringError(aux.Error),
+		Duration:    aux.Duration,
+		StepResults: aux.StepResults,
+		Metadata:    aux.Metadata,
+		TokensUsed:  aux.TokensUsed,
+		CostUSD:     aux.CostUSD,
+	}
+	return nil
+}
+
+// MarshalJSON encodes the step result; see ExecutionResult.MarshalJSON.
+func (s StepResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(stepResultJSON{
+		Name:       s.Name,
+		Success:    s.Success,
+		Output:     encodeOutput(s.Output),
+		Error:      errorString(s.Error),
+		Duration:   s.Duration,
+		StartTime:  s.StartTime,
+		EndTime:    s.EndTime,
+		TokensUsed: s.TokensUsed,
+	})
+}
+
+// UnmarshalJSON decodes a step result written by MarshalJSON.
+func (s *StepResult) UnmarshalJSON(data []byte) error {
+	var aux stepResultJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	output, err := decodeOutput(aux.Output)
+	if err != nil {
+		return fmt.Errorf("decoding output of step %q: %w", aux.Name, err)
+	}
+
+	*s = StepResult{
+		Name:       aux.Name,
+		Success:    aux.Success,
+		Output:     output,
+		Error:      stringError(aux.Error),
+		Duration:   aux.Duration,
+		StartTime:  aux.StartTime,
+		EndTime:    aux.EndTime,
+		TokensUsed: aux.TokensUsed,
+	}
+	return nil
+}
+
+// encodeOutput encodes an output value, falling back to its fmt.Sprint form
+// for values encoding/json rejects (channels, funcs, cycles, NaN), and to its
+// type name if its MarshalJSON panics.
+func encodeOutput(v interface{}) (raw json.RawMessage) {
+	if v == nil {
+		return nil
+	}
+
+	defer func() {
+		if recover() != nil {
+			raw, _ = json.Marshal(fmt.Sprintf("%T", v))
+		}
+	}()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return data
+}
+
+func decodeOutput(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func stringError(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,114 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type panickingMarshaler struct{}
+
+func (panickingMarshaler) MarshalJSON() ([]byte, error) { panic("boom") }
+
+func TestExecutionResult_JSONRoundTrip(t *testing.T) {
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	result := &ExecutionResult{
+		Success:  false,
+		Output:   map[string]interface{}{"summary": "done", "score": 0.5},
+		Error:    errors.New("step \"review\" failed"),
+		Duration: 1500 * time.Millisecond,
+		StepResults: map[string]*StepResult{
+			"draft": {
+				Name:      "draft",
+				Success:   true,
+				Output:    "first draft",
+				Duration:  time.Second,
+				StartTime: start,
+				EndTime:   start.Add(time.Second),
+			},
+			"review": {
+				Name:      "review",
+				Error:     errors.New("provider unavailable"),
+				StartTime: start.Add(time.Second),
+				EndTime:   start.Add(time.Second),
+			},
+		},
+		Metadata:   map[string]string{"pipeline": "write"},
+		TokensUsed: TokenUsage{InputTokens: 10, OutputTokens: 20, TotalTokens: 30},
+		CostUSD:    0.25,
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	if !strings.Contains(string(data), `"error":"step \"review\" failed"`) {
+		t.Errorf("error should be encoded as its message, got %s", data)
+	}
+
+	var decoded ExecutionResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+
+	if decoded.Error == nil || decoded.Error.Error() != result.Error.Error() {
+		t.Errorf("Error = %v, want %v", decoded.Error, result.Error)
+	}
+	if decoded.Duration != result.Duration || decoded.TokensUsed != result.TokensUsed ||
+		decoded.CostUSD != result.CostUSD || decoded.Success != result.Success {
+		t.Errorf("scalar fields did not round-trip: %+v", decoded)
+	}
+	if !reflect.DeepEqual(decoded.Output, result.Output) {
+		t.Errorf("Output = %#v, want %#v", decoded.Output, result.Output)
+	}
+	if !reflect.DeepEqual(decoded.Metadata, result.Metadata) {
+		t.Errorf("Metadata = %v, want %v", decoded.Metadata, result.Metadata)
+	}
+
+	draft := decoded.StepResults["draft"]
+	if draft == nil || draft.Output != "first draft" || draft.Error != nil ||
+		!draft.StartTime.Equal(start) || draft.Duration != time.Second {
+		t.Errorf("draft step did not round-trip: %+v", draft)
+	}
+	review := decoded.StepResults["review"]
+	if review == nil || review.Error == nil || review.Error.Error() != "provider unavailable" {
+		t.Errorf("review step did not round-trip: %+v", review)
+	}
+
+	// Encoding the decoded result again must give the same bytes
+	again, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatalf("second Marshal() error: %v", err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("re-encoded JSON differs:\n%s\n%s", again, data)
+	}
+}
+
+func TestExecutionResult_MarshalJSON_UnserializableOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output interface{}
+		want   string
+	}{
+		{name: "channel", output: make(chan int), want: `"0x`},
+		{name: "NaN", output: math.NaN(), want: `"output":"NaN"`},
+		{name: "panicking marshaler", output: panickingMarshaler{}, want: `"output":"runtime.panickingMarshaler"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(&ExecutionResult{Output: tt.output})
+			if err != nil {
+				t.Fatalf("Marshal() error: %v", err)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("got %s, want it to contain %s", data, tt.want)
+			}
+		})
+	}
+}
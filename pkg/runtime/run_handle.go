@@ -0,0 +1,217 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// RunStatus is a point-in-time snapshot of an execution started with Start.
+type RunStatus struct {
+	// Entity is the name of the entity being executed
+	Entity string `json:"entity"`
+
+	// RunningSteps are the steps currently executing, in the order they
+	// started; more than one when graph steps run concurrently
+	RunningSteps []string `json:"running_steps,omitempty"`
+
+	// StepsCompleted counts steps that finished successfully
+	StepsCompleted int `json:"steps_completed"`
+
+	// StepsFailed counts steps that finished with an error
+	StepsFailed int `json:"steps_failed"`
+
+	// LastStep is the most recently completed step
+	LastStep string `json:"last_step,omitempty"`
+
+	// LastError is the most recent step error, if any
+	LastError string `json:"last_error,omitempty"`
+
+	// TokensUsed is the token usage reported so far
+	TokensUsed TokenUsage `json:"tokens_used"`
+
+	// CostUSD is the estimated spend so far, priced by Config.CostModel
+	CostUSD float64 `json:"cost_usd"`
+
+	// Elapsed is the time since the run started (or its total duration once done)
+	Elapsed time.Duration `json:"elapsed"`
+
+	// Paused reports whether the run has been paused. A paused run finishes
+	// the steps it is running and waits before starting the next one, which
+	// is then reported in RunningSteps.
+	Paused bool `json:"paused,omitempty"`
+
+	// Done reports whether the run has finished
+	Done bool `json:"done"`
+}
+
+// RunHandle tracks an execution running in the background.
+// All methods are safe for concurrent use while the run proceeds.
+type RunHandle struct {
+	mu        sync.Mutex
+	status    RunStatus
+	startTime time.Time
+	endTime   time.Time
+	cancel    context.CancelFunc
+	done      chan struct{}
+	result    *ExecutionResult
+	err       error
+	cost      *costTracker // the run's spend, shared with its executions
+
+	// resume is closed to release steps waiting while the run is paused;
+	// it is nil while the run isn't paused
+	resume chan struct{}
+}
+
+// Start executes an entity in the background and returns a handle that can be
+// polled for status while the run proceeds. It accepts the same options as Execute.
+func (r *Runtime) Start(ctx context.Context, entity ast.Entity, opts ...ExecuteOption) *RunHandle {
+	runCtx, cancel := context.WithCancel(ctx)
+	h := &RunHandle{
+		status:    RunStatus{Entity: entity.Name()},
+		startTime: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		cost:      &costTracker{},
+	}
+
+	opts = append(opts, withRunHandle(h))
+	go func() {
+		defer cancel()
+		result, err := r.Execute(runCtx, entity, opts...)
+
+		h.mu.Lock()
+		h.result = result
+		h.err = err
+		h.endTime = time.Now()
+		h.status.Done = true
+		h.status.RunningSteps = nil
+		h.status.Paused = false
+		if h.resume != nil {
+			close(h.resume)
+			h.resume = nil
+		}
+		h.mu.Unlock()
+
+		close(h.done)
+	}()
+
+	return h
+}
+
+// Status returns a snapshot of the run's progress.
+func (h *RunHandle) Status() RunStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status := h.status
+	status.RunningSteps = append([]string(nil), h.status.RunningSteps...)
+	status.CostUSD = h.cost.total()
+	if h.status.Done {
+		status.Elapsed = h.endTime.Sub(h.startTime)
+	} else {
+		status.Elapsed = time.Since(h.startTime)
+	}
+	return status
+}
+
+// Done returns a channel that is closed when the run finishes.
+func (h *RunHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Wait blocks until the run finishes and returns its result.
+func (h *RunHandle) Wait() (*ExecutionResult, error) {
+	<-h.done
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.result, h.err
+}
+
+// Cancel stops the run by cancelling its context.
+func (h *RunHandle) Cancel() {
+	h.cancel()
+}
+
+// Pause stops the run from starting further steps until Resume is called.
+// Steps already running finish normally, and the run keeps its state while
+// it waits. Pausing a paused or finished run does nothing. Cancel still ends
+// a paused run.
+func (h *RunHandle) Pause() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.resume == nil && !h.status.Done {
+		h.resume = make(chan struct{})
+		h.status.Paused = true
+	}
+}
+
+// Resume lets a paused run continue from the step it stopped before.
+// Resuming a run that isn't paused does nothing.
+func (h *RunHandle) Resume() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.resume != nil {
+		close(h.resume)
+		h.resume = nil
+		h.status.Paused = false
+	}
+}
+
+// pausedUntil returns a channel that is closed when the run resumes, or nil
+// if the run isn't paused. It returns nil on a nil handle.
+func (h *RunHandle) pausedUntil() <-chan struct{} {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.resume == nil {
+		return nil
+	}
+	return h.resume
+}
+
+// stepStarted records that a step began executing. It is a no-op on a nil handle.
+func (h *RunHandle) stepStarted(name string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.RunningSteps = append(h.status.RunningSteps, name)
+}
+
+// stepFinished records the outcome of a step. It is a no-op on a nil handle.
+func (h *RunHandle) stepFinished(name string, err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, running := range h.status.RunningSteps {
+		if running == name {
+			h.status.RunningSteps = append(h.status.RunningSteps[:i], h.status.RunningSteps[i+1:]...)
+			break
+		}
+	}
+	h.status.LastStep = name
+	if err != nil {
+		h.status.StepsFailed++
+		h.status.LastError = err.Error()
+	} else {
+		h.status.StepsCompleted++
+	}
+}
+
+// addTokens records token usage from a provider call. It is a no-op on a nil handle.
+func (h *RunHandle) addTokens(usage TokenUsage) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.TokensUsed.Add(usage)
+}
@@ -0,0 +1,223 @@
+package runtime
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+func TestRuntime_Start(t *testing.T) {
+	source := `
+agent "step-agent" {
+	model: "mock-model"
+	instruction: "Process step"
+}
+
+pipeline "tracked" {
+	step_delay: "100ms"
+	step "first" {
+		use: agent("step-agent")
+		prompt: "Step 1"
+	}
+	step "second" {
+		use: agent("step-agent")
+		prompt: "Step 2"
+	}
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+
+	pipeline, found := ws.GetEntityByName("pipeline", "tracked")
+	if !found {
+		t.Fatal("pipeline not found")
+	}
+
+	t.Run("status while running", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.CostModel = CostModel{"mock": {InputPerMTok: 10_000}} // $1 per 100 input tokens
+		rt := New(ws, WithConfig(cfg), WithProvider("mock", NewSequenceProvider("one", "two")))
+		h := rt.Start(context.Background(), pipeline)
+
+		// The first step completes immediately; the second waits for step_delay.
+		deadline := time.Now().Add(time.Second)
+		var status RunStatus
+		for time.Now().Before(deadline) {
+			status = h.Status()
+			if status.StepsCompleted == 1 {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		if status.StepsCompleted != 1 || status.Done {
+			t.Fatalf("expected one completed step mid-run, got %+v", status)
+		}
+		if status.LastStep != "first" {
+			t.Errorf("LastStep = %q, want %q", status.LastStep, "first")
+		}
+		if status.TokensUsed.TotalTokens == 0 {
+			t.Error("expected token usage to be reported mid-run")
+		}
+		if math.Abs(status.CostUSD-1) > 1e-9 {
+			t.Errorf("CostUSD = %v mid-run, want 1", status.CostUSD)
+		}
+
+		result, err := h.Wait()
+		if err != nil {
+			t.Fatalf("Wait() error: %v", err)
+		}
+		if !result.Success {
+			t.Errorf("expected success, got error: %v", result.Error)
+		}
+
+		final := h.Status()
+		if !final.Done || final.StepsCompleted != 2 || len(final.RunningSteps) != 0 {
+			t.Errorf("unexpected final status: %+v", final)
+		}
+		if math.Abs(final.CostUSD-2) > 1e-9 {
+			t.Errorf("CostUSD = %v, want 2", final.CostUSD)
+		}
+		if final.Elapsed < 100*time.Millisecond {
+			t.Errorf("Elapsed = %v, want at least the step delay", final.Elapsed)
+		}
+	})
+
+	// startPaused starts the pipeline and pauses it after the first step,
+	// returning the handle and a channel of the pause states reported.
+	startPaused := func(t *testing.T) (*RunHandle, <-chan string) {
+		t.Helper()
+		states := make(chan string, 4)
+		handler := &CallbackStreamHandler{ProgressFunc: func(e ProgressEvent) {
+			if state := e.Metadata["state"]; state != "" {
+				states <- state
+			}
+		}}
+		rt := New(ws, WithProvider("mock", NewSequenceProvider("one", "two")))
+		h := rt.Start(context.Background(), pipeline, WithStreamHandler(handler))
+
+		// Pause while the second step waits out step_delay
+		deadline := time.Now().Add(time.Second)
+		for h.Status().StepsCompleted < 1 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		h.Pause()
+
+		select {
+		case state := <-states:
+			if state != "paused" {
+				t.Fatalf("first state = %q, want paused", state)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("run did not report being paused")
+		}
+		return h, states
+	}
+
+	t.Run("pause and resume", func(t *testing.T) {
+		h, states := startPaused(t)
+
+		time.Sleep(50 * time.Millisecond)
+		status := h.Status()
+		if !status.Paused || status.Done || status.StepsCompleted != 1 || strings.Join(status.RunningSteps, ",") != "second" {
+			t.Fatalf("unexpected status while paused: %+v", status)
+		}
+
+		h.Resume()
+		select {
+		case state := <-states:
+			if state != "resumed" {
+				t.Errorf("second state = %q, want resumed", state)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("run did not report resuming")
+		}
+
+		result, err := h.Wait()
+		if err != nil {
+			t.Fatalf("Wait() error: %v", err)
+		}
+		if got := result.StepResults["second"]; got == nil || got.Output != "two" {
+			t.Errorf("second step result = %+v, want output %q", got, "two")
+		}
+		if final := h.Status(); final.Paused || final.StepsCompleted != 2 {
+			t.Errorf("unexpected final status: %+v", final)
+		}
+	})
+
+	t.Run("cancel while paused", func(t *testing.T) {
+		h, _ := startPaused(t)
+		h.Cancel()
+
+		select {
+		case <-h.Done():
+		case <-time.After(time.Second):
+			t.Fatal("paused run did not stop after Cancel")
+		}
+		if _, err := h.Wait(); err == nil || !strings.Contains(err.Error(), "paused") {
+			t.Errorf("Wait() error = %v, want cancellation while paused", err)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		rt := New(ws, WithProvider("mock", NewSequenceProvider("one", "two")))
+		h := rt.Start(context.Background(), pipeline)
+		h.Cancel()
+
+		select {
+		case <-h.Done():
+		case <-time.After(time.Second):
+			t.Fatal("run did not stop after Cancel")
+		}
+
+		if _, err := h.Wait(); err == nil {
+			t.Error("expected an error from a cancelled run")
+		}
+	})
+}
+
+func TestRuntime_StartConcurrentSteps(t *testing.T) {
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, `
+agent "a" {
+	model: "mock-model"
+}
+
+pipeline "fan-out" {
+	step "left" {
+		use: agent("a")
+	}
+	step "right" {
+		use: agent("a")
+	}
+	step "join" {
+		use: agent("a")
+		depends_on: ["left", "right"]
+	}
+}
+`))
+	pipeline, _ := ws.GetEntityByName("pipeline", "fan-out")
+
+	rt := New(ws, WithProvider("mock", &blockingProvider{NewMockProvider()}))
+	h := rt.Start(context.Background(), pipeline)
+	defer h.Cancel()
+
+	deadline := time.Now().Add(time.Second)
+	var status RunStatus
+	for time.Now().Before(deadline) {
+		if status = h.Status(); len(status.RunningSteps) == 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	running := append([]string(nil), status.RunningSteps...)
+	sort.Strings(running)
+	if strings.Join(running, ",") != "left,right" {
+		t.Errorf("RunningSteps = %v, want both independent steps", status.RunningSteps)
+	}
+}
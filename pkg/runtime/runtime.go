@@ -5,7 +5,10 @@ package runtime
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,12 +19,21 @@ import (
 // Runtime is the main execution engine for LangSpace.
 // It coordinates LLM providers, variable resolution, and workflow execution.
 type Runtime struct {
-	workspace    *workspace.Workspace
-	providers    map[string]LLMProvider
-	mcpClients   map[string]MCPClient
-	defaultModel string
-	config       *Config
-	mu           sync.RWMutex
+	workspace     *workspace.Workspace
+	providers     map[string]LLMProvider
+	mcpClients    map[string]MCPClient
+	tools         map[string]Tool
+	defaultModel  string
+	config        *Config
+	cache         ResponseCache
+	metrics       *Metrics
+	escalate      EscalationHandler
+	tokenizer     Tokenizer
+	outputFormats map[string]OutputValidator
+	rateLimiters  map[string]*RateLimiter // by provider name
+	modelRoutes   []modelRoute
+	logger        *slog.Logger
+	mu            sync.RWMutex
 }
 
 // Config holds runtime configuration options.
@@ -35,12 +47,67 @@ type Config struct {
 	// Timeout is the default timeout for LLM requests
 	Timeout time.Duration `json:"timeout"`
 
-	// MaxRetries is the maximum number of retries for failed requests
+	// MaxRetries is the maximum number of retries for failed requests.
+	// Only retryable failures (see IsRetryable) are retried.
 	MaxRetries int `json:"max_retries"`
 
+	// BackoffBase is the delay before the first retry; it doubles on each
+	// further attempt (default 500ms)
+	BackoffBase time.Duration `json:"backoff_base,omitempty"`
+
+	// BackoffMax caps the delay between retries (default 30s)
+	BackoffMax time.Duration `json:"backoff_max,omitempty"`
+
 	// EnableStreaming enables streaming responses by default
 	EnableStreaming bool `json:"enable_streaming"`
 
+	// StepTimeout bounds each pipeline step. A step's timeout property
+	// overrides it. Zero means steps are only bounded by Timeout.
+	StepTimeout time.Duration `json:"step_timeout,omitempty"`
+
+	// StepDelay is a pause inserted between consecutive pipeline steps.
+	// A pipeline's step_delay property overrides it. Zero disables pacing.
+	StepDelay time.Duration `json:"step_delay,omitempty"`
+
+	// MaxParallelSteps caps how many steps of a pipeline using depends_on
+	// run at once. Zero means no limit.
+	MaxParallelSteps int `json:"max_parallel_steps,omitempty"`
+
+	// CacheMaxTemperature is the highest temperature at which requests are
+	// served from the response cache, if one is set. Samples above it are
+	// meant to diverge and always reach the provider.
+	CacheMaxTemperature float64 `json:"cache_max_temperature,omitempty"`
+
+	// CostModel prices token usage per model. Nil uses DefaultCostModel.
+	CostModel CostModel `json:"cost_model,omitempty"`
+
+	// MaxCostUSD is the most a single run may spend before further provider
+	// requests fail with ErrBudgetExceeded. Zero means no budget.
+	MaxCostUSD float64 `json:"max_cost_usd,omitempty"`
+
+	// MaxPromptTokens bounds the tokens in each pipeline step's prompt,
+	// counted with the runtime's Tokenizer. A step over it has its context
+	// and then its input cut down, keeping their ends. A step's
+	// max_prompt_tokens property overrides it. Zero means no budget.
+	MaxPromptTokens int `json:"max_prompt_tokens,omitempty"`
+
+	// ContextWindows gives the context window of each model. Requests whose
+	// prompt and max_tokens wouldn't fit fail with ErrContextOverflow
+	// instead of being sent. Nil uses DefaultContextWindows.
+	ContextWindows ContextWindows `json:"context_windows,omitempty"`
+
+	// ValidateOutputSchema checks the output of every pipeline step that
+	// declares an output_schema against it, failing steps whose output isn't
+	// JSON of that shape. A step's validate_output property overrides it.
+	ValidateOutputSchema bool `json:"validate_output_schema,omitempty"`
+
+	// RunSeed seeds the runtime's own randomness, such as retry jitter, so
+	// that runs replaying recorded responses behave the same. Each run gets
+	// its own source seeded with it, so every run repeats the same sequence.
+	// A workspace config's run_seed is used when it is zero; if neither is
+	// set each run picks a random seed, which RunManifest records.
+	RunSeed uint64 `json:"run_seed,omitempty"`
+
 	// Environment variables (can be overridden)
 	Environment map[string]string `json:"environment"`
 }
@@ -52,6 +119,8 @@ func DefaultConfig() *Config {
 		DefaultProvider: "anthropic",
 		Timeout:         5 * time.Minute,
 		MaxRetries:      3,
+		BackoffBase:     defaultBackoffBase,
+		BackoffMax:      defaultBackoffMax,
 		EnableStreaming: true,
 		Environment:     make(map[string]string),
 	}
@@ -63,6 +132,7 @@ func New(ws *workspace.Workspace, opts ...Option) *Runtime {
 		workspace:    ws,
 		providers:    make(map[string]LLMProvider),
 		mcpClients:   make(map[string]MCPClient),
+		tools:        make(map[string]Tool),
 		config:       DefaultConfig(),
 		defaultModel: "claude-sonnet-4-20250514",
 	}
@@ -96,6 +166,14 @@ func WithProvider(name string, provider LLMProvider) Option {
 	}
 }
 
+// WithResponseCache answers repeated completion requests at or below
+// Config.CacheMaxTemperature from cache instead of the provider.
+func WithResponseCache(cache ResponseCache) Option {
+	return func(r *Runtime) {
+		r.cache = cache
+	}
+}
+
 // RegisterProvider registers an LLM provider by name.
 func (r *Runtime) RegisterProvider(name string, provider LLMProvider) {
 	r.mu.Lock()
@@ -103,6 +181,39 @@ func (r *Runtime) RegisterProvider(name string, provider LLMProvider) {
 	r.providers[name] = provider
 }
 
+// modelRoute sends the models match accepts to provider.
+type modelRoute struct {
+	match    func(model string) bool
+	provider LLMProvider
+}
+
+// WithModelProvider routes the models match accepts to provider, as
+// RegisterModelProvider does.
+func WithModelProvider(match func(model string) bool, provider LLMProvider) Option {
+	return func(r *Runtime) {
+		r.modelRoutes = append(r.modelRoutes, modelRoute{match: match, provider: provider})
+	}
+}
+
+// RegisterModelProvider routes every model match accepts to provider, so
+// custom backends and test doubles can serve models of any name. Routes are
+// tried in the order they were registered, and the first match wins; they
+// take precedence over the built-in routing of claude-*, gpt-* and ollama/
+// models to the "anthropic", "openai" and "ollama" providers.
+func (r *Runtime) RegisterModelProvider(match func(model string) bool, provider LLMProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.modelRoutes = append(r.modelRoutes, modelRoute{match: match, provider: provider})
+}
+
+// RegisterProviderPrefix routes models whose names start with prefix to
+// provider, as RegisterModelProvider does.
+func (r *Runtime) RegisterProviderPrefix(prefix string, provider LLMProvider) {
+	r.RegisterModelProvider(func(model string) bool {
+		return strings.HasPrefix(model, prefix)
+	}, provider)
+}
+
 // GetProvider returns a provider by name.
 func (r *Runtime) GetProvider(name string) (LLMProvider, bool) {
 	r.mu.RLock()
@@ -178,7 +289,22 @@ func (r *Runtime) Execute(ctx context.Context, entity ast.Entity, opts ...Execut
 		Metadata:  execOpts.metadata,
 		Handler:   execOpts.handler,
 		StartTime: time.Now(),
+		run:       execOpts.run,
+		cost:      execOpts.cost,
+		rng:       execOpts.rng,
+		mu:        &sync.RWMutex{},
 	}
+	if execCtx.cost == nil {
+		execCtx.cost = &costTracker{}
+	}
+	if execCtx.rng == nil {
+		seed := execOpts.seed
+		if seed == 0 {
+			seed = r.chooseRunSeed()
+		}
+		execCtx.rng = newRunRand(seed)
+	}
+	spentBefore := execCtx.cost.total()
 
 	// Set input variable if provided
 	if execOpts.input != nil {
@@ -193,16 +319,23 @@ func (r *Runtime) Execute(ctx context.Context, entity ast.Entity, opts ...Execut
 	}
 
 	// Dispatch based on entity type
+	var result *ExecutionResult
+	var err error
 	switch entity.Type() {
 	case "intent":
-		return r.executeIntent(execCtx, entity)
+		result, err = r.executeIntent(execCtx, entity)
 	case "pipeline":
-		return r.executePipeline(execCtx, entity)
+		result, err = r.executePipeline(execCtx, entity)
 	case "script":
-		return r.executeScript(execCtx, entity)
+		result, err = r.executeScript(execCtx, entity)
 	default:
 		return nil, fmt.Errorf("cannot execute entity of type %q", entity.Type())
 	}
+
+	if result != nil {
+		result.CostUSD = execCtx.cost.total() - spentBefore
+	}
+	return result, err
 }
 
 // ExecuteByName looks up and executes an entity by type and name.
@@ -214,6 +347,54 @@ func (r *Runtime) ExecuteByName(ctx context.Context, entityType, entityName stri
 	return r.Execute(ctx, entity, opts...)
 }
 
+// ExecuteAll runs several intents (or pipelines) at once, at most
+// concurrency at a time, or all together if concurrency is zero or less.
+// Results are keyed by entity name. A failing entity doesn't stop the
+// others: its result is still returned, and its error is joined into the
+// returned error, prefixed with the entity's name. Options apply to every
+// run, and runs share the runtime's providers and rate limiters.
+func (r *Runtime) ExecuteAll(ctx context.Context, entities []ast.Entity, concurrency int, opts ...ExecuteOption) (map[string]*ExecutionResult, error) {
+	seen := make(map[string]bool, len(entities))
+	for _, entity := range entities {
+		if entity == nil {
+			return nil, fmt.Errorf("cannot execute nil entity")
+		}
+		if seen[entity.Name()] {
+			return nil, fmt.Errorf("%s %q is listed more than once", entity.Type(), entity.Name())
+		}
+		seen[entity.Name()] = true
+	}
+	if concurrency <= 0 || concurrency > len(entities) {
+		concurrency = len(entities)
+	}
+
+	results := make(map[string]*ExecutionResult, len(entities))
+	errs := make([]error, len(entities))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for i, entity := range entities {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result, err := r.Execute(ctx, entity, opts...)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s %q: %w", entity.Type(), entity.Name(), err)
+			}
+			mu.Lock()
+			results[entity.Name()] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return results, errors.Join(errs...)
+}
+
 // handleLifecycleEvent executes a lifecycle hook if defined on the entity.
 func (r *Runtime) handleLifecycleEvent(ctx *ExecutionContext, entity ast.Entity, eventName string, resolver *Resolver) {
 	hookProp, ok := entity.GetProperty(eventName)
@@ -264,7 +445,7 @@ func (r *Runtime) handleLifecycleEvent(ctx *ExecutionContext, entity ast.Entity,
 	// We use a background context or the current one? Usually hooks should be part of the same execution.
 	// But we don't want a hook failure to necessarily fail the whole thing if it's already finished.
 	// However, for now, we'll just execute it.
-	_, _ = r.Execute(ctx.Context, hookEntity, WithStreamHandler(ctx.Handler))
+	_, _ = r.Execute(ctx.Context, hookEntity, WithStreamHandler(ctx.Handler), withParent(ctx))
 
 	ctx.EmitProgress(ProgressEvent{
 		Type:    ProgressTypeStep,
@@ -279,6 +460,10 @@ type executeOptions struct {
 	handler  StreamHandler
 	timeout  time.Duration
 	metadata map[string]string
+	run      *RunHandle
+	cost     *costTracker
+	rng      *runRand
+	seed     uint64
 }
 
 // ExecuteOption is a functional option for Execute.
@@ -305,6 +490,14 @@ func WithTimeout(timeout time.Duration) ExecuteOption {
 	}
 }
 
+// WithRunSeed seeds the run's randomness with seed instead of the
+// configured run seed, such as to repeat a run from its RunManifest.
+func WithRunSeed(seed uint64) ExecuteOption {
+	return func(o *executeOptions) {
+		o.seed = seed
+	}
+}
+
 // WithMetadata sets execution metadata.
 func WithMetadata(key, value string) ExecuteOption {
 	return func(o *executeOptions) {
@@ -312,6 +505,25 @@ func WithMetadata(key, value string) ExecuteOption {
 	}
 }
 
+// withRunHandle attaches a RunHandle so nested executions report into it,
+// and their spend into its cost tracker.
+func withRunHandle(h *RunHandle) ExecuteOption {
+	return func(o *executeOptions) {
+		o.run = h
+		o.cost = h.cost
+	}
+}
+
+// withParent makes a nested execution part of parent's run, sharing its
+// RunHandle, cost budget and random source.
+func withParent(parent *ExecutionContext) ExecuteOption {
+	return func(o *executeOptions) {
+		o.run = parent.run
+		o.cost = parent.cost
+		o.rng = parent.rng
+	}
+}
+
 // ExecutionContext holds the context for a single execution.
 type ExecutionContext struct {
 	Context   context.Context
@@ -327,21 +539,61 @@ type ExecutionContext struct {
 
 	// For MCP tool resolution
 	MCPTools map[string]string // toolName -> mcpServerName
+
+	// run receives live status updates when started via Runtime.Start
+	run *RunHandle
+
+	// cost accumulates spend across the run for budget enforcement
+	cost *costTracker
+
+	// pipeline is the name of the pipeline being run, for metric labels
+	pipeline string
+
+	// step is the name of the pipeline step being run, for log attributes
+	step string
+
+	// rng is the run's seeded random source, used for retry jitter; nil
+	// means the global source
+	rng *runRand
+
+	// mu guards Variables, StepOutputs and MCPTools while steps run
+	// concurrently. It is a pointer so copies of the context share it; nil
+	// means no locking.
+	mu *sync.RWMutex
+}
+
+func (ec *ExecutionContext) lock() func() {
+	if ec.mu == nil {
+		return func() {}
+	}
+	ec.mu.Lock()
+	return ec.mu.Unlock
+}
+
+func (ec *ExecutionContext) rlock() func() {
+	if ec.mu == nil {
+		return func() {}
+	}
+	ec.mu.RLock()
+	return ec.mu.RUnlock
 }
 
 // SetVariable sets a variable in the execution context.
 func (ec *ExecutionContext) SetVariable(name string, value interface{}) {
+	defer ec.lock()()
 	ec.Variables[name] = value
 }
 
 // GetVariable gets a variable from the execution context.
 func (ec *ExecutionContext) GetVariable(name string) (interface{}, bool) {
+	defer ec.rlock()()
 	v, ok := ec.Variables[name]
 	return v, ok
 }
 
 // SetStepOutput sets the output of a step (for pipeline execution).
 func (ec *ExecutionContext) SetStepOutput(stepName string, output interface{}) {
+	defer ec.lock()()
 	if ec.StepOutputs == nil {
 		ec.StepOutputs = make(map[string]interface{})
 	}
@@ -350,6 +602,7 @@ func (ec *ExecutionContext) SetStepOutput(stepName string, output interface{}) {
 
 // GetStepOutput gets the output of a step.
 func (ec *ExecutionContext) GetStepOutput(stepName string) (interface{}, bool) {
+	defer ec.rlock()()
 	if ec.StepOutputs == nil {
 		return nil, false
 	}
@@ -393,17 +646,25 @@ type ExecutionResult struct {
 
 	// TokensUsed tracks token usage
 	TokensUsed TokenUsage `json:"tokens_used,omitempty"`
+
+	// CostUSD is the estimated spend of this execution, per Config.CostModel
+	CostUSD float64 `json:"cost_usd,omitempty"`
 }
 
 // StepResult represents the result of a single pipeline step.
 type StepResult struct {
-	Name      string        `json:"name"`
-	Success   bool          `json:"success"`
-	Output    interface{}   `json:"output,omitempty"`
-	Error     error         `json:"error,omitempty"`
-	Duration  time.Duration `json:"duration"`
-	StartTime time.Time     `json:"start_time"`
-	EndTime   time.Time     `json:"end_time"`
+	Name       string        `json:"name"`
+	Success    bool          `json:"success"`
+	Output     interface{}   `json:"output,omitempty"`
+	Error      error         `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	StartTime  time.Time     `json:"start_time"`
+	EndTime    time.Time     `json:"end_time"`
+	TokensUsed TokenUsage    `json:"tokens_used"`
+
+	// Escalated reports that the step failed and its output was supplied by
+	// the runtime's EscalationHandler
+	Escalated bool `json:"escalated,omitempty"`
 }
 
 // TokenUsage tracks LLM token usage.
@@ -411,6 +672,10 @@ type TokenUsage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
 	TotalTokens  int `json:"total_tokens"`
+
+	// Estimated is set when some of the counts were estimated with the
+	// runtime's Tokenizer because the provider didn't report usage
+	Estimated bool `json:"estimated,omitempty"`
 }
 
 // Add adds token usage from another TokenUsage.
@@ -418,4 +683,5 @@ func (t *TokenUsage) Add(other TokenUsage) {
 	t.InputTokens += other.InputTokens
 	t.OutputTokens += other.OutputTokens
 	t.TotalTokens += other.TotalTokens
+	t.Estimated = t.Estimated || other.Estimated
 }
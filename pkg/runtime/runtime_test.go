@@ -2,7 +2,11 @@ package runtime
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -112,6 +116,75 @@ func TestRegisterProvider(t *testing.T) {
 	}
 }
 
+func TestRegisterModelProvider(t *testing.T) {
+	anthropic := NewAnthropicProvider()
+	local := NewMockProvider()
+	fast := NewMockProvider()
+
+	rt := New(nil)
+	rt.RegisterProvider("anthropic", anthropic)
+	rt.RegisterProviderPrefix("local/", local)
+	rt.RegisterModelProvider(func(model string) bool {
+		return strings.HasSuffix(model, "-fast")
+	}, fast)
+	rt.RegisterProviderPrefix("claude-haiku", local)
+
+	tests := []struct {
+		model string
+		want  LLMProvider
+	}{
+		{"local/llama", local},
+		{"local/llama-fast", local}, // first match wins
+		{"claude-sonnet-fast", fast},
+		{"claude-haiku-4", local}, // routes beat the built-in prefixes
+		{"claude-sonnet-4-20250514", anthropic},
+		{"unknown-model", anthropic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			p, err := rt.getProviderForModel(tt.model)
+			if err != nil {
+				t.Fatalf("getProviderForModel() error: %v", err)
+			}
+			if p != tt.want {
+				t.Errorf("provider = %p, want %p", p, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecute_PipelineWithModelProvider(t *testing.T) {
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, `
+agent "a" {
+	model: "acme/large"
+}
+
+pipeline "p" {
+	step "one" {
+		use: agent("a")
+	}
+	step "two" {
+		use: agent("a")
+	}
+}
+`))
+	acme := NewMockProvider(WithMockResponses(MockResponse{Content: "ok"}))
+	other := NewMockProvider()
+	rt := New(ws, WithProvider("anthropic", other))
+	rt.RegisterProviderPrefix("acme/", acme)
+
+	if _, err := rt.ExecuteByName(context.Background(), "pipeline", "p"); err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	if n := len(acme.GetRequests()); n != 2 {
+		t.Errorf("registered provider got %d requests, want 2", n)
+	}
+	if n := len(other.GetRequests()); n != 0 {
+		t.Errorf("default provider got %d requests, want none", n)
+	}
+}
+
 func TestGetProviderNotFound(t *testing.T) {
 	ws := workspace.New()
 	rt := New(ws)
@@ -299,6 +372,47 @@ intent "echo-intent" {
 	}
 }
 
+func TestFormatContent_Deterministic(t *testing.T) {
+	state := map[string]interface{}{
+		"pegs":  map[string]interface{}{"A": []interface{}{3.0, 2.0}, "B": []interface{}{}, "C": []interface{}{1.0}},
+		"moves": 1.0,
+		"disks": 3.0,
+		"goal":  "move all disks to C",
+	}
+	want := formatContent(state)
+	for i := 0; i < 50; i++ {
+		if got := formatContent(state); got != want {
+			t.Fatalf("formatContent() changed between calls:\n%s\nthen\n%s", want, got)
+		}
+	}
+	if !strings.HasPrefix(want, "**disks**: 3\n**goal**: move all disks to C\n**moves**: 1\n**pegs**: **A**: 3\n\n2") {
+		t.Errorf("formatContent() = %q, want keys in sorted order", want)
+	}
+}
+
+func TestExecute_IntentWithStructuredInput(t *testing.T) {
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, `
+agent "a" {
+	model: "mock-model"
+}
+
+intent "i" {
+	use: agent("a")
+}
+`))
+	mockProvider := NewMockProvider(WithMockResponses(MockResponse{Content: "ok"}))
+	rt := New(ws, WithProvider("mock", mockProvider))
+
+	input := map[string]interface{}{"b": "two", "a": "one"}
+	if _, err := rt.ExecuteByName(context.Background(), "intent", "i", WithInput(input)); err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	if prompt := mockProvider.LastRequest().Messages[0].Content; prompt != "## Input\n\n**a**: one\n**b**: two" {
+		t.Errorf("prompt = %q, want the input's fields in sorted order", prompt)
+	}
+}
+
 func TestExecute_IntentWithStreaming(t *testing.T) {
 	source := `
 agent "stream-agent" {
@@ -391,6 +505,406 @@ pipeline "test-pipeline" {
 	}
 }
 
+func TestExecute_PipelineTokenUsage(t *testing.T) {
+	source := `
+agent "step-agent" {
+	model: "mock-model"
+}
+
+pipeline "counted" {
+	step "first" {
+		use: agent("step-agent")
+		prompt: "Step 1"
+	}
+	step "second" {
+		use: agent("step-agent")
+		prompt: step("first").output
+	}
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+
+	mockProvider := NewMockProvider(WithMockResponses(
+		MockResponse{Content: "one", Usage: TokenUsage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}},
+		MockResponse{Content: "two", Usage: TokenUsage{InputTokens: 20, OutputTokens: 7, TotalTokens: 27}},
+	))
+	rt := New(ws, WithProvider("mock", mockProvider))
+
+	pipeline, _ := ws.GetEntityByName("pipeline", "counted")
+	result, err := rt.Execute(context.Background(), pipeline)
+	if err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+
+	want := TokenUsage{InputTokens: 30, OutputTokens: 12, TotalTokens: 42}
+	if result.TokensUsed != want {
+		t.Errorf("TokensUsed = %+v, want %+v", result.TokensUsed, want)
+	}
+	if got := result.StepResults["second"].TokensUsed.TotalTokens; got != 27 {
+		t.Errorf("second step TotalTokens = %d, want 27", got)
+	}
+}
+
+// concurrencyProvider echoes the prompt after a short pause and records how
+// many requests were in flight at once.
+type concurrencyProvider struct {
+	*MockProvider
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	fail     string // prompt that fails
+}
+
+func (p *concurrencyProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	p.mu.Lock()
+	p.inFlight++
+	if p.inFlight > p.peak {
+		p.peak = p.inFlight
+	}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.inFlight--
+		p.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(50 * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	prompt := req.Messages[0].Content
+	if prompt == p.fail {
+		return nil, fmt.Errorf("cannot %s", prompt)
+	}
+	return &CompletionResponse{Content: "did " + prompt, FinishReason: FinishReasonStop}, nil
+}
+
+func TestExecute_PipelineStepGraph(t *testing.T) {
+	source := `
+agent "worker" {
+	model: "mock-model"
+}
+
+pipeline "build" {
+	step "lint" {
+		use: agent("worker")
+		prompt: "lint"
+	}
+	step "test" {
+		use: agent("worker")
+		prompt: "test"
+	}
+	step "report" {
+		use: agent("worker")
+		depends_on: ["lint", "test"]
+		prompt: "report {{step.lint}} and {{step.test}}"
+	}
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+	pipeline, _ := ws.GetEntityByName("pipeline", "build")
+
+	t.Run("independent steps run concurrently", func(t *testing.T) {
+		provider := &concurrencyProvider{MockProvider: NewMockProvider()}
+		rt := New(ws, WithProvider("mock", provider))
+
+		result, err := rt.Execute(context.Background(), pipeline)
+		if err != nil {
+			t.Fatalf("execute error: %v", err)
+		}
+		if provider.peak != 2 {
+			t.Errorf("peak concurrency = %d, want 2", provider.peak)
+		}
+		if want := "did report did lint and did test"; result.Output != want {
+			t.Errorf("Output = %q, want %q", result.Output, want)
+		}
+	})
+
+	t.Run("MaxParallelSteps limits concurrency", func(t *testing.T) {
+		provider := &concurrencyProvider{MockProvider: NewMockProvider()}
+		cfg := DefaultConfig()
+		cfg.MaxParallelSteps = 1
+		rt := New(ws, WithProvider("mock", provider), WithConfig(cfg))
+
+		if _, err := rt.Execute(context.Background(), pipeline); err != nil {
+			t.Fatalf("execute error: %v", err)
+		}
+		if provider.peak != 1 {
+			t.Errorf("peak concurrency = %d, want 1", provider.peak)
+		}
+	})
+
+	t.Run("failure skips dependents", func(t *testing.T) {
+		provider := &concurrencyProvider{MockProvider: NewMockProvider(), fail: "lint"}
+		rt := New(ws, WithProvider("mock", provider))
+
+		result, err := rt.Execute(context.Background(), pipeline)
+		if err == nil || !strings.Contains(err.Error(), `step "lint" failed`) {
+			t.Fatalf("expected lint failure, got %v", err)
+		}
+		if _, ran := result.StepResults["report"]; ran {
+			t.Error("report should not run after lint failed")
+		}
+	})
+}
+
+func TestExecute_IntentUsesPipeline(t *testing.T) {
+	source := `
+agent "writer" {
+	model: "mock-model"
+}
+
+pipeline "summarize" {
+	step "summary" {
+		use: agent("writer")
+		input: $input
+	}
+}
+
+intent "summarize-notes" {
+	use: pipeline("summarize")
+	input: "meeting notes"
+}
+
+intent "broken" {
+	use: pipeline("missing")
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+
+	mockProvider := NewMockProvider(WithMockResponses(MockResponse{Content: "short summary"}))
+	rt := New(ws, WithProvider("mock", mockProvider))
+
+	result, err := rt.ExecuteByName(context.Background(), "intent", "summarize-notes")
+	if err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	if !result.Success || result.Output != "short summary" {
+		t.Errorf("result = %+v, want the pipeline's output", result)
+	}
+	if result.Metadata["pipeline"] != "summarize" {
+		t.Errorf("Metadata = %v, want pipeline=summarize", result.Metadata)
+	}
+	if req := mockProvider.LastRequest(); req == nil || !strings.Contains(req.Messages[0].Content, "meeting notes") {
+		t.Errorf("intent input was not passed to the pipeline: %+v", req)
+	}
+
+	_, err = rt.ExecuteByName(context.Background(), "intent", "broken")
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected error naming the missing pipeline, got %v", err)
+	}
+}
+
+func TestExecute_IntentInputPrecedence(t *testing.T) {
+	source := `
+agent "solver" {
+	model: "mock-model"
+}
+
+pipeline "hanoi" {
+	input: {
+		num_disks: 3
+		from: "A"
+	}
+	step "solve" {
+		use: agent("solver")
+		prompt: "Move {{$input.num_disks}} disks from {{$input.from}}"
+	}
+}
+
+intent "test-hanoi" {
+	use: pipeline("hanoi")
+	input: {
+		num_disks: 5
+	}
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+
+	tests := []struct {
+		name       string
+		entityType string
+		entityName string
+		opts       []ExecuteOption
+		wantPrompt string
+	}{
+		{
+			name:       "pipeline defaults",
+			entityType: "pipeline",
+			entityName: "hanoi",
+			wantPrompt: "Move 3 disks from A",
+		},
+		{
+			name:       "intent overrides pipeline",
+			entityType: "intent",
+			entityName: "test-hanoi",
+			wantPrompt: "Move 5 disks from A",
+		},
+		{
+			name:       "caller overrides intent",
+			entityType: "intent",
+			entityName: "test-hanoi",
+			opts:       []ExecuteOption{WithInput(map[string]interface{}{"from": "B", "num_disks": 7})},
+			wantPrompt: "Move 7 disks from B",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProvider := NewMockProvider(WithMockResponses(MockResponse{Content: "done"}))
+			rt := New(ws, WithProvider("mock", mockProvider))
+
+			if _, err := rt.ExecuteByName(context.Background(), tt.entityType, tt.entityName, tt.opts...); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+			if got := mockProvider.LastRequest().Messages[0].Content; got != tt.wantPrompt {
+				t.Errorf("prompt = %q, want %q", got, tt.wantPrompt)
+			}
+		})
+	}
+}
+
+func TestExecute_AgentDefaults(t *testing.T) {
+	source := `
+config {
+	default_model: "mock-shared"
+	default_temperature: 0.1
+	default_instruction: "You are a careful assistant."
+}
+
+agent "inherits" {
+	instruction: "Summarize."
+}
+
+agent "overrides" {
+	model: "mock-special"
+	temperature: 0.9
+}
+
+intent "a" {
+	use: agent("inherits")
+	input: "x"
+}
+
+intent "b" {
+	use: agent("overrides")
+	input: "x"
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+
+	mockProvider := NewMockProvider(WithMockResponses(MockResponse{Content: "ok"}))
+	rt := New(ws, WithProvider("mock", mockProvider))
+
+	tests := []struct {
+		intent      string
+		model       string
+		temperature float64
+		system      string
+	}{
+		{"a", "mock-shared", 0.1, "Summarize."},
+		{"b", "mock-special", 0.9, "You are a careful assistant."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.intent, func(t *testing.T) {
+			if _, err := rt.ExecuteByName(context.Background(), "intent", tt.intent); err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+			req := mockProvider.LastRequest()
+			if req.Model != tt.model || req.Temperature != tt.temperature || req.SystemPrompt != tt.system {
+				t.Errorf("request model=%q temperature=%v system=%q, want %q %v %q",
+					req.Model, req.Temperature, req.SystemPrompt, tt.model, tt.temperature, tt.system)
+			}
+		})
+	}
+}
+
+func TestExecute_StepOverridesAgentSettings(t *testing.T) {
+	source := `
+agent "writer" {
+	model: "mock-a"
+	temperature: 0.3
+	max_tokens: 100
+	stop: ["END", "---"]
+	seed: 7
+	top_p: 0.9
+	top_k: 40
+}
+
+pipeline "p" {
+	step "warm" {
+		use: agent("writer")
+		temperature: 0.9
+		stop: "DONE"
+	}
+	step "switch" {
+		use: agent("writer")
+		model: "mock-b"
+		seed: 8
+		top_p: 0.5
+	}
+	step "plain" {
+		use: agent("writer")
+	}
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+
+	mockProvider := NewMockProvider(WithMockResponses(MockResponse{Content: "ok"}))
+	rt := New(ws, WithProvider("mock", mockProvider))
+
+	if _, err := rt.ExecuteByName(context.Background(), "pipeline", "p"); err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+
+	want := []struct {
+		model       string
+		temperature float64
+		maxTokens   int
+		stop        []string
+		seed        int
+		params      map[string]interface{}
+	}{
+		{"mock-a", 0.9, 100, []string{"DONE"}, 7, map[string]interface{}{"top_p": 0.9, "top_k": 40.0}},
+		{"mock-b", 0.3, 100, []string{"END", "---"}, 8, map[string]interface{}{"top_p": 0.5, "top_k": 40.0}},
+		{"mock-a", 0.3, 100, []string{"END", "---"}, 7, map[string]interface{}{"top_p": 0.9, "top_k": 40.0}},
+	}
+	reqs := mockProvider.GetRequests()
+	if len(reqs) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(reqs), len(want))
+	}
+	for i, w := range want {
+		if reqs[i].Model != w.model || reqs[i].Temperature != w.temperature || reqs[i].MaxTokens != w.maxTokens {
+			t.Errorf("request %d: model=%q temperature=%v max_tokens=%d, want %q %v %d",
+				i, reqs[i].Model, reqs[i].Temperature, reqs[i].MaxTokens, w.model, w.temperature, w.maxTokens)
+		}
+		if !reflect.DeepEqual(reqs[i].StopSequences, w.stop) {
+			t.Errorf("request %d: stop = %q, want %q", i, reqs[i].StopSequences, w.stop)
+		}
+		if reqs[i].Seed == nil || *reqs[i].Seed != w.seed {
+			t.Errorf("request %d: seed = %v, want %d", i, reqs[i].Seed, w.seed)
+		}
+		if !reflect.DeepEqual(reqs[i].ProviderParams, w.params) {
+			t.Errorf("request %d: provider params = %v, want %v", i, reqs[i].ProviderParams, w.params)
+		}
+	}
+}
+
 func TestExecute_WithTimeout(t *testing.T) {
 	source := `
 agent "slow-agent" {
@@ -470,3 +984,247 @@ intent "meta-intent" {
 		t.Errorf("expected request_id metadata, got: %v", result.Metadata)
 	}
 }
+
+func TestExecute_PipelineStepDelay(t *testing.T) {
+	source := `
+agent "step-agent" {
+	model: "mock-model"
+	instruction: "Process step"
+}
+
+pipeline "paced" {
+	step_delay: "40ms"
+	step "first" {
+		use: agent("step-agent")
+		prompt: "Step 1"
+	}
+	step "second" {
+		use: agent("step-agent")
+		prompt: "Step 2"
+	}
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+
+	pipeline, found := ws.GetEntityByName("pipeline", "paced")
+	if !found {
+		t.Fatal("pipeline not found")
+	}
+
+	t.Run("delays between steps", func(t *testing.T) {
+		rt := New(ws, WithProvider("mock", NewSequenceProvider("one", "two")))
+
+		start := time.Now()
+		result, err := rt.Execute(context.Background(), pipeline)
+		if err != nil {
+			t.Fatalf("execute error: %v", err)
+		}
+		if !result.Success {
+			t.Fatalf("expected success, got error: %v", result.Error)
+		}
+		if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+			t.Errorf("expected at least 40ms between steps, pipeline took %v", elapsed)
+		}
+	})
+
+	t.Run("respects cancellation", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.StepDelay = time.Hour
+		rt := New(ws, WithConfig(cfg), WithProvider("mock", NewSequenceProvider("one", "two")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		pipelineWithoutDelay := ast.NewPipelineEntity("unpaced")
+		pipelineWithoutDelay.Steps = pipeline.(*ast.PipelineEntity).Steps
+
+		_, err := rt.Execute(ctx, pipelineWithoutDelay)
+		if err == nil {
+			t.Fatal("expected cancellation error")
+		}
+		if !strings.Contains(err.Error(), "cancelled before step \"second\"") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+// blockingProvider blocks every request until its context is done.
+type blockingProvider struct {
+	*MockProvider
+}
+
+func (p *blockingProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *blockingProvider) CompleteStream(ctx context.Context, req *CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	return p.Complete(ctx, req)
+}
+
+func TestExecute_PipelineStepTimeout(t *testing.T) {
+	source := `
+agent "slow-agent" {
+	model: "mock-model"
+	instruction: "Never answers"
+}
+
+pipeline "stuck" {
+	step "wait" {
+		use: agent("slow-agent")
+		prompt: "Hello"
+		timeout: "20ms"
+	}
+}
+`
+	entities := parseSource(t, source)
+	ws := workspace.New()
+	addEntities(t, ws, entities)
+	pipeline, _ := ws.GetEntityByName("pipeline", "stuck")
+
+	rt := New(ws, WithProvider("mock", &blockingProvider{NewMockProvider()}))
+
+	t.Run("step timeout", func(t *testing.T) {
+		result, err := rt.Execute(context.Background(), pipeline)
+		if !errors.Is(err, ErrStepTimeout) {
+			t.Fatalf("expected ErrStepTimeout, got %v", err)
+		}
+		if !errors.Is(result.StepResults["wait"].Error, ErrStepTimeout) {
+			t.Errorf("step result error = %v, want ErrStepTimeout", result.StepResults["wait"].Error)
+		}
+	})
+
+	t.Run("outer cancellation is not a step timeout", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := rt.Execute(ctx, pipeline)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if errors.Is(err, ErrStepTimeout) {
+			t.Errorf("cancellation reported as step timeout: %v", err)
+		}
+	})
+}
+
+// overlapProvider answers every request after waiting briefly for another
+// request to be in flight, and records the most requests it saw at once.
+type overlapProvider struct {
+	*MockProvider
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (p *overlapProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	p.mu.Lock()
+	p.inFlight++
+	if p.inFlight > p.peak {
+		p.peak = p.inFlight
+	}
+	p.mu.Unlock()
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		p.mu.Lock()
+		overlapped := p.peak > 1
+		p.mu.Unlock()
+		if overlapped {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+	return p.MockProvider.Complete(ctx, req)
+}
+
+func TestExecuteAll(t *testing.T) {
+	const source = `
+agent "a" {
+	model: "mock-model"
+}
+
+pipeline "first" {
+	step "one" {
+		use: agent("a")
+	}
+}
+
+pipeline "second" {
+	step "one" {
+		use: agent("a")
+	}
+}
+
+intent "run-first" {
+	use: pipeline("first")
+}
+
+intent "run-second" {
+	use: pipeline("second")
+}
+
+intent "broken" {
+	use: agent("ghost")
+}
+`
+	tests := []struct {
+		name        string
+		intents     []string
+		concurrency int
+		wantPeak    int
+		wantFailed  []string
+	}{
+		{name: "concurrent", intents: []string{"run-first", "run-second"}, concurrency: 2, wantPeak: 2},
+		{name: "unbounded", intents: []string{"run-first", "run-second"}, wantPeak: 2},
+		{name: "one at a time", intents: []string{"run-first", "run-second"}, concurrency: 1, wantPeak: 1},
+		{name: "failure doesn't stop others", intents: []string{"run-first", "broken"}, concurrency: 2, wantPeak: 1, wantFailed: []string{"broken"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, source))
+			provider := &overlapProvider{MockProvider: NewMockProvider(WithMockResponses(MockResponse{Content: "done"}))}
+			rt := New(ws, WithProvider("mock", provider))
+
+			var intents []ast.Entity
+			for _, name := range tt.intents {
+				intent, _ := ws.GetEntityByName("intent", name)
+				intents = append(intents, intent)
+			}
+
+			results, err := rt.ExecuteAll(context.Background(), intents, tt.concurrency)
+			if len(results) != len(tt.intents) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.intents))
+			}
+			for _, name := range tt.intents {
+				failed := false
+				for _, f := range tt.wantFailed {
+					failed = failed || f == name
+				}
+				result := results[name]
+				if failed {
+					if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("intent %q", name)) {
+						t.Errorf("error = %v, want it to name intent %q", err, name)
+					}
+					continue
+				}
+				if result == nil || !result.Success || result.Output != "done" {
+					t.Errorf("result for %q = %+v, want it to complete", name, result)
+				}
+			}
+			if len(tt.wantFailed) == 0 && err != nil {
+				t.Errorf("ExecuteAll() error: %v", err)
+			}
+			if provider.peak != tt.wantPeak {
+				t.Errorf("peak concurrent requests = %d, want %d", provider.peak, tt.wantPeak)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// renderOutputSchema renders a step's output_schema as prompt instructions
+// describing the JSON object the model should respond with. Each field is
+// listed with its type and description; nested objects are indented.
+func renderOutputSchema(schema ast.Value) string {
+	obj, ok := schema.(ast.ObjectValue)
+	if !ok {
+		return fmt.Sprintf("Respond in this format: %s", describeSchemaType(schema))
+	}
+
+	var b strings.Builder
+	b.WriteString("Respond with a JSON object with these fields:\n")
+	writeSchemaFields(&b, obj, 0)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// writeSchemaFields writes one bullet per field of obj, in key order.
+func writeSchemaFields(b *strings.Builder, obj ast.ObjectValue, depth int) {
+	keys := make([]string, 0, len(obj.Properties))
+	for k := range obj.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	indent := strings.Repeat("  ", depth)
+	for _, key := range keys {
+		value := obj.Properties[key]
+		if nested, ok := value.(ast.ObjectValue); ok && !isFieldDescriptor(nested) {
+			fmt.Fprintf(b, "%s- `%s`: object\n", indent, key)
+			writeSchemaFields(b, nested, depth+1)
+			continue
+		}
+		if arr, ok := value.(ast.ArrayValue); ok && len(arr.Elements) == 1 {
+			if elem, ok := arr.Elements[0].(ast.ObjectValue); ok && !isFieldDescriptor(elem) {
+				fmt.Fprintf(b, "%s- `%s`: array of objects\n", indent, key)
+				writeSchemaFields(b, elem, depth+1)
+				continue
+			}
+		}
+		fmt.Fprintf(b, "%s- `%s`: %s\n", indent, key, describeSchemaType(value))
+	}
+}
+
+// isFieldDescriptor reports whether obj describes a single field, as in
+// { type: "string", description: "..." }, rather than a nested object.
+func isFieldDescriptor(obj ast.ObjectValue) bool {
+	t, hasType := obj.Properties["type"].(ast.StringValue)
+	_, hasDesc := obj.Properties["description"].(ast.StringValue)
+	return hasType && hasDesc && t.Value != ""
+}
+
+// describeSchemaType renders the type of a single schema field.
+func describeSchemaType(value ast.Value) string {
+	switch v := value.(type) {
+	case ast.StringValue:
+		return v.Value
+
+	case ast.TypedParameterValue:
+		desc := v.ParamType
+		if v.ParamType == "enum" && len(v.EnumValues) > 0 {
+			quoted := make([]string, len(v.EnumValues))
+			for i, ev := range v.EnumValues {
+				quoted[i] = fmt.Sprintf("%q", ev)
+			}
+			desc = "one of " + strings.Join(quoted, ", ")
+		}
+		if v.Required {
+			desc += " (required)"
+		}
+		if v.Description != "" {
+			desc += " — " + v.Description
+		}
+		return desc
+
+	case ast.ArrayValue:
+		if len(v.Elements) == 1 {
+			return "array of " + describeSchemaType(v.Elements[0])
+		}
+		return "array"
+
+	case ast.ObjectValue:
+		if isFieldDescriptor(v) {
+			t := v.Properties["type"].(ast.StringValue).Value
+			d := v.Properties["description"].(ast.StringValue).Value
+			return t + " — " + d
+		}
+		return "object"
+
+	case ast.NumberValue:
+		return fmt.Sprintf("%g", v.Value)
+
+	case ast.BoolValue:
+		return fmt.Sprintf("%t", v.Value)
+
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// schemaValidator returns an OutputValidator that parses output as JSON,
+// optionally in a code fence, and checks it against an output_schema. Every
+// field of an object schema must be present unless it is declared optional,
+// and must have the declared type; fields the schema doesn't mention are
+// allowed. Types the schema names but the validator doesn't know, such as
+// "any", accept any value.
+func schemaValidator(schema ast.Value) OutputValidator {
+	return func(output string) error {
+		var value interface{}
+		if err := json.Unmarshal([]byte(unfenceJSON(output)), &value); err != nil {
+			return fmt.Errorf("is not valid JSON: %w", err)
+		}
+		if err := checkSchema(schema, value, ""); err != nil {
+			return fmt.Errorf("does not match output_schema: %w", err)
+		}
+		return nil
+	}
+}
+
+// checkSchema checks a decoded JSON value against a schema value. path names
+// the value in errors, e.g. "location.line".
+func checkSchema(schema ast.Value, value interface{}, path string) error {
+	switch s := schema.(type) {
+	case ast.StringValue:
+		return checkSchemaType(s.Value, value, path)
+
+	case ast.TypedParameterValue:
+		if s.ParamType == "enum" && len(s.EnumValues) > 0 {
+			str, ok := value.(string)
+			if ok {
+				for _, allowed := range s.EnumValues {
+					if str == allowed {
+						return nil
+					}
+				}
+			}
+			return fmt.Errorf("%s: want one of %q, got %s", describePath(path), s.EnumValues, describeJSON(value))
+		}
+		return checkSchemaType(s.ParamType, value, path)
+
+	case ast.ArrayValue:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want array, got %s", describePath(path), describeJSON(value))
+		}
+		if len(s.Elements) != 1 {
+			return nil
+		}
+		for i, item := range items {
+			if err := checkSchema(s.Elements[0], item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case ast.ObjectValue:
+		if isFieldDescriptor(s) {
+			return checkSchemaType(s.Properties["type"].(ast.StringValue).Value, value, path)
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want object, got %s", describePath(path), describeJSON(value))
+		}
+		keys := make([]string, 0, len(s.Properties))
+		for k := range s.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field := s.Properties[key]
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			v, present := obj[key]
+			if !present {
+				if optional, ok := field.(ast.TypedParameterValue); ok && !optional.Required && optional.ParamType != "enum" {
+					continue
+				}
+				return fmt.Errorf("missing field %q", fieldPath)
+			}
+			if err := checkSchema(field, v, fieldPath); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// checkSchemaType checks a decoded JSON value against a named type.
+func checkSchemaType(typ string, value interface{}, path string) error {
+	ok := true
+	switch typ {
+	case "string":
+		_, ok = value.(string)
+	case "number":
+		_, ok = value.(float64)
+	case "integer", "int":
+		f, isNum := value.(float64)
+		ok = isNum && f == math.Trunc(f)
+	case "bool", "boolean":
+		_, ok = value.(bool)
+	case "array":
+		_, ok = value.([]interface{})
+	case "object":
+		_, ok = value.(map[string]interface{})
+	}
+	if !ok {
+		return fmt.Errorf("%s: want %s, got %s", describePath(path), typ, describeJSON(value))
+	}
+	return nil
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "output"
+	}
+	return fmt.Sprintf("field %q", path)
+}
+
+// describeJSON names the JSON type of a decoded value.
+func describeJSON(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,253 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+func TestRenderOutputSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name: "flat fields",
+			source: `output_schema: {
+				score: number
+				feedback: string
+			}`,
+			want: "Respond with a JSON object with these fields:\n" +
+				"- `feedback`: string\n" +
+				"- `score`: number",
+		},
+		{
+			name: "enum",
+			source: `output_schema: {
+				type: enum ["bug", "feature"]
+			}`,
+			want: "Respond with a JSON object with these fields:\n" +
+				"- `type`: one of \"bug\", \"feature\"",
+		},
+		{
+			name: "descriptions and nesting",
+			source: `output_schema: {
+				summary: { type: "string", description: "One paragraph" }
+				location: {
+					file: string
+					line: number
+				}
+				issues: [{ severity: string, message: string }]
+				tags: [string]
+			}`,
+			want: "Respond with a JSON object with these fields:\n" +
+				"- `issues`: array of objects\n" +
+				"  - `message`: string\n" +
+				"  - `severity`: string\n" +
+				"- `location`: object\n" +
+				"  - `file`: string\n" +
+				"  - `line`: number\n" +
+				"- `summary`: string — One paragraph\n" +
+				"- `tags`: array of string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entities := parseSource(t, `agent "a" { `+tt.source+` }`)
+			schema, ok := entities[0].GetProperty("output_schema")
+			if !ok {
+				t.Fatal("output_schema not parsed")
+			}
+			if got := renderOutputSchema(schema); got != tt.want {
+				t.Errorf("renderOutputSchema() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecute_StepOutputSchemaInPrompt(t *testing.T) {
+	source := `
+agent "critic" {
+	model: "mock-model"
+	instruction: "Critique"
+}
+
+pipeline "review" {
+	step "evaluate" {
+		use: agent("critic")
+		input: $input
+		output_schema: {
+			score: number
+			feedback: string
+		}
+	}
+}
+`
+	ws := workspace.New()
+	addEntities(t, ws, parseSource(t, source))
+	pipeline, _ := ws.GetEntityByName("pipeline", "review")
+
+	mock := NewMockProvider(WithMockResponses(MockResponse{Content: `{"score": 7}`, FinishReason: FinishReasonStop}))
+	rt := New(ws, WithProvider("mock", mock))
+
+	if _, err := rt.Execute(context.Background(), pipeline, WithInput("draft")); err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+
+	prompt := mock.LastRequest().Messages[0].Content
+	if !strings.Contains(prompt, "## Output Format") || !strings.Contains(prompt, "- `score`: number") {
+		t.Errorf("prompt does not describe the output schema:\n%s", prompt)
+	}
+}
+
+func TestSchemaValidator(t *testing.T) {
+	const schema = `output_schema: {
+		move: { type: "string", description: "Disk move, e.g. 1:A->C" }
+		next_state: {
+			pegs: { A: [number], B: [number], C: [number] }
+			done: bool
+		}
+		kind: enum ["move", "finish"]
+		comment: string optional
+	}`
+	tests := []struct {
+		name    string
+		output  string
+		wantErr string
+	}{
+		{
+			name:   "conforming",
+			output: `{"move": "1:A->C", "next_state": {"pegs": {"A": [2, 3], "B": [], "C": [1]}, "done": false}, "kind": "move"}`,
+		},
+		{
+			name:   "fenced with extra keys",
+			output: "```json\n" + `{"move": "1:A->C", "next_state": {"pegs": {"A": [], "B": [], "C": [1]}, "done": true}, "kind": "finish", "why": "smallest disk"}` + "\n```",
+		},
+		{
+			name:    "missing key",
+			output:  `{"move": "1:A->C", "kind": "move"}`,
+			wantErr: `missing field "next_state"`,
+		},
+		{
+			name:    "missing nested key",
+			output:  `{"move": "1:A->C", "next_state": {"pegs": {"A": [], "B": [], "C": [1]}}, "kind": "move"}`,
+			wantErr: `missing field "next_state.done"`,
+		},
+		{
+			name:    "wrong type",
+			output:  `{"move": 1, "next_state": {"pegs": {"A": [], "B": [], "C": [1]}, "done": false}, "kind": "move"}`,
+			wantErr: `field "move": want string, got number`,
+		},
+		{
+			name:    "wrong array element",
+			output:  `{"move": "1:A->C", "next_state": {"pegs": {"A": ["2"], "B": [], "C": [1]}, "done": false}, "kind": "move"}`,
+			wantErr: `field "next_state.pegs.A[0]": want number, got string`,
+		},
+		{
+			name:    "value outside enum",
+			output:  `{"move": "1:A->C", "next_state": {"pegs": {"A": [], "B": [], "C": [1]}, "done": false}, "kind": "undo"}`,
+			wantErr: `field "kind": want one of`,
+		},
+		{
+			name:    "optional key of wrong type",
+			output:  `{"move": "1:A->C", "next_state": {"pegs": {"A": [], "B": [], "C": [1]}, "done": false}, "kind": "move", "comment": 3}`,
+			wantErr: `field "comment": want string`,
+		},
+		{
+			name:    "not an object",
+			output:  `["1:A->C"]`,
+			wantErr: "output: want object, got array",
+		},
+		{
+			name:    "not JSON",
+			output:  "Move disk 1 from A to C",
+			wantErr: "is not valid JSON",
+		},
+	}
+
+	entities := parseSource(t, `agent "a" { `+schema+` }`)
+	value, ok := entities[0].GetProperty("output_schema")
+	if !ok {
+		t.Fatal("output_schema not parsed")
+	}
+	validate := schemaValidator(value)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(tt.output)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validate() = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecute_ValidateOutputSchema(t *testing.T) {
+	const source = `
+agent "solver" {
+	model: "mock-model"
+}
+
+pipeline "p" {
+	step "move" {
+		use: agent("solver")
+		output_schema: {
+			move: string
+			next_state: object
+		}
+		%s
+	}
+}
+`
+	tests := []struct {
+		name    string
+		config  bool
+		props   string
+		output  string
+		wantErr string
+	}{
+		{name: "off by default", output: `{"move": "1:A->C"}`},
+		{name: "conforming", config: true, output: `{"move": "1:A->C", "next_state": {}}`},
+		{name: "missing key", config: true, output: `{"move": "1:A->C"}`, wantErr: `missing field "next_state"`},
+		{name: "step enables", props: "validate_output: true", output: `{"move": 1, "next_state": {}}`, wantErr: `field "move": want string`},
+		{name: "step disables", config: true, props: "validate_output: false", output: `{"move": "1:A->C"}`},
+		{name: "format checked first", config: true, props: `output_format: "single_line"`, output: "{}\n{}", wantErr: "spans more than one line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, fmt.Sprintf(source, tt.props)))
+
+			cfg := DefaultConfig()
+			cfg.ValidateOutputSchema = tt.config
+			mockProvider := NewMockProvider(WithMockResponses(MockResponse{Content: tt.output}))
+			rt := New(ws, WithProvider("mock", mockProvider), WithConfig(cfg))
+
+			result, err := rt.ExecuteByName(context.Background(), "pipeline", "p")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("execute error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if result.StepResults["move"].Output != tt.output {
+				t.Errorf("rejected step output = %v, want it kept for inspection", result.StepResults["move"].Output)
+			}
+		})
+	}
+}
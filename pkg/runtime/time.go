@@ -1,7 +1,51 @@
 package runtime
 
-import "time"
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
 
 // timeNow is a variable that returns the current time.
 // It's a variable so it can be mocked in tests.
 var timeNow = time.Now
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+// It returns ctx.Err() if the context ended the wait.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// durationProperty reads a duration from an entity property.
+// Strings use Go duration syntax ("500ms", "2m"); numbers are seconds.
+func durationProperty(entity ast.Entity, key string) (time.Duration, bool, error) {
+	prop, ok := entity.GetProperty(key)
+	if !ok {
+		return 0, false, nil
+	}
+
+	switch v := prop.(type) {
+	case ast.StringValue:
+		d, err := time.ParseDuration(v.Value)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid %s %q: %w", key, v.Value, err)
+		}
+		return d, true, nil
+	case ast.NumberValue:
+		return time.Duration(v.Value * float64(time.Second)), true, nil
+	default:
+		return 0, false, fmt.Errorf("%s must be a duration string or a number of seconds", key)
+	}
+}
@@ -0,0 +1,253 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// Tokenizer counts the tokens a model would see in a piece of text. The
+// runtime uses it to keep prompts within a token budget before they are
+// sent, so the count need not match the provider's exactly.
+type Tokenizer interface {
+	CountTokens(model, text string) int
+}
+
+// HeuristicTokenizer estimates token counts at about four characters per
+// token, which is close for English text on most models.
+type HeuristicTokenizer struct{}
+
+// CountTokens implements Tokenizer.
+func (HeuristicTokenizer) CountTokens(_, text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// WithTokenizer sets the tokenizer used to count prompt tokens and to
+// estimate usage a provider doesn't report. The default is
+// HeuristicTokenizer.
+func WithTokenizer(t Tokenizer) Option {
+	return func(r *Runtime) {
+		r.tokenizer = t
+	}
+}
+
+func (r *Runtime) countTokens(model, text string) int {
+	if r.tokenizer == nil {
+		return HeuristicTokenizer{}.CountTokens(model, text)
+	}
+	return r.tokenizer.CountTokens(model, text)
+}
+
+// ErrContextOverflow is returned (wrapped) when a request's prompt, plus the
+// tokens reserved for the response, would not fit in the model's context
+// window, so it is not sent.
+var ErrContextOverflow = errors.New("prompt exceeds the model's context window")
+
+// ContextWindows maps model names to the number of tokens their context
+// window holds. Like CostModel, a model without an exact entry uses the
+// longest key that prefixes it.
+type ContextWindows map[string]int
+
+// DefaultContextWindows returns the context windows of commonly used
+// models. Set Config.ContextWindows to override them.
+func DefaultContextWindows() ContextWindows {
+	return ContextWindows{
+		"claude":      200000,
+		"gpt-4o":      128000,
+		"gpt-4o-mini": 128000,
+		"o1":          200000,
+		"o3-mini":     200000,
+	}
+}
+
+// Window returns the context window of model, if known.
+func (c ContextWindows) Window(model string) (int, bool) {
+	if n, ok := c[model]; ok {
+		return n, true
+	}
+
+	best := ""
+	for name := range c {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	return c[best], true
+}
+
+// checkContextWindow returns ErrContextOverflow if req's system prompt and
+// messages, counted with the runtime's Tokenizer, leave too little of the
+// model's context window for MaxTokens of output. Models without a known
+// window are not checked.
+func (r *Runtime) checkContextWindow(req *CompletionRequest) error {
+	windows := r.config.ContextWindows
+	if windows == nil {
+		windows = DefaultContextWindows()
+	}
+	window, ok := windows.Window(req.Model)
+	if !ok {
+		return nil
+	}
+
+	prompt := r.countTokens(req.Model, req.SystemPrompt)
+	for _, msg := range req.Messages {
+		prompt += r.countTokens(req.Model, msg.Content)
+	}
+	if prompt+req.MaxTokens <= window {
+		return nil
+	}
+	return fmt.Errorf("%w: model %q holds %d tokens, but the prompt is about %d tokens with %d more reserved for output",
+		ErrContextOverflow, req.Model, window, prompt, req.MaxTokens)
+}
+
+// estimateUsage fills in resp.Usage from the runtime's Tokenizer when the
+// provider reported none, as Ollama and some OpenAI-compatible servers may
+// not, so budgets and metrics still see the request.
+func (r *Runtime) estimateUsage(req *CompletionRequest, resp *CompletionResponse) {
+	if resp == nil || resp.Usage.InputTokens != 0 || resp.Usage.OutputTokens != 0 {
+		return
+	}
+
+	input := r.countTokens(req.Model, req.SystemPrompt)
+	for _, msg := range req.Messages {
+		input += r.countTokens(req.Model, msg.Content)
+	}
+	output := r.countTokens(req.Model, resp.Content)
+	for _, call := range resp.ToolCalls {
+		args, _ := json.Marshal(call.Arguments)
+		output += r.countTokens(req.Model, call.Name+string(args))
+	}
+
+	resp.Usage = TokenUsage{
+		InputTokens:  input,
+		OutputTokens: output,
+		TotalTokens:  input + output,
+		Estimated:    true,
+	}
+}
+
+// truncationMarker replaces the text dropped from a section cut to fit the
+// prompt budget.
+const truncationMarker = "[earlier content truncated]\n"
+
+// stepPrompt holds the sections of a pipeline step's prompt so they can be
+// cut down separately to fit a token budget.
+type stepPrompt struct {
+	input   string
+	context string
+	prompt  string
+	format  string
+}
+
+func (p *stepPrompt) String() string {
+	var parts []string
+	if p.input != "" {
+		parts = append(parts, "## Input\n\n"+p.input)
+	}
+	if p.context != "" {
+		parts = append(parts, "## Context\n\n"+p.context)
+	}
+	parts = append(parts, p.prompt)
+	if p.format != "" {
+		parts = append(parts, "## Output Format\n\n"+p.format)
+	}
+	return joinNonEmpty(parts, "\n\n")
+}
+
+// maxPromptTokens returns the prompt budget for a step: its
+// max_prompt_tokens property if set, otherwise Config.MaxPromptTokens.
+func (r *Runtime) maxPromptTokens(step ast.Entity) int {
+	if v, ok := step.GetProperty("max_prompt_tokens"); ok {
+		if nv, ok := v.(ast.NumberValue); ok {
+			return int(nv.Value)
+		}
+	}
+	if r.config == nil {
+		return 0
+	}
+	return r.config.MaxPromptTokens
+}
+
+// fitStepPrompt cuts the context and then the input section of p until the
+// system prompt and p together fit in budget tokens, keeping the end of each
+// section. It emits a warning event when anything is cut, and fails if the
+// prompt is still over budget with both sections dropped.
+func (r *Runtime) fitStepPrompt(ctx *ExecutionContext, stepName, model, systemPrompt string, p *stepPrompt, budget int) error {
+	total := func() int {
+		return r.countTokens(model, systemPrompt) + r.countTokens(model, p.String())
+	}
+
+	before := total()
+	if before <= budget {
+		return nil
+	}
+
+	var truncated []string
+	for _, section := range []struct {
+		name string
+		text *string
+	}{
+		{"context", &p.context},
+		{"input", &p.input},
+	} {
+		cut := false
+		for n := total(); n > budget && *section.text != ""; n = total() {
+			keep := r.countTokens(model, *section.text) - (n - budget)
+			*section.text = r.keepTail(model, *section.text, keep)
+			cut = true
+		}
+		if cut {
+			truncated = append(truncated, section.name)
+		}
+	}
+
+	after := total()
+	if after > budget {
+		return fmt.Errorf("prompt for step %q needs %d tokens without its input and context, over max_prompt_tokens %d", stepName, after, budget)
+	}
+
+	ctx.EmitProgress(ProgressEvent{
+		Type:    ProgressTypeWarning,
+		Message: fmt.Sprintf("Truncated %s of step %s to fit %d prompt tokens", strings.Join(truncated, " and "), stepName, budget),
+		Step:    stepName,
+		Metadata: map[string]string{
+			"truncated":         strings.Join(truncated, ","),
+			"prompt_tokens":     strconv.Itoa(before),
+			"max_prompt_tokens": strconv.Itoa(budget),
+		},
+	})
+	return nil
+}
+
+// keepTail returns the longest end of text that, after truncationMarker,
+// fits in max tokens, or "" if nothing does.
+func (r *Runtime) keepTail(model, text string, max int) string {
+	max -= r.countTokens(model, truncationMarker)
+	if max <= 0 {
+		return ""
+	}
+	runes := []rune(strings.TrimPrefix(text, truncationMarker))
+	// Find the earliest start whose tail fits
+	lo, hi := 1, len(runes)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if r.countTokens(model, string(runes[mid:])) <= max {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	tail := strings.TrimLeft(string(runes[lo:]), " \t\r\n")
+	if tail == "" {
+		return ""
+	}
+	return truncationMarker + tail
+}
@@ -0,0 +1,301 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+// wordTokenizer counts whitespace-separated words, so budgets in tests are
+// easy to work out by hand.
+type wordTokenizer struct{}
+
+func (wordTokenizer) CountTokens(_, text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestHeuristicTokenizer(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hi", 1},
+		{"four", 1},
+		{"hello", 2},
+		{"héllo wörld!", 3},
+	}
+	for _, tt := range tests {
+		if got := (HeuristicTokenizer{}).CountTokens("any", tt.text); got != tt.want {
+			t.Errorf("CountTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHeuristicTokenizer_MatchesProviderCounts(t *testing.T) {
+	// Counts as reported by OpenAI (cl100k_base) for the same text
+	tests := []struct {
+		text     string
+		reported int
+	}{
+		{"Hello, world!", 4},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+	}
+	for _, tt := range tests {
+		got := (HeuristicTokenizer{}).CountTokens("gpt-4o", tt.text)
+		if diff := got - tt.reported; diff < -tt.reported/3-1 || diff > tt.reported/3+1 {
+			t.Errorf("CountTokens(%q) = %d, provider reported %d", tt.text, got, tt.reported)
+		}
+	}
+}
+
+func TestExecute_EstimatesMissingUsage(t *testing.T) {
+	const source = `
+agent "a" {
+	model: "mock-model"
+	instruction: "Be brief."
+}
+
+intent "i" {
+	use: agent("a")
+	input: "one two three"
+}
+`
+	tests := []struct {
+		name string
+		resp MockResponse
+		want TokenUsage
+	}{
+		{
+			name: "reported usage kept",
+			resp: MockResponse{Content: "four five", Usage: TokenUsage{InputTokens: 10, OutputTokens: 3, TotalTokens: 13}},
+			want: TokenUsage{InputTokens: 10, OutputTokens: 3, TotalTokens: 13},
+		},
+		{
+			name: "missing usage estimated",
+			resp: MockResponse{Content: "four five"},
+			want: TokenUsage{OutputTokens: 2, Estimated: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, source))
+
+			mockProvider := NewMockProvider(WithMockResponses(tt.resp))
+			rt := New(ws, WithProvider("mock", mockProvider), WithTokenizer(wordTokenizer{}))
+
+			result, err := rt.ExecuteByName(context.Background(), "intent", "i")
+			if err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			got := result.TokensUsed
+			if got.OutputTokens != tt.want.OutputTokens || got.Estimated != tt.want.Estimated {
+				t.Errorf("usage = %+v, want %+v", got, tt.want)
+			}
+			if tt.want.InputTokens != 0 && got.InputTokens != tt.want.InputTokens {
+				t.Errorf("input tokens = %d, want %d", got.InputTokens, tt.want.InputTokens)
+			}
+			if got.Estimated && (got.InputTokens < 5 || got.TotalTokens != got.InputTokens+got.OutputTokens) {
+				t.Errorf("estimated usage = %+v, want the system prompt and input counted", got)
+			}
+		})
+	}
+}
+
+func TestExecute_MaxPromptTokens(t *testing.T) {
+	// The system prompt is 2 words and the prompt 23: "## Input", 8 input
+	// words, "## Context", 10 context words and the instruction. The
+	// truncation marker is 3 words.
+	const source = `
+agent "summarizer" {
+	model: "mock-model"
+	instruction: "Be brief."
+}
+
+pipeline "p" {
+	step "summarize" {
+		use: agent("summarizer")
+		input: "one two three four five six seven eight"
+		context: "c1 c2 c3 c4 c5 c6 c7 c8 c9 c10"
+		prompt: "Summarize."
+		%s
+	}
+}
+`
+	tests := []struct {
+		name          string
+		config        int
+		step          string
+		wantContains  []string
+		wantMissing   []string
+		wantTruncated string
+		wantErr       bool
+	}{
+		{
+			name:         "no budget",
+			wantContains: []string{"one two", "c1 c2"},
+		},
+		{
+			name:         "within budget",
+			config:       25,
+			wantContains: []string{"one two", "c1 c2"},
+		},
+		{
+			name:          "context cut first",
+			config:        23,
+			wantContains:  []string{"one two three", truncationMarker + "c6 c7 c8 c9 c10"},
+			wantMissing:   []string{"c5"},
+			wantTruncated: "context",
+		},
+		{
+			name:          "then input",
+			config:        10,
+			wantContains:  []string{truncationMarker + "seven eight", "Summarize."},
+			wantMissing:   []string{"six", "## Context"},
+			wantTruncated: "context,input",
+		},
+		{
+			name:          "step overrides config",
+			config:        100,
+			step:          "max_prompt_tokens: 23",
+			wantContains:  []string{truncationMarker + "c6"},
+			wantTruncated: "context",
+		},
+		{
+			name:    "over budget without input and context",
+			config:  2,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, fmt.Sprintf(source, tt.step)))
+
+			cfg := DefaultConfig()
+			cfg.MaxPromptTokens = tt.config
+			mockProvider := NewMockProvider(WithMockResponses(MockResponse{Content: "ok"}))
+			rt := New(ws, WithProvider("mock", mockProvider), WithConfig(cfg), WithTokenizer(wordTokenizer{}))
+
+			var warnings []ProgressEvent
+			handler := &CallbackStreamHandler{ProgressFunc: func(e ProgressEvent) {
+				if e.Type == ProgressTypeWarning {
+					warnings = append(warnings, e)
+				}
+			}}
+			_, err := rt.ExecuteByName(context.Background(), "pipeline", "p", WithStreamHandler(handler))
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "max_prompt_tokens") {
+					t.Fatalf("error = %v, want a prompt budget error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+
+			req := mockProvider.LastRequest()
+			prompt := req.Messages[0].Content
+			for _, s := range tt.wantContains {
+				if !strings.Contains(prompt, s) {
+					t.Errorf("prompt %q does not contain %q", prompt, s)
+				}
+			}
+			for _, s := range tt.wantMissing {
+				if strings.Contains(prompt, s) {
+					t.Errorf("prompt %q contains %q", prompt, s)
+				}
+			}
+
+			if tt.wantTruncated == "" {
+				if len(warnings) != 0 {
+					t.Errorf("got warnings %+v, want none", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || warnings[0].Metadata["truncated"] != tt.wantTruncated {
+				t.Fatalf("warnings = %+v, want one truncating %s", warnings, tt.wantTruncated)
+			}
+			budget := tt.config
+			if tt.step != "" {
+				budget = 23
+			}
+			var words wordTokenizer
+			if n := words.CountTokens("", req.SystemPrompt) + words.CountTokens("", prompt); n > budget {
+				t.Errorf("prompt uses %d tokens, over the budget of %d", n, budget)
+			}
+		})
+	}
+}
+
+func TestExecute_ContextOverflow(t *testing.T) {
+	// The system prompt is 2 words and the prompt 5: "## Input" and the
+	// 3 input words
+	const source = `
+agent "a" {
+	model: "mock-model"
+	instruction: "Be brief."
+	%s
+}
+
+intent "i" {
+	use: agent("a")
+	input: "one two three"
+}
+`
+	tests := []struct {
+		name    string
+		windows ContextWindows
+		agent   string
+		wantErr string
+	}{
+		{name: "fits", windows: ContextWindows{"mock-model": 100}, agent: "max_tokens: 50"},
+		{name: "exactly fits", windows: ContextWindows{"mock": 7}},
+		{
+			name:    "no room for output",
+			windows: ContextWindows{"mock": 7},
+			agent:   "max_tokens: 1",
+			wantErr: `model "mock-model" holds 7 tokens, but the prompt is about 7 tokens with 1 more reserved for output`,
+		},
+		{
+			name:    "longest prefix wins",
+			windows: ContextWindows{"mock": 100, "mock-mod": 6},
+			wantErr: `holds 6 tokens`,
+		},
+		{name: "unknown model", windows: ContextWindows{"other": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, fmt.Sprintf(source, tt.agent)))
+
+			cfg := DefaultConfig()
+			cfg.ContextWindows = tt.windows
+			mockProvider := NewMockProvider(WithMockResponses(MockResponse{Content: "ok"}))
+			rt := New(ws, WithProvider("mock", mockProvider), WithConfig(cfg), WithTokenizer(wordTokenizer{}))
+
+			_, err := rt.ExecuteByName(context.Background(), "intent", "i")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("execute error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrContextOverflow) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want ErrContextOverflow with %q", err, tt.wantErr)
+			}
+			if n := len(mockProvider.GetRequests()); n != 0 {
+				t.Errorf("provider got %d requests, want none", n)
+			}
+		})
+	}
+}
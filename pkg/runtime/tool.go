@@ -0,0 +1,119 @@
+package runtime
+
+import (
+	"fmt"
+)
+
+// maxToolTurns bounds how many requests a single intent or step may make
+// while the model keeps asking for tool calls.
+const maxToolTurns = 10
+
+// Tool is a tool implemented in Go. Register it with WithTool and agents can
+// list it by name in their tools property, alongside tool entities and MCP
+// servers. A registered tool takes precedence over a tool entity of the same
+// name.
+type Tool interface {
+	Name() string
+	Invoke(args map[string]interface{}) (interface{}, error)
+}
+
+// ToolDescriber is implemented by Tools that describe themselves to the
+// model. Tools that don't are offered with their name as the description and
+// no parameter schema.
+type ToolDescriber interface {
+	Description() string
+	Parameters() map[string]interface{}
+}
+
+// WithTool registers a Go tool that agents can call.
+func WithTool(tool Tool) Option {
+	return func(r *Runtime) {
+		r.tools[tool.Name()] = tool
+	}
+}
+
+// RegisterTool registers a Go tool that agents can call.
+func (r *Runtime) RegisterTool(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name()] = tool
+}
+
+// getTool returns a registered Go tool by name.
+func (r *Runtime) getTool(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// toolDefinition describes a registered Go tool to the model.
+func toolDefinition(tool Tool) ToolDefinition {
+	def := ToolDefinition{Name: tool.Name(), Description: tool.Name()}
+	if d, ok := tool.(ToolDescriber); ok {
+		if desc := d.Description(); desc != "" {
+			def.Description = desc
+		}
+		def.Parameters = d.Parameters()
+	}
+	return def
+}
+
+// completeWithTools sends req and, for as long as the model responds with
+// tool calls, runs them and sends their results back, up to maxToolTurns
+// requests. A failing tool call is reported to the model rather than ending
+// the exchange. It returns the final response and the tokens used by every
+// request.
+func (r *Runtime) completeWithTools(ctx *ExecutionContext, provider LLMProvider, req *CompletionRequest, resolver *Resolver) (*CompletionResponse, TokenUsage, error) {
+	var usage TokenUsage
+	messages := req.Messages
+
+	var resp *CompletionResponse
+	for turn := 0; turn < maxToolTurns; turn++ {
+		turnReq := *req
+		turnReq.Messages = messages
+
+		var err error
+		resp, err = r.complete(ctx, provider, &turnReq)
+		if err != nil {
+			return nil, usage, err
+		}
+		usage.Add(resp.Usage)
+		ctx.run.addTokens(resp.Usage)
+
+		// If no tool calls, we're done
+		if len(resp.ToolCalls) == 0 || resp.FinishReason != FinishReasonToolUse {
+			return resp, usage, nil
+		}
+
+		messages = append(messages, Message{
+			Role:      RoleAssistant,
+			Content:   resp.Content,
+			ToolCalls: resp.ToolCalls,
+		})
+
+		for _, tc := range resp.ToolCalls {
+			ctx.EmitProgress(ProgressEvent{
+				Type:    ProgressTypeStep,
+				Message: fmt.Sprintf("Executing tool: %s", tc.Name),
+				Metadata: map[string]string{
+					"tool": tc.Name,
+				},
+			})
+
+			toolResult, err := r.executeToolCall(ctx, tc, resolver)
+			if err != nil {
+				// We report the error back to the LLM so it can try to fix it
+				toolResult = fmt.Sprintf("Error: %v", err)
+			}
+
+			messages = append(messages, Message{
+				Role:       RoleTool,
+				Content:    toString(toolResult),
+				ToolCallID: tc.ID,
+			})
+		}
+	}
+
+	return resp, usage, nil
+}
@@ -0,0 +1,226 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/workspace"
+)
+
+// moveValidator is a Go tool that accepts moves onto peg C only.
+type moveValidator struct {
+	calls []map[string]interface{}
+}
+
+func (v *moveValidator) Name() string { return "validate_move" }
+
+func (v *moveValidator) Invoke(args map[string]interface{}) (interface{}, error) {
+	v.calls = append(v.calls, args)
+	if args["to"] != "C" {
+		return nil, errors.New("illegal move")
+	}
+	return "legal", nil
+}
+
+func (v *moveValidator) Description() string { return "Checks a move" }
+
+func (v *moveValidator) Parameters() map[string]interface{} {
+	return map[string]interface{}{"to": "string"}
+}
+
+func TestExecute_GoToolCalls(t *testing.T) {
+	source := `
+agent "solver" {
+	model: "mock-model"
+	tools: ["validate_move"]
+}
+
+intent "solve" {
+	use: agent("solver")
+	input: "move disk 1"
+}
+
+pipeline "p" {
+	step "move" {
+		use: agent("solver")
+		input: "move disk 1"
+	}
+}
+`
+	tests := []struct {
+		name       string
+		entityType string
+		entityName string
+		to         string
+		wantResult string
+	}{
+		{"intent", "intent", "solve", "C", "legal"},
+		{"pipeline step", "pipeline", "p", "C", "legal"},
+		{"tool error reported to model", "intent", "solve", "B", "Error: illegal move"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, source))
+
+			tool := &moveValidator{}
+			mockProvider := NewMockProvider(WithMockResponses(
+				MockResponse{
+					ToolCalls:    []ToolCall{{ID: "call-1", Name: "validate_move", Arguments: map[string]interface{}{"to": tt.to}}},
+					FinishReason: FinishReasonToolUse,
+					Usage:        TokenUsage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+				},
+				MockResponse{
+					Content:      "disk 1 from A to C",
+					FinishReason: FinishReasonStop,
+					Usage:        TokenUsage{InputTokens: 20, OutputTokens: 5, TotalTokens: 25},
+				},
+			))
+			rt := New(ws, WithProvider("mock", mockProvider), WithTool(tool))
+
+			result, err := rt.ExecuteByName(context.Background(), tt.entityType, tt.entityName)
+			if err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+			if fmt.Sprint(result.Output) != "disk 1 from A to C" {
+				t.Errorf("Output = %v, want the final response", result.Output)
+			}
+			if result.TokensUsed.TotalTokens != 40 {
+				t.Errorf("TokensUsed = %+v, want both requests counted", result.TokensUsed)
+			}
+			if len(tool.calls) != 1 {
+				t.Fatalf("tool invoked %d times, want 1", len(tool.calls))
+			}
+
+			reqs := mockProvider.GetRequests()
+			if len(reqs) != 2 {
+				t.Fatalf("got %d requests, want 2", len(reqs))
+			}
+			if len(reqs[0].Tools) != 1 || reqs[0].Tools[0].Description != "Checks a move" {
+				t.Errorf("Tools = %+v, want the registered tool", reqs[0].Tools)
+			}
+			last := reqs[1].Messages[len(reqs[1].Messages)-1]
+			if last.Role != RoleTool || last.ToolCallID != "call-1" || last.Content != tt.wantResult {
+				t.Errorf("tool result message = %+v, want %q", last, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestExecute_StreamingToolCalls(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		provider func(url string) LLMProvider
+		toolCall []string // SSE events of the response calling the tool
+		answer   []string // SSE events of the final response
+	}{
+		{
+			name:  "openai",
+			model: "gpt-4o",
+			provider: func(url string) LLMProvider {
+				return NewOpenAIProvider(WithOpenAIAPIKey("test-key"), WithOpenAIBaseURL(url))
+			},
+			toolCall: []string{
+				`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call-1","type":"function","function":{"name":"validate_move","arguments":""}}]}}]}`,
+				`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"to\":"}}]}}]}`,
+				`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"C\"}"}}]},"finish_reason":"tool_calls"}]}`,
+				`data: [DONE]`,
+			},
+			answer: []string{
+				`data: {"choices":[{"delta":{"content":"disk 1 from A to C"},"finish_reason":"stop"}]}`,
+				`data: [DONE]`,
+			},
+		},
+		{
+			name:  "anthropic",
+			model: "claude-sonnet-4-20250514",
+			provider: func(url string) LLMProvider {
+				return NewAnthropicProvider(WithAnthropicAPIKey("test-key"), WithAnthropicBaseURL(url))
+			},
+			toolCall: []string{
+				"event: content_block_start\ndata: {\"index\":0,\"content_block\":{\"type\":\"tool_use\",\"id\":\"call-1\",\"name\":\"validate_move\",\"input\":{}}}",
+				"event: content_block_delta\ndata: {\"index\":0,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"to\\\":\"}}",
+				"event: content_block_delta\ndata: {\"index\":0,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"\\\"C\\\"}\"}}",
+				"event: message_delta\ndata: {\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":5}}",
+			},
+			answer: []string{
+				"event: content_block_delta\ndata: {\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"disk 1 from A to C\"}}",
+				"event: message_delta\ndata: {\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":5}}",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var got map[string]interface{}
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("decode request: %v", err)
+				}
+				requests = append(requests, got)
+				events := tt.toolCall
+				if len(requests) > 1 {
+					events = tt.answer
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				for _, e := range events {
+					fmt.Fprintf(w, "%s\n\n", e)
+				}
+			}))
+			defer server.Close()
+
+			ws := workspace.New()
+			addEntities(t, ws, parseSource(t, fmt.Sprintf(`
+agent "solver" {
+	model: %q
+	tools: ["validate_move"]
+}
+
+pipeline "p" {
+	step "move" {
+		use: agent("solver")
+		input: "move disk 1"
+	}
+}
+`, tt.model)))
+
+			tool := &moveValidator{}
+			rt := New(ws, WithProvider(tt.name, tt.provider(server.URL)), WithTool(tool))
+			handler := &BufferedStreamHandler{}
+
+			result, err := rt.ExecuteByName(context.Background(), "pipeline", "p", WithStreamHandler(handler))
+			if err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+			if fmt.Sprint(result.Output) != "disk 1 from A to C" {
+				t.Errorf("Output = %v, want the final response", result.Output)
+			}
+			if len(tool.calls) != 1 || tool.calls[0]["to"] != "C" {
+				t.Fatalf("tool calls = %v, want one with to=C", tool.calls)
+			}
+			if len(requests) != 2 {
+				t.Fatalf("got %d requests, want 2", len(requests))
+			}
+			for i, req := range requests {
+				if req["stream"] != true {
+					t.Errorf("request %d was not streamed", i+1)
+				}
+				if tools, _ := req["tools"].([]interface{}); len(tools) != 1 {
+					t.Errorf("request %d tools = %v, want the registered tool", i+1, req["tools"])
+				}
+			}
+			if !strings.Contains(fmt.Sprint(requests[1]["messages"]), "call-1") {
+				t.Errorf("second request does not carry the tool call and result: %v", requests[1]["messages"])
+			}
+		})
+	}
+}
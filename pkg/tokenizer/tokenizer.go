@@ -3,6 +3,7 @@
 package tokenizer
 
 import (
+	"strings"
 	"unicode"
 )
 
@@ -18,7 +19,7 @@ const (
 	TokenTypeSemicolon
 	// TokenTypeMultilineString represents a multiline string literal
 	TokenTypeMultilineString
-	// TokenTypeComment represents a comment (# ...)
+	// TokenTypeComment represents a comment (# ..., // ... or /* ... */)
 	TokenTypeComment
 	// TokenTypeLeftBrace represents an opening brace ({)
 	TokenTypeLeftBrace
@@ -91,7 +92,35 @@ func (t *Tokenizer) Tokenize(input string) []Token {
 
 	for i < len(input) {
 		switch {
-		case input[i] == '#':
+		case input[i] == '/' && i+1 < len(input) && input[i+1] == '*':
+			// Handle block comments, which may span lines. An unterminated
+			// block comment runs to the end of the input.
+			startCol := column
+			startLine := line
+			start := i
+			i += 2
+			column += 2
+			for i < len(input) && !(input[i] == '*' && i+1 < len(input) && input[i+1] == '/') {
+				if input[i] == '\n' {
+					line++
+					column = 1
+				} else {
+					column++
+				}
+				i++
+			}
+			if i < len(input) {
+				i += 2
+				column += 2
+			}
+			tokens = append(tokens, Token{
+				Type:   TokenTypeComment,
+				Value:  input[start:i],
+				Line:   startLine,
+				Column: startCol,
+			})
+
+		case input[i] == '#' || (input[i] == '/' && i+1 < len(input) && input[i+1] == '/'):
 			// Handle single-line comments
 			startCol := column
 			start := i
@@ -371,14 +400,42 @@ func (t *Tokenizer) Tokenize(input string) []Token {
 				column += 3
 			}
 
+		case strings.HasPrefix(input[i:], `"""`):
+			// Triple-quoted strings are verbatim, like triple backticks
+			startCol := column
+			startLine := line
+			i += 3
+			column += 3
+			start := i
+			for i < len(input) && !strings.HasPrefix(input[i:], `"""`) {
+				if input[i] == '\n' {
+					line++
+					column = 1
+				} else {
+					column++
+				}
+				i++
+			}
+
+			if i < len(input) {
+				tokens = append(tokens, Token{
+					Type:   TokenTypeMultilineString,
+					Value:  input[start:i],
+					Line:   startLine,
+					Column: startCol,
+				})
+				i += 3
+				column += 3
+			}
+
 		case input[i] == '"':
 			startCol := column
 			i++ // Skip opening quote
 			column++
-			start := i
+			var value strings.Builder
 			for i < len(input) && input[i] != '"' {
 				if input[i] == '\\' && i+1 < len(input) {
-					// Skip escaped character
+					value.WriteString(unescape(input[i+1]))
 					i += 2
 					column += 2
 					continue
@@ -389,12 +446,13 @@ func (t *Tokenizer) Tokenize(input string) []Token {
 				} else {
 					column++
 				}
+				value.WriteByte(input[i])
 				i++
 			}
 			if i < len(input) {
 				tokens = append(tokens, Token{
 					Type:   TokenTypeString,
-					Value:  input[start:i],
+					Value:  value.String(),
 					Line:   line,
 					Column: startCol,
 				})
@@ -422,6 +480,26 @@ func (t *Tokenizer) Tokenize(input string) []Token {
 	return tokens
 }
 
+// unescape decodes the escape sequence whose character follows a backslash
+// in a double-quoted string. Unknown escapes are kept as written, so
+// backslashes in patterns and Windows paths survive.
+func unescape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	case '"':
+		return `"`
+	case '\\':
+		return `\`
+	default:
+		return `\` + string(c)
+	}
+}
+
 func (t TokenType) String() string {
 	switch t {
 	case TokenTypeIdentifier:
@@ -158,6 +158,23 @@ func TestTokenizer_Tokenize(t *testing.T) {
 				{Type: TokenTypeMultilineString, Value: "\nYou are helpful.\n", Line: 1, Column: 14},
 			},
 		},
+		{
+			name:  "escaped_string",
+			input: `"a\"b\\c\nd\te\x"`,
+			expected: []Token{
+				{Type: TokenTypeString, Value: "a\"b\\c\nd\te\\x", Line: 1, Column: 1},
+			},
+		},
+		{
+			name:  "triple_quoted_string",
+			input: "contents: \"\"\"\nSay \"hi\".\n\"\"\";",
+			expected: []Token{
+				{Type: TokenTypeIdentifier, Value: "contents", Line: 1, Column: 1},
+				{Type: TokenTypeColon, Value: ":", Line: 1, Column: 9},
+				{Type: TokenTypeMultilineString, Value: "\nSay \"hi\".\n", Line: 1, Column: 11},
+				{Type: TokenTypeSemicolon, Value: ";", Line: 3, Column: 4},
+			},
+		},
 		{
 			name:     "empty_input",
 			input:    "",
@@ -335,6 +352,40 @@ func TestTokenizer_Comments(t *testing.T) {
 				{Type: TokenTypeComment, Value: "# inline comment", Line: 1, Column: 23},
 			},
 		},
+		{
+			name:  "double_slash_comment",
+			input: "file \"a\" // trailing\n;",
+			expected: []Token{
+				{Type: TokenTypeIdentifier, Value: "file", Line: 1, Column: 1},
+				{Type: TokenTypeString, Value: "a", Line: 1, Column: 6},
+				{Type: TokenTypeComment, Value: "// trailing", Line: 1, Column: 10},
+				{Type: TokenTypeSemicolon, Value: ";", Line: 2, Column: 1},
+			},
+		},
+		{
+			name:  "block_comment_spanning_lines",
+			input: "/* one\ntwo */ file",
+			expected: []Token{
+				{Type: TokenTypeComment, Value: "/* one\ntwo */", Line: 1, Column: 1},
+				{Type: TokenTypeIdentifier, Value: "file", Line: 2, Column: 8},
+			},
+		},
+		{
+			name:  "unterminated_block_comment",
+			input: "file /* never closed",
+			expected: []Token{
+				{Type: TokenTypeIdentifier, Value: "file", Line: 1, Column: 1},
+				{Type: TokenTypeComment, Value: "/* never closed", Line: 1, Column: 6},
+			},
+		},
+		{
+			name:  "comment_markers_inside_strings",
+			input: `"http://example.com" "/* not a comment */"`,
+			expected: []Token{
+				{Type: TokenTypeString, Value: "http://example.com", Line: 1, Column: 1},
+				{Type: TokenTypeString, Value: "/* not a comment */", Line: 1, Column: 22},
+			},
+		},
 	}
 
 	for _, tt := range tests {
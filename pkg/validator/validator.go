@@ -2,6 +2,8 @@ package validator
 
 import (
 	"fmt"
+	"math"
+	"sort"
 
 	"github.com/shellkjell/langspace/pkg/ast"
 )
@@ -129,13 +131,21 @@ func (v *Validator) validateAgentEntity(entity ast.Entity) error {
 		return fmt.Errorf("agent entity must have a name")
 	}
 
-	// Check for required model property
-	_, hasModel := entity.GetProperty("model")
-	if !hasModel {
-		return fmt.Errorf("agent entity must have 'model' property")
+	// The model may be omitted: the runtime then uses the config's
+	// default_model, or its own default model
+	if model, ok := entity.GetProperty("model"); ok {
+		if sv, ok := model.(ast.StringValue); !ok || sv.Value == "" {
+			return fmt.Errorf("agent entity 'model' must be a non-empty string")
+		}
 	}
 
-	return nil
+	if val, ok := entity.GetProperty("temperature"); ok {
+		if err := validateTemperature(val); err != nil {
+			return fmt.Errorf("agent entity 'temperature' %w", err)
+		}
+	}
+
+	return validateSamplingParams("agent", entity)
 }
 
 // validateToolEntity validates a tool entity
@@ -176,7 +186,7 @@ func (v *Validator) validatePipelineEntity(entity ast.Entity) error {
 		return fmt.Errorf("pipeline entity must have a name")
 	}
 
-	return nil
+	return validateNestedSteps(entity)
 }
 
 // validateStepEntity validates a step entity
@@ -191,6 +201,136 @@ func (v *Validator) validateStepEntity(entity ast.Entity) error {
 		return fmt.Errorf("step entity must have 'use' property")
 	}
 
+	return validateStepSettings(entity)
+}
+
+// validateStepSettings checks the model settings a step may override: its
+// temperature and sampling settings.
+func validateStepSettings(entity ast.Entity) error {
+	if val, ok := entity.GetProperty("temperature"); ok {
+		if err := validateTemperature(val); err != nil {
+			return fmt.Errorf("step entity 'temperature' %w", err)
+		}
+	}
+
+	return validateSamplingParams("step", entity)
+}
+
+// validateNestedSteps checks the settings of the steps nested in a pipeline,
+// including those in parallel blocks, branch cases and loop bodies, which
+// are not added to the workspace, and so not validated, on their own.
+func validateNestedSteps(entity ast.Entity) error {
+	var nested []ast.Entity
+	switch e := entity.(type) {
+	case *ast.PipelineEntity:
+		for _, step := range e.Steps {
+			nested = append(nested, step)
+		}
+	case *ast.ParallelEntity:
+		for _, step := range e.Steps {
+			nested = append(nested, step)
+		}
+	}
+	props := entity.Properties()
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		nested = appendNestedEntities(nested, props[k])
+	}
+
+	for _, e := range nested {
+		if e.Type() == "step" {
+			if err := validateStepSettings(e); err != nil {
+				return fmt.Errorf("step %q: %w", e.Name(), err)
+			}
+		}
+		if err := validateNestedSteps(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendNestedEntities appends the entities nested in v, such as branch
+// cases and loop bodies, to entities.
+func appendNestedEntities(entities []ast.Entity, v ast.Value) []ast.Entity {
+	switch val := v.(type) {
+	case ast.NestedEntityValue:
+		if val.Entity != nil {
+			entities = append(entities, val.Entity)
+		}
+	case ast.BranchValue:
+		cases := make([]string, 0, len(val.Cases))
+		for k := range val.Cases {
+			cases = append(cases, k)
+		}
+		sort.Strings(cases)
+		for _, k := range cases {
+			entities = appendNestedEntities(entities, val.Cases[k])
+		}
+	case ast.LoopValue:
+		for _, body := range val.Body {
+			entities = appendNestedEntities(entities, body)
+		}
+	case ast.ArrayValue:
+		for _, elem := range val.Elements {
+			entities = appendNestedEntities(entities, elem)
+		}
+	}
+	return entities
+}
+
+// validateTemperature checks that a temperature is a number in the range
+// the providers accept.
+func validateTemperature(val ast.Value) error {
+	nv, ok := val.(ast.NumberValue)
+	if !ok {
+		return fmt.Errorf("must be a number")
+	}
+	if nv.Value < 0 || nv.Value > 2 {
+		return fmt.Errorf("must be between 0 and 2, got %g", nv.Value)
+	}
+	return nil
+}
+
+// samplingParams are the sampling settings an agent or step may set for the
+// providers that support them, with the values those providers accept.
+var samplingParams = []struct {
+	name     string
+	min, max float64
+	whole    bool
+}{
+	{name: "top_p", min: 0, max: 1},
+	{name: "top_k", min: 1, max: math.MaxInt32, whole: true},
+	{name: "frequency_penalty", min: -2, max: 2},
+	{name: "presence_penalty", min: -2, max: 2},
+}
+
+// validateSamplingParams checks that the sampling settings of an agent or
+// step are numbers in range.
+func validateSamplingParams(entityType string, entity ast.Entity) error {
+	for _, param := range samplingParams {
+		val, ok := entity.GetProperty(param.name)
+		if !ok {
+			continue
+		}
+		nv, ok := val.(ast.NumberValue)
+		if !ok {
+			return fmt.Errorf("%s entity '%s' must be a number", entityType, param.name)
+		}
+		if param.whole && nv.Value != math.Trunc(nv.Value) {
+			return fmt.Errorf("%s entity '%s' must be a whole number", entityType, param.name)
+		}
+		if nv.Value < param.min || nv.Value > param.max {
+			if param.whole {
+				return fmt.Errorf("%s entity '%s' must be at least %g", entityType, param.name, param.min)
+			}
+			return fmt.Errorf("%s entity '%s' must be between %g and %g", entityType, param.name, param.min, param.max)
+		}
+	}
 	return nil
 }
 
@@ -219,6 +359,20 @@ func (v *Validator) validateConfigEntity(entity ast.Entity) error {
 		return fmt.Errorf("config entity must have at least one property")
 	}
 
+	// Agent defaults must have the type of the agent property they stand in for
+	for _, key := range []string{"default_model", "default_instruction"} {
+		if val, ok := entity.GetProperty(key); ok {
+			if sv, ok := val.(ast.StringValue); !ok || sv.Value == "" {
+				return fmt.Errorf("config '%s' must be a non-empty string", key)
+			}
+		}
+	}
+	if val, ok := entity.GetProperty("default_temperature"); ok {
+		if err := validateTemperature(val); err != nil {
+			return fmt.Errorf("config 'default_temperature' %w", err)
+		}
+	}
+
 	return nil
 }
 
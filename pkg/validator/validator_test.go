@@ -120,8 +120,87 @@ func TestValidator_ValidateEntity(t *testing.T) {
 				e.SetProperty("instruction", ast.StringValue{Value: "test"})
 				return e
 			}(),
+			wantError: false,
+		},
+		{
+			name: "agent entity with non-string model",
+			entity: func() ast.Entity {
+				e := ast.NewAgentEntity("test")
+				e.SetProperty("model", ast.NumberValue{Value: 4})
+				return e
+			}(),
+			wantError: true,
+			errorMsg:  "agent entity 'model' must be a non-empty string",
+		},
+		{
+			name: "agent entity with temperature",
+			entity: func() ast.Entity {
+				e := createAgentEntity("test")
+				e.SetProperty("temperature", ast.NumberValue{Value: 0})
+				return e
+			}(),
+			wantError: false,
+		},
+		{
+			name: "agent entity with non-number temperature",
+			entity: func() ast.Entity {
+				e := createAgentEntity("test")
+				e.SetProperty("temperature", ast.StringValue{Value: "hot"})
+				return e
+			}(),
+			wantError: true,
+			errorMsg:  "agent entity 'temperature' must be a number",
+		},
+		{
+			name: "agent entity with temperature out of range",
+			entity: func() ast.Entity {
+				e := createAgentEntity("test")
+				e.SetProperty("temperature", ast.NumberValue{Value: 2.5})
+				return e
+			}(),
 			wantError: true,
-			errorMsg:  "agent entity must have 'model' property",
+			errorMsg:  "agent entity 'temperature' must be between 0 and 2, got 2.5",
+		},
+		{
+			name: "agent entity with sampling params",
+			entity: func() ast.Entity {
+				e := createAgentEntity("test")
+				e.SetProperty("top_p", ast.NumberValue{Value: 0.9})
+				e.SetProperty("top_k", ast.NumberValue{Value: 40})
+				e.SetProperty("frequency_penalty", ast.NumberValue{Value: -0.5})
+				return e
+			}(),
+			wantError: false,
+		},
+		{
+			name: "agent entity with non-number top_p",
+			entity: func() ast.Entity {
+				e := createAgentEntity("test")
+				e.SetProperty("top_p", ast.StringValue{Value: "high"})
+				return e
+			}(),
+			wantError: true,
+			errorMsg:  "agent entity 'top_p' must be a number",
+		},
+		{
+			name: "agent entity with top_p out of range",
+			entity: func() ast.Entity {
+				e := createAgentEntity("test")
+				e.SetProperty("top_p", ast.NumberValue{Value: 1.5})
+				return e
+			}(),
+			wantError: true,
+			errorMsg:  "agent entity 'top_p' must be between 0 and 1",
+		},
+		{
+			name: "agent entity with fractional top_k",
+			entity: func() ast.Entity {
+				e := createAgentEntity("test")
+				e.SetProperty("top_k", ast.NumberValue{Value: 2.5})
+				return e
+			}(),
+			wantError: true,
+			errorMsg:  "agent entity 'top_k' must be a whole number",
 		},
 		{
 			name:      "valid tool entity",
@@ -172,6 +251,35 @@ func TestValidator_ValidateEntity(t *testing.T) {
 			wantError: true,
 			errorMsg:  "pipeline entity must have a name",
 		},
+		{
+			name: "pipeline entity with step temperature out of range",
+			entity: func() ast.Entity {
+				p := ast.NewPipelineEntity("build")
+				step := createStepEntity("compile").(*ast.StepEntity)
+				step.SetProperty("temperature", ast.NumberValue{Value: 3})
+				p.Steps = append(p.Steps, step)
+				return p
+			}(),
+			wantError: true,
+			errorMsg:  "step \"compile\": step entity 'temperature' must be between 0 and 2, got 3",
+		},
+		{
+			name: "pipeline entity with branch step temperature out of range",
+			entity: func() ast.Entity {
+				p := ast.NewPipelineEntity("build")
+				route := createStepEntity("route")
+				fix := createStepEntity("fix")
+				fix.SetProperty("temperature", ast.NumberValue{Value: -1})
+				route.SetProperty("branch", ast.BranchValue{
+					Condition: ast.StringValue{Value: "bug"},
+					Cases:     map[string]ast.NestedEntityValue{"bug": {Entity: fix}},
+				})
+				p.Steps = append(p.Steps, route.(*ast.StepEntity))
+				return p
+			}(),
+			wantError: true,
+			errorMsg:  "step \"fix\": step entity 'temperature' must be between 0 and 2, got -1",
+		},
 		{
 			name:      "valid trigger entity",
 			entity:    createTriggerEntity("startup"),
@@ -204,6 +312,26 @@ func TestValidator_ValidateEntity(t *testing.T) {
 			wantError: true,
 			errorMsg:  "config entity must have at least one property",
 		},
+		{
+			name: "config entity with non-string default_model",
+			entity: func() ast.Entity {
+				e := ast.NewConfigEntity()
+				e.SetProperty("default_model", ast.BoolValue{Value: true})
+				return e
+			}(),
+			wantError: true,
+			errorMsg:  "config 'default_model' must be a non-empty string",
+		},
+		{
+			name: "config entity with non-number default_temperature",
+			entity: func() ast.Entity {
+				e := ast.NewConfigEntity()
+				e.SetProperty("default_temperature", ast.StringValue{Value: "warm"})
+				return e
+			}(),
+			wantError: true,
+			errorMsg:  "config 'default_temperature' must be a number",
+		},
 		{
 			name:      "valid mcp entity",
 			entity:    createMCPEntity("server"),
@@ -236,6 +364,26 @@ func TestValidator_ValidateEntity(t *testing.T) {
 			wantError: true,
 			errorMsg:  "step entity must have a name",
 		},
+		{
+			name: "step entity with presence_penalty out of range",
+			entity: func() ast.Entity {
+				e := createStepEntity("process")
+				e.SetProperty("presence_penalty", ast.NumberValue{Value: 3})
+				return e
+			}(),
+			wantError: true,
+			errorMsg:  "step entity 'presence_penalty' must be between -2 and 2",
+		},
+		{
+			name: "step entity with temperature out of range",
+			entity: func() ast.Entity {
+				e := createStepEntity("process")
+				e.SetProperty("temperature", ast.NumberValue{Value: 2.5})
+				return e
+			}(),
+			wantError: true,
+			errorMsg:  "step entity 'temperature' must be between 0 and 2, got 2.5",
+		},
 		{
 			name: "step entity without use",
 			entity: func() ast.Entity {
@@ -0,0 +1,148 @@
+package workspace
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// HasStepDependencies reports whether any step of a pipeline declares
+// depends_on, in which case the pipeline runs as a dependency graph rather
+// than strictly in order.
+func HasStepDependencies(pipeline ast.Entity) bool {
+	for _, step := range childSteps(pipeline) {
+		if _, ok := step.GetProperty("depends_on"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// StepDependencies returns, for each step of a pipeline, the names of the
+// steps it must wait for. A step depends on the steps named in its
+// depends_on property, written as a step name, a step("name") reference or
+// an array of either, and on any step whose output it references, such as
+// step("name").output.
+//
+// An error is returned if a step depends on a step that does not exist in
+// the pipeline or the dependencies form a cycle.
+func StepDependencies(pipeline ast.Entity) (map[string][]string, error) {
+	steps := childSteps(pipeline)
+	known := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		known[step.Name()] = true
+	}
+
+	deps := make(map[string][]string, len(steps))
+	for _, step := range steps {
+		seen := make(map[string]bool)
+		add := func(name string) {
+			if !seen[name] {
+				seen[name] = true
+				deps[step.Name()] = append(deps[step.Name()], name)
+			}
+		}
+
+		if val, ok := step.GetProperty("depends_on"); ok {
+			names, err := dependencyNames(val)
+			if err != nil {
+				return nil, fmt.Errorf("step %q: %w", step.Name(), err)
+			}
+			for _, name := range names {
+				if name == step.Name() {
+					return nil, fmt.Errorf("step %q depends on itself", step.Name())
+				}
+				if !known[name] {
+					return nil, fmt.Errorf("step %q depends on unknown step %q", step.Name(), name)
+				}
+				add(name)
+			}
+		}
+
+		// Output references are implicit dependencies
+		for _, ref := range EntityReferences(step) {
+			if ref.Property == "depends_on" || ref.Target.Type != "step" {
+				continue
+			}
+			if name := ref.Target.Name; known[name] && name != step.Name() {
+				add(name)
+			}
+		}
+	}
+
+	if cycle := findCycle(steps, deps); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return deps, nil
+}
+
+// dependencyNames extracts step names from a depends_on value.
+func dependencyNames(val ast.Value) ([]string, error) {
+	switch v := val.(type) {
+	case ast.StringValue:
+		return []string{v.Value}, nil
+	case ast.ReferenceValue:
+		if v.Type != "step" {
+			return nil, fmt.Errorf("depends_on must reference steps, got %s(%q)", v.Type, v.Name)
+		}
+		return []string{v.Name}, nil
+	case ast.ArrayValue:
+		var names []string
+		for _, elem := range v.Elements {
+			n, err := dependencyNames(elem)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, n...)
+		}
+		return names, nil
+	default:
+		return nil, fmt.Errorf("depends_on must be a step name, step reference or array, got %T", val)
+	}
+}
+
+// findCycle returns a dependency cycle as a list of step names starting and
+// ending with the same step, or nil if there is none. Steps are visited in
+// declaration order so the reported cycle is deterministic.
+func findCycle(steps []*ast.StepEntity, deps map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(steps))
+	var path []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			switch state[dep] {
+			case visiting:
+				for i, n := range path {
+					if n == dep {
+						return append(append([]string{}, path[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, step := range steps {
+		if state[step.Name()] == unvisited {
+			if cycle := visit(step.Name()); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package workspace
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/parser"
+)
+
+func TestStepDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    map[string][]string
+		wantErr string
+	}{
+		{
+			name: "explicit and implicit dependencies",
+			source: `
+pipeline "p" {
+  step "fetch" { use: agent("a") }
+  step "lint" { use: agent("a") }
+  step "test" { use: agent("a") depends_on: "fetch" }
+  step "report" {
+    use: agent("a")
+    depends_on: [step("lint"), "test"]
+    input: step("fetch").output
+  }
+}
+`,
+			want: map[string][]string{
+				"test":   {"fetch"},
+				"report": {"lint", "test", "fetch"},
+			},
+		},
+		{
+			name: "unknown step",
+			source: `
+pipeline "p" {
+  step "a" { use: agent("x") depends_on: "b" }
+}
+`,
+			wantErr: `step "a" depends on unknown step "b"`,
+		},
+		{
+			name: "cycle",
+			source: `
+pipeline "p" {
+  step "a" { use: agent("x") depends_on: "c" }
+  step "b" { use: agent("x") depends_on: "a" }
+  step "c" { use: agent("x") depends_on: "b" }
+}
+`,
+			wantErr: "dependency cycle: a -> c -> b -> a",
+		},
+		{
+			name: "self dependency",
+			source: `
+pipeline "p" {
+  step "a" { use: agent("x") depends_on: step("a") }
+}
+`,
+			wantErr: `step "a" depends on itself`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entities, _, err := parser.New(tt.source).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+
+			got, err := StepDependencies(entities[0])
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("StepDependencies() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StepDependencies() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StepDependencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
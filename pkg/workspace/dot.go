@@ -0,0 +1,264 @@
+package workspace
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// dotEdge is a directed edge in a DOT graph.
+type dotEdge struct {
+	from, to, label string
+}
+
+// dotGraph accumulates nodes and edges while walking the workspace.
+type dotGraph struct {
+	nodes     map[string]string // node ID -> attribute list
+	nodeOrder []string
+	edges     []dotEdge
+	edgeSeen  map[dotEdge]bool
+	label     string // graph label, already escaped
+}
+
+func newDOTGraph() *dotGraph {
+	return &dotGraph{
+		nodes:    make(map[string]string),
+		edgeSeen: make(map[dotEdge]bool),
+	}
+}
+
+// addNode registers a node unless a node with the same ID already exists.
+func (g *dotGraph) addNode(id, attrs string) {
+	if _, ok := g.nodes[id]; ok {
+		return
+	}
+	g.nodes[id] = attrs
+	g.nodeOrder = append(g.nodeOrder, id)
+}
+
+// defineNode registers a node, replacing the attributes of a placeholder
+// added earlier by a forward reference.
+func (g *dotGraph) defineNode(id, attrs string) {
+	if _, ok := g.nodes[id]; !ok {
+		g.nodeOrder = append(g.nodeOrder, id)
+	}
+	g.nodes[id] = attrs
+}
+
+func (g *dotGraph) addEdge(from, to, label string) {
+	e := dotEdge{from: from, to: to, label: label}
+	if g.edgeSeen[e] {
+		return
+	}
+	g.edgeSeen[e] = true
+	g.edges = append(g.edges, e)
+}
+
+func (g *dotGraph) write(out io.Writer, name string) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %s {\n", dotQuote(name))
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [fontname=\"Helvetica\"];\n")
+	if g.label != "" {
+		fmt.Fprintf(&sb, "  label=\"%s\";\n", g.label)
+		sb.WriteString("  labelloc=t;\n")
+	}
+	for _, id := range g.nodeOrder {
+		fmt.Fprintf(&sb, "  %s [%s];\n", dotQuote(id), g.nodes[id])
+	}
+	for _, e := range g.edges {
+		if e.label != "" {
+			fmt.Fprintf(&sb, "  %s -> %s [label=%s];\n", dotQuote(e.from), dotQuote(e.to), dotQuote(e.label))
+		} else {
+			fmt.Fprintf(&sb, "  %s -> %s;\n", dotQuote(e.from), dotQuote(e.to))
+		}
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(out, sb.String())
+	return err
+}
+
+// dotEscape escapes s for use inside a quoted DOT string.
+func dotEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// dotQuote returns s as a quoted DOT ID.
+func dotQuote(s string) string {
+	return `"` + dotEscape(s) + `"`
+}
+
+// entityNodeAttrs returns the DOT attributes for an entity node.
+func entityNodeAttrs(entityType, name string, defined bool) string {
+	shape := "box"
+	switch entityType {
+	case "pipeline", "intent":
+		shape = "box3d"
+	case "file", "script":
+		shape = "note"
+	case "tool", "mcp":
+		shape = "component"
+	}
+	attrs := fmt.Sprintf(`label="%s\n%s", shape=%s`, dotEscape(entityType), dotEscape(name), shape)
+	if !defined {
+		attrs += ", style=dashed"
+	}
+	return attrs
+}
+
+// WriteDOT writes the workspace entity graph to out in Graphviz DOT format.
+//
+// Every entity becomes a node and every reference (agent("x"), file("y"), ...)
+// becomes an edge labelled with the property it appears in. Steps nested in
+// pipelines are drawn as their own nodes so the flow pipeline → step → agent
+// is visible, and agents link to the model they use. References to entities
+// that are not defined in the workspace are drawn dashed.
+func (w *Workspace) WriteDOT(out io.Writer) error {
+	entities := w.GetEntities()
+
+	g := newDOTGraph()
+	for _, entity := range entities {
+		g.defineNode(entityKey(entity.Type(), entity.Name()), entityNodeAttrs(entity.Type(), entity.Name(), true))
+	}
+	for _, entity := range entities {
+		addEntityToDOT(g, entity, entityKey(entity.Type(), entity.Name()), "")
+	}
+
+	return g.write(out, "workspace")
+}
+
+// GraphDOT returns the workspace entity graph in Graphviz DOT format.
+// See WriteDOT for a description of the graph.
+func (w *Workspace) GraphDOT() string {
+	var sb strings.Builder
+	_ = w.WriteDOT(&sb)
+	return sb.String()
+}
+
+// addEntityToDOT adds the edges for an entity (whose node already exists) and
+// recursively adds its nested entities. scope is the node ID of the enclosing
+// pipeline, used to resolve step("name") references to sibling steps.
+func addEntityToDOT(g *dotGraph, entity ast.Entity, id, scope string) {
+	if entity.Type() == "pipeline" {
+		scope = id
+	}
+
+	for _, ref := range EntityReferences(entity) {
+		target := entityKey(ref.Target.Type, ref.Target.Name)
+		if ref.Target.Type == "step" && scope != "" {
+			target = scope + "/" + target
+		}
+		g.addNode(target, entityNodeAttrs(ref.Target.Type, ref.Target.Name, false))
+		g.addEdge(id, target, ref.Property)
+	}
+
+	if entity.Type() == "agent" {
+		if model, ok := entity.GetProperty("model"); ok {
+			if s, ok := model.(ast.StringValue); ok && s.Value != "" {
+				modelID := "model:" + s.Value
+				g.addNode(modelID, fmt.Sprintf("label=%s, shape=ellipse", dotQuote(s.Value)))
+				g.addEdge(id, modelID, "model")
+			}
+		}
+	}
+
+	for _, child := range nestedEntities(entity) {
+		var childID string
+		switch {
+		case child.Type() == "step" && scope != "":
+			// Steps share one namespace per pipeline, wherever they are nested
+			childID = scope + "/" + entityKey(child.Type(), child.Name())
+		case child.Name() == "":
+			childID = fmt.Sprintf("%s/%s#%d", id, child.Type(), len(g.nodeOrder))
+		default:
+			childID = id + "/" + entityKey(child.Type(), child.Name())
+		}
+		g.defineNode(childID, entityNodeAttrs(child.Type(), child.Name(), true))
+		g.addEdge(id, childID, "")
+		addEntityToDOT(g, child, childID, scope)
+	}
+}
+
+// WritePipelineDOT writes the step flow of a pipeline to out in Graphviz DOT
+// format. Each step is a node labelled with its name, the agent it uses and
+// any model it overrides. When the steps declare depends_on, edges follow
+// their dependencies as given by StepDependencies; otherwise they chain the
+// steps in the order they run. The graph is labelled with the pipeline's
+// name and its plain settings, such as step_delay. A pipeline without steps
+// is an empty graph.
+func WritePipelineDOT(out io.Writer, pipeline ast.Entity) error {
+	p, ok := pipeline.(*ast.PipelineEntity)
+	if !ok {
+		return fmt.Errorf("%s %q is not a pipeline", pipeline.Type(), pipeline.Name())
+	}
+	steps := p.Steps
+
+	g := newDOTGraph()
+	label := []string{dotEscape(fmt.Sprintf("pipeline %q", pipeline.Name()))}
+	for _, key := range sortedPropertyKeys(pipeline) {
+		val, _ := pipeline.GetProperty(key)
+		switch v := val.(type) {
+		case ast.StringValue:
+			label = append(label, dotEscape(fmt.Sprintf("%s: %s", key, v.Value)))
+		case ast.NumberValue:
+			label = append(label, dotEscape(fmt.Sprintf("%s: %g", key, v.Value)))
+		case ast.BoolValue:
+			label = append(label, dotEscape(fmt.Sprintf("%s: %t", key, v.Value)))
+		}
+	}
+	g.label = strings.Join(label, `\n`)
+
+	for _, step := range steps {
+		g.defineNode(step.Name(), stepNodeAttrs(step))
+	}
+
+	if HasStepDependencies(pipeline) {
+		deps, err := StepDependencies(pipeline)
+		if err != nil {
+			return fmt.Errorf("pipeline %q: %w", pipeline.Name(), err)
+		}
+		for _, step := range steps {
+			for _, dep := range deps[step.Name()] {
+				g.addEdge(dep, step.Name(), "")
+			}
+		}
+	} else {
+		for i := 1; i < len(steps); i++ {
+			g.addEdge(steps[i-1].Name(), steps[i].Name(), "")
+		}
+	}
+
+	return g.write(out, pipeline.Name())
+}
+
+// PipelineDOT returns the step flow of a pipeline in Graphviz DOT format.
+// See WritePipelineDOT for a description of the graph.
+func PipelineDOT(pipeline ast.Entity) (string, error) {
+	var sb strings.Builder
+	if err := WritePipelineDOT(&sb, pipeline); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// stepNodeAttrs returns the DOT attributes for a step in a pipeline graph.
+func stepNodeAttrs(step ast.Entity) string {
+	lines := []string{dotEscape(step.Name())}
+	if use, ok := step.GetProperty("use"); ok {
+		switch v := use.(type) {
+		case ast.ReferenceValue:
+			lines = append(lines, dotEscape(fmt.Sprintf("%s(%q)", v.Type, v.Name)))
+		case ast.StringValue:
+			lines = append(lines, dotEscape(fmt.Sprintf("agent(%q)", v.Value)))
+		}
+	}
+	if model, ok := step.GetProperty("model"); ok {
+		if s, ok := model.(ast.StringValue); ok && s.Value != "" {
+			lines = append(lines, dotEscape(s.Value))
+		}
+	}
+	return fmt.Sprintf(`label="%s", shape=box`, strings.Join(lines, `\n`))
+}
@@ -0,0 +1,183 @@
+package workspace
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+	"github.com/shellkjell/langspace/pkg/parser"
+)
+
+func TestWorkspace_GraphDOT(t *testing.T) {
+	input := `
+file "strategy" {
+  contents: "Move the smallest disk first."
+}
+
+agent "planner" {
+  model: "claude-sonnet-4-20250514"
+  instruction: file("strategy")
+}
+
+pipeline "solve" {
+  step "plan" {
+    use: agent("planner")
+    input: $input
+  }
+
+  step "check" {
+    use: agent("checker")
+    input: step("plan").output
+  }
+}
+`
+	entities, _, err := parser.New(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	ws := New()
+	for _, e := range entities {
+		if err := ws.AddEntity(e); err != nil {
+			t.Fatalf("AddEntity() error: %v", err)
+		}
+	}
+
+	dot := ws.GraphDOT()
+
+	wantLines := []string{
+		`digraph "workspace" {`,
+		`"pipeline:solve" -> "pipeline:solve/step:plan";`,
+		`"pipeline:solve" -> "pipeline:solve/step:check";`,
+		`"pipeline:solve/step:plan" -> "agent:planner" [label="use"];`,
+		`"pipeline:solve/step:check" -> "pipeline:solve/step:plan" [label="input"];`,
+		`"agent:planner" -> "file:strategy" [label="instruction"];`,
+		`"agent:planner" -> "model:claude-sonnet-4-20250514" [label="model"];`,
+		`"agent:checker" [label="agent\nchecker", shape=box, style=dashed];`,
+		`"file:strategy" [label="file\nstrategy", shape=note];`,
+	}
+	for _, want := range wantLines {
+		if !strings.Contains(dot, want) {
+			t.Errorf("GraphDOT() missing %q\n%s", want, dot)
+		}
+	}
+
+	if dot != ws.GraphDOT() {
+		t.Error("GraphDOT() output is not deterministic")
+	}
+}
+
+func TestDotQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", `"plain"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`back\slash`, `"back\\slash"`},
+	}
+
+	for _, tt := range tests {
+		if got := dotQuote(tt.in); got != tt.want {
+			t.Errorf("dotQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestPipelineDOT(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{
+			name: "sequential",
+			source: `
+pipeline "hanoi" {
+  step_delay: "500ms"
+  step "plan" {
+    use: agent("planner")
+    input: $input
+  }
+  step "move" {
+    use: agent("solver")
+    model: "gpt-4o-mini"
+    input: step("plan").output
+  }
+  step "check" {
+    use: "checker"
+    input: step("move").output
+  }
+}
+`,
+		},
+		{
+			name: "depends_on",
+			source: `
+pipeline "review" {
+  step "analyze" {
+    use: agent("analyzer")
+  }
+  step "security" {
+    use: agent("auditor")
+    depends_on: "analyze"
+  }
+  step "style" {
+    use: agent("linter")
+    depends_on: step("analyze")
+  }
+  step "summarize" {
+    use: agent("summarizer")
+    input: [step("security").output, step("style").output]
+  }
+}
+`,
+		},
+		{
+			name:   "empty",
+			source: `pipeline "empty" {}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entities, _, err := parser.New(tt.source).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			got, err := PipelineDOT(entities[0])
+			if err != nil {
+				t.Fatalf("PipelineDOT() error: %v", err)
+			}
+
+			golden := filepath.Join("testdata", "pipeline_"+tt.name+".dot")
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("PipelineDOT() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+
+	for _, e := range []ast.Entity{
+		ast.NewAgentEntity("a"),
+		ast.NewParallelEntity(""),
+	} {
+		t.Run("not a pipeline: "+e.Type(), func(t *testing.T) {
+			if _, err := PipelineDOT(e); err == nil {
+				t.Errorf("PipelineDOT() should fail for a %s", e.Type())
+			}
+		})
+	}
+}
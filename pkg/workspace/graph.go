@@ -0,0 +1,147 @@
+package workspace
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// EntityGraph is a snapshot of the references between a workspace's
+// entities, as returned by Workspace.EntityGraph. Unlike a DependencyGraph,
+// whose dependencies are added by hand, it is derived from the entities
+// themselves. An entity depends on every entity it references, from its own
+// properties or from the steps nested inside it, with a reference such as
+// agent("x") or by bare name as in use: "x". References to entities that
+// aren't in the workspace are left out.
+type EntityGraph struct {
+	entities []ast.Entity
+	index    map[string]int   // entity key -> position in entities
+	deps     map[string][]int // entity key -> positions of its dependencies
+}
+
+// EntityGraph builds the graph of references between the workspace's
+// entities: a step's `use: agent("x")` makes its pipeline depend on the
+// agent, an agent's `instruction: file("y")` makes it depend on the file,
+// and so on.
+func (w *Workspace) EntityGraph() *EntityGraph {
+	w.mu.RLock()
+	entities := make([]ast.Entity, len(w.entities))
+	copy(entities, w.entities)
+	w.mu.RUnlock()
+	return newEntityGraph(entities)
+}
+
+// newEntityGraph builds the graph of references between entities.
+func newEntityGraph(entities []ast.Entity) *EntityGraph {
+	g := &EntityGraph{
+		entities: entities,
+		index:    make(map[string]int, len(entities)),
+		deps:     make(map[string][]int, len(entities)),
+	}
+	for i, e := range entities {
+		g.index[entityKey(e.Type(), e.Name())] = i
+	}
+
+	for _, e := range entities {
+		key := entityKey(e.Type(), e.Name())
+		seen := make(map[int]bool)
+		WalkEntity(e, func(nested ast.Entity) {
+			for _, ref := range append(EntityReferences(nested), namedReferences(nested)...) {
+				i, ok := g.index[entityKey(ref.Target.Type, ref.Target.Name)]
+				if !ok || seen[i] {
+					continue
+				}
+				seen[i] = true
+				g.deps[key] = append(g.deps[key], i)
+			}
+		})
+	}
+	return g
+}
+
+// GetDependencies returns the entities the given entity references, in the
+// order it first references them.
+func (g *EntityGraph) GetDependencies(entityType, entityName string) []ast.Entity {
+	var result []ast.Entity
+	for _, i := range g.deps[entityKey(entityType, entityName)] {
+		result = append(result, g.entities[i])
+	}
+	return result
+}
+
+// GetDependents returns the entities that reference the given entity, in
+// workspace order.
+func (g *EntityGraph) GetDependents(entityType, entityName string) []ast.Entity {
+	target, ok := g.index[entityKey(entityType, entityName)]
+	if !ok {
+		return nil
+	}
+	var result []ast.Entity
+	for _, e := range g.entities {
+		for _, i := range g.deps[entityKey(e.Type(), e.Name())] {
+			if i == target {
+				result = append(result, e)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// TopologicalSort returns the entities with every entity after the ones it
+// depends on, otherwise keeping workspace order. It fails if the
+// references form a cycle, naming the entities on it.
+func (g *EntityGraph) TopologicalSort() ([]ast.Entity, error) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(g.entities))
+	var stack []int
+	sorted := make([]ast.Entity, 0, len(g.entities))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			var cycle []string
+			for j := len(stack) - 1; j >= 0; j-- {
+				cycle = append([]string{describe(g.entities[stack[j]])}, cycle...)
+				if stack[j] == i {
+					break
+				}
+			}
+			cycle = append(cycle, describe(g.entities[i]))
+			return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[i] = visiting
+		stack = append(stack, i)
+		e := g.entities[i]
+		for _, dep := range g.deps[entityKey(e.Type(), e.Name())] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+		sorted = append(sorted, e)
+		return nil
+	}
+
+	for i := range g.entities {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// describe names an entity as it is written in source, e.g. agent "x".
+func describe(e ast.Entity) string {
+	return fmt.Sprintf("%s %q", e.Type(), e.Name())
+}
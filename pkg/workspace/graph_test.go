@@ -0,0 +1,117 @@
+package workspace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+	"github.com/shellkjell/langspace/pkg/parser"
+)
+
+func names(entities []ast.Entity) string {
+	parts := make([]string, len(entities))
+	for i, e := range entities {
+		parts[i] = e.Type() + ":" + e.Name()
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestWorkspace_EntityGraph(t *testing.T) {
+	// Entities are declared dependents first so sorting has work to do
+	const source = `
+intent "solve" {
+  use: pipeline("hanoi")
+}
+
+pipeline "hanoi" {
+  step "move" {
+    use: agent("solver")
+  }
+  step "check" {
+    use: agent("checker")
+    input: step("move").output
+  }
+}
+
+agent "solver" {
+  model: "gpt-4o"
+  instruction: file("hanoi-strategy")
+}
+
+agent "checker" {
+  model: "gpt-4o"
+  instruction: file("hanoi-strategy")
+  tools: [tool("undefined")]
+}
+
+file "hanoi-strategy" {
+  contents: "Move the smallest disk every other turn."
+}
+`
+	entities, _, err := parser.New(source).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	ws := New()
+	for _, e := range entities {
+		if err := ws.AddEntity(e); err != nil {
+			t.Fatalf("AddEntity() error = %v", err)
+		}
+	}
+	g := ws.EntityGraph()
+
+	deps := []struct {
+		entityType, name string
+		want             string
+	}{
+		{"intent", "solve", "pipeline:hanoi"},
+		{"pipeline", "hanoi", "agent:solver agent:checker"},
+		{"agent", "solver", "file:hanoi-strategy"},
+		{"agent", "checker", "file:hanoi-strategy"},
+		{"file", "hanoi-strategy", ""},
+	}
+	for _, tt := range deps {
+		if got := names(g.GetDependencies(tt.entityType, tt.name)); got != tt.want {
+			t.Errorf("GetDependencies(%s %q) = %q, want %q", tt.entityType, tt.name, got, tt.want)
+		}
+	}
+
+	if got, want := names(g.GetDependents("file", "hanoi-strategy")), "agent:solver agent:checker"; got != want {
+		t.Errorf("GetDependents(file) = %q, want %q", got, want)
+	}
+	if got := g.GetDependents("agent", "missing"); got != nil {
+		t.Errorf("GetDependents(missing) = %v, want nil", got)
+	}
+
+	sorted, err := g.TopologicalSort()
+	if err != nil {
+		t.Fatalf("TopologicalSort() error = %v", err)
+	}
+	if got, want := names(sorted), "file:hanoi-strategy agent:solver agent:checker pipeline:hanoi intent:solve"; got != want {
+		t.Errorf("TopologicalSort() = %q, want %q", got, want)
+	}
+}
+
+func TestEntityGraph_TopologicalSortCycle(t *testing.T) {
+	entities, _, err := parser.New(`
+agent "a" {
+  handoff: agent("b")
+}
+
+agent "b" {
+  handoff: agent("a")
+}
+`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	ws := New()
+	for _, e := range entities {
+		_ = ws.AddEntity(e)
+	}
+
+	_, err = ws.EntityGraph().TopologicalSort()
+	if err == nil || !strings.Contains(err.Error(), `agent "a" -> agent "b" -> agent "a"`) {
+		t.Errorf("TopologicalSort() error = %v, want the cycle named", err)
+	}
+}
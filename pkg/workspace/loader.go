@@ -1,10 +1,15 @@
 package workspace
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/shellkjell/langspace/pkg/ast"
+	"github.com/shellkjell/langspace/pkg/formatter"
 	"github.com/shellkjell/langspace/pkg/parser"
 )
 
@@ -12,7 +17,8 @@ import (
 type Loader struct {
 	workspace *Workspace
 	loaded    map[string]bool
-	baseDir   string
+	chain     []string          // files currently being loaded, outermost first
+	origins   map[string]string // entity key -> file that defined it
 }
 
 // NewLoader creates a new Loader instance for the given workspace.
@@ -20,53 +26,386 @@ func NewLoader(ws *Workspace) *Loader {
 	return &Loader{
 		workspace: ws,
 		loaded:    make(map[string]bool),
+		origins:   make(map[string]string),
 	}
 }
 
 // Load loads a LangSpace file and all its imported dependencies.
+//
+// Imports are resolved relative to the importing file and loaded before the
+// file's own entities are added, so entities may reference entities defined
+// in the files they import. A file imported more than once is only loaded
+// once; an import cycle or a missing import is reported together with the
+// chain of imports that led to it.
+//
+// Loading runs in two phases: the file and everything it imports are read
+// and parsed first, and their entities are only added once all of them
+// parsed. A file that can't be read or parsed, or an entity that can't be
+// added, such as a duplicate, leaves the workspace as it was, and the load
+// can be retried once the problem is fixed.
 func (l *Loader) Load(filePath string) error {
+	var files []parsedFile
+	if err := l.parse(filePath, &files, make(map[string]bool)); err != nil {
+		return err
+	}
+	return l.add(files)
+}
+
+// parsedFile is a file read and parsed by the first phase of loading,
+// waiting for its entities to be added.
+type parsedFile struct {
+	path     string
+	entities []ast.Entity
+}
+
+// parse reads and parses filePath and the files it imports, appending to
+// files those not loaded or pending already, each after its imports.
+// pending holds the files parsed in the current batch.
+func (l *Loader) parse(filePath string, files *[]parsedFile, pending map[string]bool) error {
 	absPath, err := filepath.Abs(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path for %s: %w", filePath, err)
 	}
 
-	if l.loaded[absPath] {
-		return nil
+	for _, p := range l.chain {
+		if p == absPath {
+			return fmt.Errorf("import cycle detected: %s", l.formatChain(absPath))
+		}
 	}
 
-	l.loaded[absPath] = true
+	if l.loaded[absPath] || pending[absPath] {
+		return nil
+	}
 
 	content, err := os.ReadFile(absPath)
 	if err != nil {
+		if len(l.chain) > 0 {
+			return fmt.Errorf("failed to read file %s (import chain: %s): %w", absPath, l.formatChain(absPath), err)
+		}
 		return fmt.Errorf("failed to read file %s: %w", absPath, err)
 	}
 
-	l.baseDir = filepath.Dir(absPath)
-
 	p := parser.New(string(content))
 	entities, imports, err := p.Parse()
 	if err != nil {
-		return fmt.Errorf("parse error in %s: %w", absPath, err)
+		return fmt.Errorf("%s: %w", absPath, err)
+	}
+
+	l.chain = append(l.chain, absPath)
+	defer func() { l.chain = l.chain[:len(l.chain)-1] }()
+
+	// Parse imports first so this file's entities are added after theirs
+	baseDir := filepath.Dir(absPath)
+	for _, imp := range imports {
+		impPath := imp.Path
+		if !filepath.IsAbs(impPath) {
+			impPath = filepath.Join(baseDir, impPath)
+		}
+
+		if err := l.parse(impPath, files, pending); err != nil {
+			return err
+		}
 	}
 
-	// Add entities to workspace
+	pending[absPath] = true
+	*files = append(*files, parsedFile{path: absPath, entities: entities})
+	return nil
+}
+
+// add adds the entities of parsed files to the workspace, in order, and
+// marks the files loaded. If an entity can't be added, those added before
+// it are removed again and no file is marked loaded.
+func (l *Loader) add(files []parsedFile) error {
+	var added []ast.Entity
+	for _, file := range files {
+		for _, entity := range file.entities {
+			if err := l.workspace.AddEntity(entity); err != nil {
+				err = l.addError(file.path, entity, err)
+				l.removeAdded(added)
+				return err
+			}
+			l.origins[entityKey(entity.Type(), entity.Name())] = file.path
+			added = append(added, entity)
+		}
+	}
+
+	for _, file := range files {
+		l.loaded[file.path] = true
+	}
+	return nil
+}
+
+// addError describes why entity, defined in path, couldn't be added,
+// naming where a conflicting definition came from.
+func (l *Loader) addError(path string, entity ast.Entity, err error) error {
+	var dup *DuplicateEntityError
+	if errors.As(err, &dup) {
+		if origin, ok := l.origins[entityKey(entity.Type(), entity.Name())]; ok {
+			return fmt.Errorf("%s %q in %s conflicts with the definition in %s: %w",
+				entity.Type(), entity.Name(), location(path, entity), location(origin, dup.Existing), err)
+		}
+	}
+	return fmt.Errorf("failed to add entity %q from %s: %w", entity.Name(), path, err)
+}
+
+// removeAdded takes entities added by a failed load back out of the
+// workspace, newest first.
+func (l *Loader) removeAdded(entities []ast.Entity) {
+	for i := len(entities) - 1; i >= 0; i-- {
+		e := entities[i]
+		if err := l.workspace.removeInstance(e); err == nil {
+			delete(l.origins, entityKey(e.Type(), e.Name()))
+		}
+	}
+}
+
+// LoadDir loads every file under dir, in subdirectories too, whose name
+// matches the glob pattern ("*.ls" if empty), in lexical order. Hidden
+// directories such as .git are skipped. Files already loaded, directly or
+// as another file's import, are not loaded again.
+//
+// Every file is parsed before any entity is added, so a file that can't be
+// read or parsed, or an entity that can't be added, leaves the workspace as
+// it was. Since files may reference entities defined in files after them,
+// references are only checked once every file is in the workspace, with
+// ValidateWorkspace; its error is returned, but the loaded entities stay.
+func (l *Loader) LoadDir(dir, pattern string) error {
+	if pattern == "" {
+		pattern = "*.ls"
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ok, _ := filepath.Match(pattern, d.Name()); ok {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	var parsed []parsedFile
+	pending := make(map[string]bool)
+	for _, file := range files {
+		if err := l.parse(file, &parsed, pending); err != nil {
+			return err
+		}
+	}
+	if err := l.add(parsed); err != nil {
+		return err
+	}
+	return ValidateWorkspace(l.workspace)
+}
+
+// Reload re-reads a file loaded earlier and brings the workspace in line
+// with it: entities that are new are added, ones whose definition changed
+// are replaced, and ones no longer in the file are removed. Unchanged
+// entities are left alone. Imports the file gained are loaded; entities of
+// imports it dropped stay in the workspace.
+//
+// Unless force is set, nothing is changed if an entity to be removed is
+// still referenced by another entity; a ReferencedEntityError names them.
+// An entity in the file that matches one added to the workspace other than
+// by a loader is a conflict, and is not replaced. If any change fails, the
+// ones made before it are undone, and the reload can be retried. A file
+// that was never loaded is loaded as by Load.
+func (l *Loader) Reload(filePath string, force bool) error {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for %s: %w", filePath, err)
+	}
+	if !l.loaded[absPath] {
+		return l.Load(absPath)
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", absPath, err)
+	}
+	entities, imports, err := parser.New(string(content)).Parse()
+	if err != nil {
+		return fmt.Errorf("%s: %w", absPath, err)
+	}
+
+	// Work out what changes before touching the workspace
+	keep := make(map[string]bool)
+	var added, changed []ast.Entity
 	for _, entity := range entities {
+		key := entityKey(entity.Type(), entity.Name())
+		if keep[key] {
+			return fmt.Errorf("%s: duplicate %s %q at line %d", absPath, entity.Type(), entity.Name(), entity.Line())
+		}
+		keep[key] = true
+
+		existing, found := l.workspace.GetEntityByName(entity.Type(), entity.Name())
+		switch origin, fromFile := l.origins[key]; {
+		case !found:
+			added = append(added, entity)
+		case !fromFile:
+			return fmt.Errorf("%s %q in %s conflicts with an entity that was not loaded from a file",
+				entity.Type(), entity.Name(), location(absPath, entity))
+		case origin != absPath:
+			return fmt.Errorf("%s %q in %s conflicts with the definition in %s",
+				entity.Type(), entity.Name(), location(absPath, entity), location(origin, existing))
+		case !sameDefinition(existing, entity):
+			changed = append(changed, entity)
+		}
+	}
+
+	var removed []ast.Entity
+	for _, e := range l.workspace.GetEntities() {
+		key := entityKey(e.Type(), e.Name())
+		if l.origins[key] == absPath && !keep[key] {
+			removed = append(removed, e)
+		}
+	}
+
+	if !force {
+		// Check references as they will be once the file is reloaded
+		var after []ast.Entity
+		for _, e := range l.workspace.GetEntities() {
+			key := entityKey(e.Type(), e.Name())
+			if l.origins[key] != absPath {
+				after = append(after, e)
+			}
+		}
+		after = append(after, entities...)
+		// The removed entities are in the graph only to be looked up;
+		// references between them don't count
+		gone := make(map[ast.Entity]bool, len(removed))
+		for _, e := range removed {
+			gone[e] = true
+		}
+		g := newEntityGraph(append(after, removed...))
+		for _, e := range removed {
+			var refs []ast.Entity
+			for _, ref := range g.GetDependents(e.Type(), e.Name()) {
+				if !gone[ref] {
+					refs = append(refs, ref)
+				}
+			}
+			if len(refs) > 0 {
+				return &ReferencedEntityError{Entity: e, Referrers: refs}
+			}
+		}
+	}
+
+	// Parse the imports the file gained before changing anything
+	var files []parsedFile
+	if err := l.parseImports(absPath, imports, &files); err != nil {
+		return err
+	}
+	if err := l.add(files); err != nil {
+		return err
+	}
+
+	// Apply the changes, recording how to undo each
+	undo := []func(){func() {
+		var imported []ast.Entity
+		for _, file := range files {
+			imported = append(imported, file.entities...)
+			delete(l.loaded, file.path)
+		}
+		l.removeAdded(imported)
+	}}
+	rollback := func(err error) error {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+		return err
+	}
+
+	for _, entity := range added {
 		if err := l.workspace.AddEntity(entity); err != nil {
-			return fmt.Errorf("failed to add entity %q from %s: %w", entity.Name(), absPath, err)
+			return rollback(fmt.Errorf("failed to add entity %q from %s: %w", entity.Name(), absPath, err))
 		}
+		l.origins[entityKey(entity.Type(), entity.Name())] = absPath
+		undo = append(undo, func() { l.removeAdded([]ast.Entity{entity}) })
 	}
+	for _, entity := range changed {
+		previous, _ := l.workspace.GetEntityByName(entity.Type(), entity.Name())
+		if err := l.workspace.UpdateEntity(entity); err != nil {
+			return rollback(fmt.Errorf("failed to update entity %q from %s: %w", entity.Name(), absPath, err))
+		}
+		undo = append(undo, func() { _ = l.workspace.UpdateEntity(previous) })
+	}
+	for _, entity := range removed {
+		key := entityKey(entity.Type(), entity.Name())
+		if err := l.workspace.ForceRemoveEntity(entity.Type(), entity.Name()); err != nil {
+			return rollback(fmt.Errorf("failed to remove entity %q of %s: %w", entity.Name(), absPath, err))
+		}
+		delete(l.origins, key)
+		undo = append(undo, func() {
+			if l.workspace.AddEntity(entity) == nil {
+				l.origins[key] = absPath
+			}
+		})
+	}
+
+	return nil
+}
 
-	// Recursively load imports
+// parseImports parses the files imported by filePath that aren't loaded
+// yet, as parse does for the file's own imports.
+func (l *Loader) parseImports(filePath string, imports []ast.Import, files *[]parsedFile) error {
+	l.chain = append(l.chain, filePath)
+	defer func() { l.chain = l.chain[:len(l.chain)-1] }()
+
+	pending := make(map[string]bool)
+	baseDir := filepath.Dir(filePath)
 	for _, imp := range imports {
 		impPath := imp.Path
 		if !filepath.IsAbs(impPath) {
-			impPath = filepath.Join(l.baseDir, impPath)
+			impPath = filepath.Join(baseDir, impPath)
 		}
-
-		if err := l.Load(impPath); err != nil {
+		if err := l.parse(impPath, files, pending); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
+
+// sameDefinition reports whether two entities have the same source form,
+// ignoring where in the file they appear.
+func sameDefinition(a, b ast.Entity) bool {
+	textA, errA := formatter.Format([]ast.Entity{a})
+	textB, errB := formatter.Format([]ast.Entity{b})
+	return errA == nil && errB == nil && textA == textB
+}
+
+// location formats where entity was defined, as path:line when the line is known.
+func location(path string, entity ast.Entity) string {
+	if entity.Line() > 0 {
+		return fmt.Sprintf("%s:%d", path, entity.Line())
+	}
+	return path
+}
+
+// formatChain renders the current import chain followed by next, with paths
+// shown relative to the directory of the outermost file.
+func (l *Loader) formatChain(next string) string {
+	paths := append(append([]string{}, l.chain...), next)
+	root := filepath.Dir(paths[0])
+
+	parts := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if rel, err := filepath.Rel(root, p); err == nil {
+			p = rel
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, " -> ")
+}
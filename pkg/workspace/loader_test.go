@@ -0,0 +1,545 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// writeFiles writes name -> contents into dir, creating subdirectories as needed.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoader_Load(t *testing.T) {
+	t.Run("imports are loaded before entities", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"main.ls": `
+import "agents/reviewer.ls"
+
+intent "review" {
+  use: agent("reviewer")
+}
+`,
+			"agents/reviewer.ls": `
+import "../shared/prompt.ls"
+
+agent "reviewer" {
+  model: "gpt-4o"
+  instruction: file("prompt")
+}
+`,
+			"shared/prompt.ls": `
+file "prompt" {
+  contents: "Be thorough."
+}
+`,
+		})
+
+		ws := New()
+		var order []string
+		ws.OnEntityEvent(HookAfterAdd, func(e ast.Entity) error {
+			order = append(order, e.Type()+":"+e.Name())
+			return nil
+		})
+
+		if err := NewLoader(ws).Load(filepath.Join(dir, "main.ls")); err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+
+		want := []string{"file:prompt", "agent:reviewer", "intent:review"}
+		if strings.Join(order, ",") != strings.Join(want, ",") {
+			t.Errorf("entities added in order %v, want %v", order, want)
+		}
+	})
+
+	t.Run("sibling imports resolve relative to the importing file", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"main.ls": `
+import "lib/a.ls"
+import "b.ls"
+`,
+			"lib/a.ls": `file "a" { contents: "a" }`,
+			"b.ls":     `file "b" { contents: "b" }`,
+		})
+
+		ws := New()
+		if err := NewLoader(ws).Load(filepath.Join(dir, "main.ls")); err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		if len(ws.GetEntities()) != 2 {
+			t.Errorf("expected 2 entities, got %d", len(ws.GetEntities()))
+		}
+	})
+
+	t.Run("shared import is loaded once", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"main.ls":   "import \"left.ls\"\nimport \"right.ls\"\n",
+			"left.ls":   "import \"shared.ls\"\n",
+			"right.ls":  "import \"shared.ls\"\n",
+			"shared.ls": `file "shared" { contents: "x" }`,
+		})
+
+		ws := New()
+		if err := NewLoader(ws).Load(filepath.Join(dir, "main.ls")); err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		if len(ws.GetEntities()) != 1 {
+			t.Errorf("expected 1 entity, got %d", len(ws.GetEntities()))
+		}
+	})
+
+	t.Run("import cycle", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"a.ls": "import \"b.ls\"\n",
+			"b.ls": "import \"c.ls\"\n",
+			"c.ls": "import \"a.ls\"\n",
+		})
+
+		err := NewLoader(New()).Load(filepath.Join(dir, "a.ls"))
+		if err == nil {
+			t.Fatal("expected import cycle error")
+		}
+		if !strings.Contains(err.Error(), "import cycle detected: a.ls -> b.ls -> c.ls -> a.ls") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("missing import", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"main.ls": "import \"lib.ls\"\n",
+			"lib.ls":  "import \"missing.ls\"\n",
+		})
+
+		err := NewLoader(New()).Load(filepath.Join(dir, "main.ls"))
+		if err == nil {
+			t.Fatal("expected missing import error")
+		}
+		if !strings.Contains(err.Error(), "import chain: main.ls -> lib.ls -> missing.ls") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("forward references", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"main.ls": `
+import "pipelines.ls"
+
+intent "review" {
+  use: pipeline("checks")
+}
+
+agent "reviewer" {
+  model: "gpt-4o"
+}
+`,
+			// imported, so added first, but uses an agent main.ls defines later
+			"pipelines.ls": `
+pipeline "checks" {
+  step "review" {
+    use: agent("reviewer")
+  }
+}
+`,
+		})
+
+		ws := New()
+		if err := NewLoader(ws).Load(filepath.Join(dir, "main.ls")); err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		if err := ValidateWorkspace(ws); err != nil {
+			t.Errorf("ValidateWorkspace() error: %v", err)
+		}
+	})
+
+	t.Run("parse error in an import leaves the workspace untouched", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"main.ls": "import \"good.ls\"\nimport \"bad.ls\"\n\nfile \"main\" { contents: \"x\" }\n",
+			"good.ls": `file "good" { contents: "x" }`,
+			"bad.ls":  `file "bad" { contents: `,
+		})
+
+		ws := New()
+		loader := NewLoader(ws)
+		err := loader.Load(filepath.Join(dir, "main.ls"))
+		if err == nil || !strings.Contains(err.Error(), "bad.ls") {
+			t.Fatalf("Load() error = %v, want a parse error in bad.ls", err)
+		}
+		if n := len(ws.GetEntities()); n != 0 {
+			t.Errorf("workspace has %d entities after a failed load, want 0", n)
+		}
+
+		// Nothing was marked loaded, so a fixed file loads in full
+		writeFiles(t, dir, map[string]string{"bad.ls": `file "bad" { contents: "x" }`})
+		if err := loader.Load(filepath.Join(dir, "main.ls")); err != nil {
+			t.Fatalf("Load() after fix error: %v", err)
+		}
+		if n := len(ws.GetEntities()); n != 3 {
+			t.Errorf("got %d entities, want 3", n)
+		}
+	})
+
+	t.Run("failed add leaves the workspace untouched", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"main.ls": "import \"lib.ls\"\n\nfile \"notes\" { contents: \"x\" }\nagent \"helper\" { model: \"gpt-4o\" }\n",
+			"lib.ls":  "file \"shared\" { contents: \"x\" }\nagent \"helper\" { model: \"gpt-4o-mini\" }\n",
+		})
+
+		ws := New()
+		loader := NewLoader(ws)
+		var dup *DuplicateEntityError
+		if err := loader.Load(filepath.Join(dir, "main.ls")); !errors.As(err, &dup) {
+			t.Fatalf("Load() error = %v, want a *DuplicateEntityError", err)
+		}
+		if n := len(ws.GetEntities()); n != 0 {
+			t.Errorf("workspace has %d entities after a failed load, want 0", n)
+		}
+
+		// Retrying once the conflict is gone loads both files in full
+		writeFiles(t, dir, map[string]string{
+			"main.ls": "import \"lib.ls\"\n\nfile \"notes\" { contents: \"x\" }\nagent \"writer\" { model: \"gpt-4o\" }\n",
+		})
+		if err := loader.Load(filepath.Join(dir, "main.ls")); err != nil {
+			t.Fatalf("Load() after fix error: %v", err)
+		}
+		var got []string
+		for _, e := range ws.GetEntities() {
+			got = append(got, entityKey(e.Type(), e.Name()))
+		}
+		want := []string{"file:shared", "agent:helper", "file:notes", "agent:writer"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("loaded %v, want %v", got, want)
+		}
+	})
+
+	t.Run("same agent in two files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"main.ls": "import \"lib.ls\"\n\nagent \"helper\" {\n  model: \"gpt-4o\"\n}\n",
+			"lib.ls":  "agent \"helper\" {\n  model: \"gpt-4o-mini\"\n}\n",
+		})
+
+		err := NewLoader(New()).Load(filepath.Join(dir, "main.ls"))
+		if err == nil {
+			t.Fatal("expected duplicate entity error")
+		}
+		var dup *DuplicateEntityError
+		if !errors.As(err, &dup) {
+			t.Fatalf("error %v is not a *DuplicateEntityError", err)
+		}
+		for _, want := range []string{
+			filepath.Join(dir, "main.ls") + ":3",
+			filepath.Join(dir, "lib.ls") + ":1",
+			`agent "helper"`,
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not contain %q", err, want)
+			}
+		}
+	})
+}
+
+func TestLoader_Reload(t *testing.T) {
+	const agents = `
+agent "writer" {
+  model: "gpt-4o"
+}
+
+agent "reviewer" {
+  model: "gpt-4o"
+}
+`
+	load := func(t *testing.T) (*Workspace, *Loader, string) {
+		t.Helper()
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"agents.ls": agents,
+			"main.ls": `
+import "agents.ls"
+
+intent "review" {
+  use: agent("reviewer")
+}
+`,
+		})
+		ws := New()
+		l := NewLoader(ws)
+		if err := l.Load(filepath.Join(dir, "main.ls")); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		return ws, l, filepath.Join(dir, "agents.ls")
+	}
+
+	t.Run("add, change and remove", func(t *testing.T) {
+		ws, l, path := load(t)
+		var events []string
+		ws.OnEvent(func(e Event) {
+			events = append(events, string(e.Type)+":"+e.Entity.Name())
+		})
+
+		writeFiles(t, filepath.Dir(path), map[string]string{"agents.ls": `
+agent "reviewer" {
+  model: "claude-sonnet-4-20250514"
+}
+
+agent "editor" {
+  model: "gpt-4o"
+}
+`})
+		if err := l.Reload(path, false); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+
+		if _, ok := ws.GetEntityByName("agent", "writer"); ok {
+			t.Error("removed agent is still in the workspace")
+		}
+		if _, ok := ws.GetEntityByName("agent", "editor"); !ok {
+			t.Error("added agent is missing")
+		}
+		reviewer, _ := ws.GetEntityByName("agent", "reviewer")
+		if model, _ := reviewer.GetProperty("model"); model.(ast.StringValue).Value != "claude-sonnet-4-20250514" {
+			t.Errorf("reviewer model = %v, want the reloaded one", model)
+		}
+		want := []string{"entity_added:editor", "entity_updated:reviewer", "entity_removed:writer"}
+		if strings.Join(events, " ") != strings.Join(want, " ") {
+			t.Errorf("events = %v, want %v", events, want)
+		}
+
+		// Reloading an unchanged file changes nothing
+		events = nil
+		if err := l.Reload(path, false); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+		if len(events) != 0 {
+			t.Errorf("events = %v, want none", events)
+		}
+	})
+
+	t.Run("referenced entity kept", func(t *testing.T) {
+		ws, l, path := load(t)
+		writeFiles(t, filepath.Dir(path), map[string]string{"agents.ls": `
+agent "writer" {
+  model: "o3"
+}
+`})
+		err := l.Reload(path, false)
+		var refErr *ReferencedEntityError
+		if !errors.As(err, &refErr) || refErr.Entity.Name() != "reviewer" || !strings.Contains(err.Error(), `intent "review"`) {
+			t.Fatalf("Reload() error = %v, want reviewer still referenced by the intent", err)
+		}
+		writer, _ := ws.GetEntityByName("agent", "writer")
+		if model, _ := writer.GetProperty("model"); model.(ast.StringValue).Value != "gpt-4o" {
+			t.Error("workspace changed despite the failed reload")
+		}
+
+		if err := l.Reload(path, true); err != nil {
+			t.Fatalf("forced Reload() error = %v", err)
+		}
+		if _, ok := ws.GetEntityByName("agent", "reviewer"); ok {
+			t.Error("forced reload kept the removed agent")
+		}
+	})
+
+	t.Run("failed change rolls back", func(t *testing.T) {
+		ws, l, path := load(t)
+		reject := true
+		ws.OnEntityEvent(HookBeforeUpdate, func(e ast.Entity) error {
+			if reject {
+				return errors.New("updates are frozen")
+			}
+			return nil
+		})
+
+		writeFiles(t, filepath.Dir(path), map[string]string{
+			"helpers.ls": `
+agent "helper" {
+  model: "gpt-4o"
+}
+`,
+			"agents.ls": `
+import "helpers.ls"
+
+agent "reviewer" {
+  model: "claude-sonnet-4-20250514"
+}
+
+agent "editor" {
+  model: "gpt-4o"
+}
+`,
+		})
+		if err := l.Reload(path, true); err == nil || !strings.Contains(err.Error(), "updates are frozen") {
+			t.Fatalf("Reload() error = %v, want the rejected update", err)
+		}
+		for _, name := range []string{"helper", "editor"} {
+			if _, ok := ws.GetEntityByName("agent", name); ok {
+				t.Errorf("agent %q added by the failed reload is still in the workspace", name)
+			}
+		}
+		if _, ok := ws.GetEntityByName("agent", "writer"); !ok {
+			t.Error("agent removed by the failed reload is missing")
+		}
+		reviewer, _ := ws.GetEntityByName("agent", "reviewer")
+		if model, _ := reviewer.GetProperty("model"); model.(ast.StringValue).Value != "gpt-4o" {
+			t.Errorf("reviewer model = %v, want the original", model)
+		}
+
+		reject = false
+		if err := l.Reload(path, true); err != nil {
+			t.Fatalf("retried Reload() error = %v", err)
+		}
+		for _, name := range []string{"helper", "editor"} {
+			if _, ok := ws.GetEntityByName("agent", name); !ok {
+				t.Errorf("agent %q is missing after the retry", name)
+			}
+		}
+	})
+
+	t.Run("entity not loaded from a file", func(t *testing.T) {
+		ws, l, path := load(t)
+		editor, _ := ast.NewEntity("agent", "editor")
+		editor.SetProperty("model", ast.StringValue{Value: "o3"})
+		if err := ws.AddEntity(editor); err != nil {
+			t.Fatalf("AddEntity() error = %v", err)
+		}
+
+		writeFiles(t, filepath.Dir(path), map[string]string{"agents.ls": agents + `
+agent "editor" {
+  model: "gpt-4o"
+}
+`})
+		if err := l.Reload(path, false); err == nil || !strings.Contains(err.Error(), "not loaded from a file") {
+			t.Fatalf("Reload() error = %v, want a conflict with the added agent", err)
+		}
+		if got, _ := ws.GetEntityByName("agent", "editor"); got != editor {
+			t.Error("reload replaced an entity it did not load")
+		}
+	})
+
+	t.Run("file not loaded before", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"agents.ls": agents})
+		ws := New()
+		if err := NewLoader(ws).Reload(filepath.Join(dir, "agents.ls"), false); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+		if len(ws.AllAgents()) != 2 {
+			t.Errorf("got %d agents, want 2", len(ws.AllAgents()))
+		}
+	})
+}
+
+func TestLoader_LoadDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		pattern string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "references across files in any order",
+			files: map[string]string{
+				"a_intents.ls": `intent "review" { use: agent("reviewer") }`,
+				"m_pipes.ls":   `pipeline "p" { step "s" { use: agent("reviewer") } }`,
+				"z_agents.ls":  `agent "reviewer" { model: "gpt-4o" }`,
+			},
+			want: []string{"agent:reviewer", "intent:review", "pipeline:p"},
+		},
+		{
+			name: "subdirectories and imports loaded once",
+			files: map[string]string{
+				"main.ls":       "import \"lib/shared.ls\"\n\nagent \"a\" { model: \"gpt-4o\" }\n",
+				"lib/shared.ls": `file "shared" { contents: "x" }`,
+				"lib/more.ls":   `file "more" { contents: "y" }`,
+			},
+			want: []string{"agent:a", "file:more", "file:shared"},
+		},
+		{
+			name: "other files and hidden directories skipped",
+			files: map[string]string{
+				"main.ls":        `file "main" { contents: "x" }`,
+				"notes.txt":      `file "notes" { contents: "x" }`,
+				".cache/old.ls":  `file "old" { contents: "x" }`,
+				"nested/.tmp.ls": `file "hidden-file" { contents: "x" }`,
+			},
+			want: []string{"file:hidden-file", "file:main"},
+		},
+		{
+			name:    "custom pattern",
+			files:   map[string]string{"a.lang": `file "a" { contents: "x" }`, "b.ls": `file "b" { contents: "x" }`},
+			pattern: "*.lang",
+			want:    []string{"file:a"},
+		},
+		{
+			name: "undefined reference reported after loading",
+			files: map[string]string{
+				"intents.ls": `intent "review" { use: agent("ghost") }`,
+			},
+			want:    []string{"intent:review"},
+			wantErr: `intent "review": use references undefined agent "ghost"`,
+		},
+		{
+			name: "parse error in any file loads nothing",
+			files: map[string]string{
+				"a.ls": `file "a" { contents: "x" }`,
+				"b.ls": `agent "b" {`,
+				"c.ls": `file "c" { contents: "x" }`,
+			},
+			wantErr: "b.ls",
+		},
+		{
+			name:    "invalid pattern",
+			files:   map[string]string{"a.ls": `file "a" { contents: "x" }`},
+			pattern: "[",
+			wantErr: "invalid pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			ws := New()
+			err := NewLoader(ws).LoadDir(dir, tt.pattern)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("LoadDir() error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("LoadDir() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, e := range ws.GetEntities() {
+				got = append(got, entityKey(e.Type(), e.Name()))
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("loaded %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package workspace
+
+import (
+	"sort"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// Reference describes a reference from one entity property to another entity,
+// e.g. the `use: agent("reviewer")` property of a step.
+type Reference struct {
+	Property string             // The top-level property the reference appears in
+	Target   ast.ReferenceValue // The referenced entity
+}
+
+// childSteps returns the steps declared directly inside a pipeline or parallel block.
+func childSteps(entity ast.Entity) []*ast.StepEntity {
+	switch e := entity.(type) {
+	case *ast.PipelineEntity:
+		return e.Steps
+	case *ast.ParallelEntity:
+		return e.Steps
+	default:
+		return nil
+	}
+}
+
+// sortedPropertyKeys returns the entity's property keys in lexical order so that
+// walks over properties are deterministic.
+func sortedPropertyKeys(entity ast.Entity) []string {
+	props := entity.Properties()
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// walkValue calls fn for every value reachable from v, including v itself.
+// Nested entities (steps inside branches, loops or parallel blocks) are
+// reported to fn but not descended into; callers decide how to treat them.
+func walkValue(v ast.Value, fn func(ast.Value)) {
+	if v == nil {
+		return
+	}
+	fn(v)
+
+	switch val := v.(type) {
+	case ast.ArrayValue:
+		for _, elem := range val.Elements {
+			walkValue(elem, fn)
+		}
+	case ast.ObjectValue:
+		keys := make([]string, 0, len(val.Properties))
+		for k := range val.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkValue(val.Properties[k], fn)
+		}
+	case ast.MethodCallValue:
+		walkValue(val.Object, fn)
+		for _, arg := range val.Arguments {
+			walkValue(arg, fn)
+		}
+	case ast.FunctionCallValue:
+		for _, arg := range val.Arguments {
+			walkValue(arg, fn)
+		}
+	case ast.ComparisonValue:
+		walkValue(val.Left, fn)
+		walkValue(val.Right, fn)
+	case ast.TypedParameterValue:
+		walkValue(val.Default, fn)
+	case ast.BranchValue:
+		walkValue(val.Condition, fn)
+		cases := make([]string, 0, len(val.Cases))
+		for k := range val.Cases {
+			cases = append(cases, k)
+		}
+		sort.Strings(cases)
+		for _, k := range cases {
+			walkValue(val.Cases[k], fn)
+		}
+	case ast.LoopValue:
+		for _, body := range val.Body {
+			walkValue(body, fn)
+		}
+		walkValue(val.BreakCondition, fn)
+	}
+}
+
+// EntityReferences returns the references made directly by an entity's
+// properties, in property order. References made by nested entities (steps,
+// parallel blocks, branch cases) are not included.
+func EntityReferences(entity ast.Entity) []Reference {
+	var refs []Reference
+	for _, key := range sortedPropertyKeys(entity) {
+		val, _ := entity.GetProperty(key)
+		walkValue(val, func(v ast.Value) {
+			if ref, ok := v.(ast.ReferenceValue); ok {
+				refs = append(refs, Reference{Property: key, Target: ref})
+			}
+		})
+	}
+	return refs
+}
+
+// nestedEntities returns the entities nested inside an entity: declared steps
+// followed by any nested blocks found in its properties (parallel blocks,
+// branch cases, loop bodies and lifecycle handlers).
+func nestedEntities(entity ast.Entity) []ast.Entity {
+	var nested []ast.Entity
+	for _, step := range childSteps(entity) {
+		nested = append(nested, step)
+	}
+	for _, key := range sortedPropertyKeys(entity) {
+		val, _ := entity.GetProperty(key)
+		walkValue(val, func(v ast.Value) {
+			if n, ok := v.(ast.NestedEntityValue); ok && n.Entity != nil {
+				nested = append(nested, n.Entity)
+			}
+		})
+	}
+	return nested
+}
+
+// WalkEntity calls fn for entity and then, depth first, for every entity
+// nested inside it: pipeline and parallel steps, branch cases, loop bodies
+// and lifecycle handlers.
+func WalkEntity(entity ast.Entity, fn func(ast.Entity)) {
+	if entity == nil {
+		return
+	}
+	fn(entity)
+	for _, child := range nestedEntities(entity) {
+		WalkEntity(child, fn)
+	}
+}
+
+// namedReferences returns the references an entity's properties make by bare
+// name, resolved as the runtime resolves them: the use of an intent or step
+// names an agent, agent and pipeline properties name an entity of that type,
+// and a name in tools may be a tool or an MCP server, so both are returned.
+func namedReferences(entity ast.Entity) []Reference {
+	var refs []Reference
+	for _, key := range sortedPropertyKeys(entity) {
+		val, _ := entity.GetProperty(key)
+		switch key {
+		case "use":
+			if s, ok := val.(ast.StringValue); ok && (entity.Type() == "intent" || entity.Type() == "step") {
+				refs = append(refs, Reference{Property: key, Target: ast.ReferenceValue{Type: "agent", Name: s.Value}})
+			}
+		case "agent", "pipeline":
+			if s, ok := val.(ast.StringValue); ok {
+				refs = append(refs, Reference{Property: key, Target: ast.ReferenceValue{Type: key, Name: s.Value}})
+			}
+		case "tools":
+			if arr, ok := val.(ast.ArrayValue); ok {
+				for _, elem := range arr.Elements {
+					if s, ok := elem.(ast.StringValue); ok {
+						refs = append(refs,
+							Reference{Property: key, Target: ast.ReferenceValue{Type: "tool", Name: s.Value}},
+							Reference{Property: key, Target: ast.ReferenceValue{Type: "mcp", Name: s.Value}})
+					}
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// referrers returns the entities among entities, other than the target
+// itself, that refer to the entity of the given type and name, as found in
+// the graph of references between them. The target must be among entities.
+func referrers(entities []ast.Entity, entityType, entityName string) []ast.Entity {
+	var result []ast.Entity
+	for _, e := range newEntityGraph(entities).GetDependents(entityType, entityName) {
+		if e.Type() != entityType || e.Name() != entityName {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Referrers returns the workspace entities that reference the entity of the
+// given type and name, directly or from a nested step, in workspace order.
+func (w *Workspace) Referrers(entityType, entityName string) []ast.Entity {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return referrers(w.entities, entityType, entityName)
+}
@@ -0,0 +1,116 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shellkjell/langspace/pkg/ast"
+)
+
+// ValidateWorkspace checks references between entities that can only be
+// resolved once everything has been loaded: every tool("x") or mcp("x") an
+// agent lists must be defined, every intent and pipeline step
+// that names an agent in its `use` property must refer to an agent defined in
+// the workspace, an intent that uses a pipeline must refer to a defined
+// pipeline, and step depends_on declarations must name existing steps without
+// forming a cycle. All problems are reported together, in entity order.
+//
+// Per-entity validation (see validator.ValidateEntity) still runs as entities
+// are added; this pass complements it for callers that have a complete
+// workspace, such as the CLI's validate command.
+func ValidateWorkspace(ws *Workspace) error {
+	var errs []error
+
+	for _, entity := range ws.GetEntities() {
+		switch entity.Type() {
+		case "agent":
+			for _, ref := range toolReferences(entity) {
+				if _, found := ws.GetEntityByName(ref.Type, ref.Name); !found {
+					errs = append(errs, fmt.Errorf("agent %q: tools references undefined %s %q", entity.Name(), ref.Type, ref.Name))
+				}
+			}
+
+		case "intent":
+			if name, ok := usedAgent(entity); ok && !hasAgent(ws, name) {
+				errs = append(errs, fmt.Errorf("intent %q: use references undefined agent %q", entity.Name(), name))
+			}
+			if use, ok := entity.GetProperty("use"); ok {
+				if ref, ok := use.(ast.ReferenceValue); ok && ref.Type == "pipeline" {
+					if _, found := ws.GetEntityByName("pipeline", ref.Name); !found {
+						errs = append(errs, fmt.Errorf("intent %q: use references undefined pipeline %q", entity.Name(), ref.Name))
+					}
+				}
+			}
+
+		case "pipeline":
+			if HasStepDependencies(entity) {
+				if _, err := StepDependencies(entity); err != nil {
+					errs = append(errs, fmt.Errorf("pipeline %q: %w", entity.Name(), err))
+				}
+			}
+			WalkEntity(entity, func(e ast.Entity) {
+				if e.Type() != "step" {
+					return
+				}
+				if name, ok := usedAgent(e); ok && !hasAgent(ws, name) {
+					errs = append(errs, fmt.Errorf("pipeline %q step %q: use references undefined agent %q", entity.Name(), e.Name(), name))
+				}
+			})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// usedAgent returns the agent named by an entity's `use` property, written
+// either as agent("name") or as a bare string.
+func usedAgent(entity ast.Entity) (string, bool) {
+	use, ok := entity.GetProperty("use")
+	if !ok {
+		return "", false
+	}
+	switch v := use.(type) {
+	case ast.ReferenceValue:
+		if v.Type == "agent" {
+			return v.Name, true
+		}
+	case ast.StringValue:
+		return v.Value, true
+	}
+	return "", false
+}
+
+func hasAgent(ws *Workspace, name string) bool {
+	_, ok := ws.GetEntityByName("agent", name)
+	return ok
+}
+
+// toolReferences returns the tool and MCP server references in an agent's
+// tools property, normalising mcp_server to mcp. Bare names are skipped since
+// they may name tools registered with the runtime rather than the workspace.
+func toolReferences(agent ast.Entity) []ast.ReferenceValue {
+	tools, ok := agent.GetProperty("tools")
+	if !ok {
+		return nil
+	}
+	arr, ok := tools.(ast.ArrayValue)
+	if !ok {
+		return nil
+	}
+
+	var refs []ast.ReferenceValue
+	for _, elem := range arr.Elements {
+		ref, ok := elem.(ast.ReferenceValue)
+		if !ok {
+			continue
+		}
+		switch ref.Type {
+		case "tool", "mcp":
+			refs = append(refs, ref)
+		case "mcp_server":
+			ref.Type = "mcp"
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
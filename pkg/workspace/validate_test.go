@@ -0,0 +1,140 @@
+package workspace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shellkjell/langspace/pkg/parser"
+)
+
+func TestValidateWorkspace(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr []string
+	}{
+		{
+			name: "all references resolve",
+			source: `
+agent "solver" { model: "gpt-4o" }
+intent "solve" { use: agent("solver") }
+pipeline "p" {
+  step "s" { use: agent("solver") }
+}
+`,
+		},
+		{
+			name: "agent inherits default model",
+			source: `
+config { default_model: "gpt-4o" }
+agent "solver" { instruction: "Solve it" }
+`,
+		},
+		{
+			name: "agent without model or default",
+			source: `
+agent "solver" { instruction: "Solve it" }
+`,
+		},
+		{
+			name: "agent tools resolve",
+			source: `
+tool "lint" { command: "golangci-lint run" }
+mcp "git" { command: "npx" }
+agent "reviewer" {
+  model: "gpt-4o"
+  tools: [tool("lint"), mcp("git").get_diff, "registered_in_go"]
+}
+`,
+		},
+		{
+			name: "agent references undefined tool",
+			source: `
+mcp "git" { command: "npx" }
+agent "reviewer" {
+  model: "gpt-4o"
+  tools: [tool("lint"), mcp("gti")]
+}
+`,
+			wantErr: []string{
+				`agent "reviewer": tools references undefined tool "lint"`,
+				`agent "reviewer": tools references undefined mcp "gti"`,
+			},
+		},
+		{
+			name: "intent uses undefined pipeline",
+			source: `
+intent "run" { use: pipeline("nightly") }
+`,
+			wantErr: []string{`intent "run": use references undefined pipeline "nightly"`},
+		},
+		{
+			name: "depends_on cycle",
+			source: `
+agent "a" { model: "gpt-4o" }
+pipeline "p" {
+  step "x" { use: agent("a") depends_on: "y" }
+  step "y" { use: agent("a") depends_on: "x" }
+}
+`,
+			wantErr: []string{`pipeline "p": dependency cycle: x -> y -> x`},
+		},
+		{
+			name: "typo in step use",
+			source: `
+agent "solver" { model: "gpt-4o" }
+pipeline "p" {
+  step "first" { use: agent("solver") }
+  step "second" { use: agent("solvr") }
+}
+`,
+			wantErr: []string{`pipeline "p" step "second": use references undefined agent "solvr"`},
+		},
+		{
+			name: "intent and nested parallel step both reported",
+			source: `
+intent "i" { use: "ghost" }
+pipeline "p" {
+  parallel {
+    step "a" { use: agent("missing") }
+  }
+}
+`,
+			wantErr: []string{
+				`intent "i": use references undefined agent "ghost"`,
+				`pipeline "p" step "a": use references undefined agent "missing"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entities, _, err := parser.New(tt.source).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			ws := New()
+			for _, e := range entities {
+				if err := ws.AddEntity(e); err != nil {
+					t.Fatalf("AddEntity() error: %v", err)
+				}
+			}
+
+			err = ValidateWorkspace(ws)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("ValidateWorkspace() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("ValidateWorkspace() = nil, want error")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
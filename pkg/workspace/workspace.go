@@ -8,6 +8,8 @@ import (
 	"io"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -425,7 +427,9 @@ func (w *Workspace) runHooks(hookType HookType, entity ast.Entity) error {
 	return nil
 }
 
-// AddEntity adds an entity to the workspace
+// AddEntity adds an entity to the workspace. Unless Config.AllowDuplicateNames
+// is set, adding an entity whose type and name are already taken fails with a
+// *DuplicateEntityError.
 func (w *Workspace) AddEntity(entity ast.Entity) error {
 	if entity == nil {
 		return fmt.Errorf("cannot add nil entity")
@@ -469,6 +473,36 @@ func (w *Workspace) AddEntity(entity ast.Entity) error {
 	return nil
 }
 
+// DuplicateEntityError is returned by AddEntity when an entity with the same
+// type and name is already in the workspace.
+type DuplicateEntityError struct {
+	Existing  ast.Entity // the entity already in the workspace
+	Duplicate ast.Entity // the entity that was rejected
+}
+
+func (e *DuplicateEntityError) Error() string {
+	msg := fmt.Sprintf("duplicate %s %q", e.Duplicate.Type(), e.Duplicate.Name())
+	if e.Duplicate.Line() > 0 && e.Existing.Line() > 0 {
+		msg += fmt.Sprintf(" at line %d: already defined at line %d", e.Duplicate.Line(), e.Existing.Line())
+	}
+	return msg
+}
+
+// ReferencedEntityError is returned when removing an entity that other
+// entities still reference.
+type ReferencedEntityError struct {
+	Entity    ast.Entity   // the entity that was to be removed
+	Referrers []ast.Entity // the entities that reference it
+}
+
+func (e *ReferencedEntityError) Error() string {
+	names := make([]string, len(e.Referrers))
+	for i, r := range e.Referrers {
+		names[i] = describe(r)
+	}
+	return fmt.Sprintf("%s is still referenced by %s", describe(e.Entity), strings.Join(names, ", "))
+}
+
 // checkAddConstraints checks if adding an entity violates configuration constraints.
 // Must be called with lock held.
 func (w *Workspace) checkAddConstraints(entity ast.Entity) error {
@@ -499,7 +533,7 @@ func (w *Workspace) checkAddConstraints(entity ast.Entity) error {
 	if !w.config.AllowDuplicateNames {
 		for _, e := range w.entities {
 			if e.Type() == entity.Type() && e.Name() == entity.Name() {
-				return fmt.Errorf("entity %s/%s already exists", entity.Type(), entity.Name())
+				return &DuplicateEntityError{Existing: e, Duplicate: entity}
 			}
 		}
 	}
@@ -538,35 +572,107 @@ func (w *Workspace) GetEntityByName(entityType, entityName string) (ast.Entity,
 	})
 }
 
-// RemoveEntity removes an entity from the workspace by type and name
+// Query returns the entities of type typ for which pred returns true, sorted
+// by name, then by type. An empty typ matches every type and a nil pred
+// matches every entity. Entities that share a type and name keep the order
+// in which they were added.
+func (w *Workspace) Query(typ string, pred EntityPredicate) []ast.Entity {
+	// pred runs without the lock held so it may call back into the workspace
+	result := slices.Filter(w.GetEntities(), func(e ast.Entity) bool {
+		return (typ == "" || e.Type() == typ) && (pred == nil || pred(e))
+	})
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Name() != result[j].Name() {
+			return result[i].Name() < result[j].Name()
+		}
+		return result[i].Type() < result[j].Type()
+	})
+	return result
+}
+
+// AllAgents returns every agent in the workspace, sorted by name.
+func (w *Workspace) AllAgents() []ast.Entity {
+	return w.Query("agent", nil)
+}
+
+// AllPipelines returns every pipeline in the workspace, sorted by name.
+func (w *Workspace) AllPipelines() []ast.Entity {
+	return w.Query("pipeline", nil)
+}
+
+// AllIntents returns every intent in the workspace, sorted by name.
+func (w *Workspace) AllIntents() []ast.Entity {
+	return w.Query("intent", nil)
+}
+
+// RemoveEntity removes an entity from the workspace by type and name. It
+// fails with a ReferencedEntityError if other entities still reference it;
+// ForceRemoveEntity removes it regardless.
 func (w *Workspace) RemoveEntity(entityType, entityName string) error {
+	return w.removeEntity(entityType, entityName, false)
+}
+
+// ForceRemoveEntity removes an entity from the workspace by type and name,
+// even if other entities still reference it.
+func (w *Workspace) ForceRemoveEntity(entityType, entityName string) error {
+	return w.removeEntity(entityType, entityName, true)
+}
+
+func (w *Workspace) removeEntity(entityType, entityName string, force bool) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	for i, entity := range w.entities {
 		if entity.Type() == entityType && entity.Name() == entityName {
-			// Run before-remove hooks
-			if err := w.runHooks(HookBeforeRemove, entity); err != nil {
-				return err
+			if !force {
+				if refs := referrers(w.entities, entityType, entityName); len(refs) > 0 {
+					return &ReferencedEntityError{Entity: entity, Referrers: refs}
+				}
 			}
+			return w.removeAt(i)
+		}
+	}
 
-			// Remove the entity
-			w.entities = append(w.entities[:i], w.entities[i+1:]...)
+	return fmt.Errorf("entity not found: %s %q", entityType, entityName)
+}
 
-			// Remove any relationships involving this entity
-			w.removeRelationshipsForEntity(entityType, entityName)
+// removeInstance removes entity itself, rather than the first entity of its
+// type and name, even if other entities still reference it.
+func (w *Workspace) removeInstance(entity ast.Entity) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-			// Run after-remove hooks
-			_ = w.runHooks(HookAfterRemove, entity)
+	for i, e := range w.entities {
+		if e == entity {
+			return w.removeAt(i)
+		}
+	}
+	return fmt.Errorf("entity not found: %s %q", entity.Type(), entity.Name())
+}
 
-			// Emit entity removed event
-			w.emit(Event{Type: EventEntityRemoved, Entity: entity})
+// removeAt removes the entity at index i. The caller must hold w.mu.
+func (w *Workspace) removeAt(i int) error {
+	entity := w.entities[i]
 
-			return nil
-		}
+	// Run before-remove hooks
+	if err := w.runHooks(HookBeforeRemove, entity); err != nil {
+		return err
 	}
 
-	return fmt.Errorf("entity not found: %s %q", entityType, entityName)
+	// Remove the entity
+	w.entities = append(w.entities[:i], w.entities[i+1:]...)
+
+	// Remove any relationships involving this entity
+	w.removeRelationshipsForEntity(entity.Type(), entity.Name())
+
+	// Run after-remove hooks
+	_ = w.runHooks(HookAfterRemove, entity)
+
+	// Emit entity removed event
+	w.emit(Event{Type: EventEntityRemoved, Entity: entity})
+
+	return nil
 }
 
 // UpdateEntity replaces an existing entity with a new version.
@@ -622,6 +728,8 @@ func (w *Workspace) UpdateEntity(entity ast.Entity) error {
 
 // UpsertEntity adds an entity if it doesn't exist, or updates it if it does.
 // This is a convenience method combining AddEntity and UpdateEntity behavior.
+// Use it where overriding a definition is intended; AddEntity reports a
+// DuplicateEntityError instead.
 func (w *Workspace) UpsertEntity(entity ast.Entity) error {
 	if entity == nil {
 		return fmt.Errorf("cannot upsert nil entity")
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/shellkjell/langspace/pkg/ast"
+	"github.com/shellkjell/langspace/pkg/parser"
 	"github.com/shellkjell/langspace/pkg/validator"
 )
 
@@ -137,6 +138,57 @@ func TestWorkspace_GetEntityByName(t *testing.T) {
 	}
 }
 
+func TestWorkspace_Query(t *testing.T) {
+	w := New()
+	_ = w.AddEntity(createAgentEntity("writer"))
+	_ = w.AddEntity(createToolEntity("linter"))
+	_ = w.AddEntity(createAgentEntity("critic"))
+	_ = w.AddEntity(createFileEntity("notes.txt"))
+	_ = w.AddEntity(createAgentEntity("analyst"))
+	pipeline, _ := ast.NewEntity("pipeline", "review")
+	_ = w.AddEntity(pipeline)
+	fast := createAgentEntity("fast")
+	fast.SetProperty("model", ast.StringValue{Value: "gpt-4o-mini"})
+	_ = w.AddEntity(fast)
+
+	names := func(entities []ast.Entity) string {
+		var parts []string
+		for _, e := range entities {
+			parts = append(parts, e.Type()+":"+e.Name())
+		}
+		return strings.Join(parts, ",")
+	}
+	usesModel := func(model string) EntityPredicate {
+		return func(e ast.Entity) bool {
+			v, ok := e.GetProperty("model")
+			return ok && v == ast.StringValue{Value: model}
+		}
+	}
+
+	tests := []struct {
+		name string
+		got  []ast.Entity
+		want string
+	}{
+		{"by type", w.Query("agent", nil), "agent:analyst,agent:critic,agent:fast,agent:writer"},
+		{"by type and predicate", w.Query("agent", usesModel("gpt-4o")), "agent:analyst,agent:critic,agent:writer"},
+		{"predicate across types", w.Query("", usesModel("gpt-4o-mini")), "agent:fast"},
+		{"all types", w.Query("", nil), "agent:analyst,agent:critic,agent:fast,tool:linter,file:notes.txt,pipeline:review,agent:writer"},
+		{"unknown type", w.Query("intent", nil), ""},
+		{"AllAgents", w.AllAgents(), "agent:analyst,agent:critic,agent:fast,agent:writer"},
+		{"AllPipelines", w.AllPipelines(), "pipeline:review"},
+		{"AllIntents", w.AllIntents(), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(tt.got); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestWorkspace_Clear(t *testing.T) {
 	w := New()
 	_ = w.AddEntity(createFileEntity("test.txt"))
@@ -644,6 +696,82 @@ func TestWorkspace_RemoveEntity(t *testing.T) {
 			t.Error("Entity should not be removed when hook fails")
 		}
 	})
+
+	t.Run("referenced_entity", func(t *testing.T) {
+		w := New()
+		entities, _, err := parser.New(`
+agent "writer" { model: "gpt-4o" }
+pipeline "p" {
+  step "draft" { use: agent("writer") }
+}
+`).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		for _, e := range entities {
+			_ = w.AddEntity(e)
+		}
+
+		err = w.RemoveEntity("agent", "writer")
+		var refErr *ReferencedEntityError
+		if !errors.As(err, &refErr) || len(refErr.Referrers) != 1 || refErr.Referrers[0].Name() != "p" {
+			t.Fatalf("RemoveEntity() error = %v, want the pipeline named as a referrer", err)
+		}
+		if _, ok := w.GetEntityByName("agent", "writer"); !ok {
+			t.Error("Referenced entity should not be removed")
+		}
+
+		if err := w.ForceRemoveEntity("agent", "writer"); err != nil {
+			t.Errorf("ForceRemoveEntity() error = %v", err)
+		}
+		if _, ok := w.GetEntityByName("agent", "writer"); ok {
+			t.Error("Entity should be removed when forced")
+		}
+	})
+	t.Run("referenced_by_name", func(t *testing.T) {
+		w := New()
+		entities, _, err := parser.New(`
+tool "lint" { command: "golangci-lint run" }
+agent "writer" {
+  model: "gpt-4o"
+  tools: ["lint"]
+}
+intent "write" { use: "writer" }
+pipeline "p" {
+  step "draft" { use: "writer" }
+}
+`).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		for _, e := range entities {
+			if err := w.AddEntity(e); err != nil {
+				t.Fatalf("AddEntity() error = %v", err)
+			}
+		}
+
+		tests := []struct {
+			entityType, name string
+			referrers        []string
+		}{
+			{"agent", "writer", []string{"write", "p"}},
+			{"tool", "lint", []string{"writer"}},
+		}
+		for _, tt := range tests {
+			err := w.RemoveEntity(tt.entityType, tt.name)
+			var refErr *ReferencedEntityError
+			if !errors.As(err, &refErr) {
+				t.Fatalf("RemoveEntity(%s %q) error = %v, want a ReferencedEntityError", tt.entityType, tt.name, err)
+			}
+			var got []string
+			for _, e := range refErr.Referrers {
+				got = append(got, e.Name())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.referrers, ",") {
+				t.Errorf("RemoveEntity(%s %q) referrers = %v, want %v", tt.entityType, tt.name, got, tt.referrers)
+			}
+		}
+	})
 }
 
 func TestWorkspace_Stats_WithHooks(t *testing.T) {
@@ -767,9 +895,9 @@ func TestWorkspace_UpdateEntity(t *testing.T) {
 		original := createAgentEntity("assistant")
 		_ = w.AddEntity(original)
 
-		// Create invalid entity (missing required model)
+		// Create invalid entity (model must be a string)
 		invalid, _ := ast.NewEntity("agent", "assistant")
-		// Don't set model property
+		invalid.SetProperty("model", ast.NumberValue{Value: 4})
 
 		err := w.UpdateEntity(invalid)
 		if err == nil {
@@ -1891,6 +2019,45 @@ func TestWorkspace_Config(t *testing.T) {
 		}
 	})
 
+	t.Run("duplicate_names_error_names_both", func(t *testing.T) {
+		w := New()
+
+		first := createFileEntity("test.txt")
+		first.SetLocation(2, 1)
+		second := createFileEntity("test.txt")
+		second.SetLocation(9, 1)
+
+		_ = w.AddEntity(first)
+		err := w.AddEntity(second)
+
+		var dup *DuplicateEntityError
+		if !errors.As(err, &dup) {
+			t.Fatalf("AddEntity() error = %v, want *DuplicateEntityError", err)
+		}
+		if dup.Existing != first || dup.Duplicate != second {
+			t.Error("DuplicateEntityError should reference both entities")
+		}
+		want := `duplicate file "test.txt" at line 9: already defined at line 2`
+		if err.Error() != want {
+			t.Errorf("Error() = %q, want %q", err.Error(), want)
+		}
+	})
+
+	t.Run("upsert_overrides", func(t *testing.T) {
+		w := New()
+
+		_ = w.AddEntity(createFileEntity("test.txt"))
+		replacement := createFileEntity("test.txt")
+		if err := w.UpsertEntity(replacement); err != nil {
+			t.Fatalf("UpsertEntity() error: %v", err)
+		}
+
+		entities := w.GetEntities()
+		if len(entities) != 1 || entities[0] != replacement {
+			t.Errorf("expected the replacement to be the only entity, got %v", entities)
+		}
+	})
+
 	t.Run("duplicate_names_allowed", func(t *testing.T) {
 		cfg := &Config{AllowDuplicateNames: true}
 		w := New().WithConfig(cfg)